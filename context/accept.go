@@ -0,0 +1,166 @@
+package context
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// acceptSpec is a media range or language range with its quality in Accept(-Language) header
+type acceptSpec struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses header like "text/html, application/json;q=0.9, */*;q=0.1", specs are
+// sorted by q descending, specs with q=0 are kept to exclude values
+func parseAccept(header string) []acceptSpec {
+	var specs []acceptSpec
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil && v >= 0 && v <= 1 {
+					q = v
+				}
+			}
+		}
+
+		specs = append(specs, acceptSpec{value, q})
+	}
+
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specs[i].q > specs[j].q
+	})
+
+	return specs
+}
+
+// Accepts returns the best type in offered accepted by client according to Accept header, q-values
+// and wildcards like "*/*" and "text/*" are respected, more specific ranges take precedence, and
+// ties are broken by order of offered. The first offered is returned if Accept is absent, and ""
+// if none accepted.
+//
+//	switch ctx.Accepts("application/json", "text/html") {
+//	case "application/json":
+//	...
+func (c *Context) Accepts(offered ...string) string {
+	header := c.Get("Accept")
+	if header == "" {
+		if len(offered) > 0 {
+			return offered[0]
+		}
+		return ""
+	}
+
+	specs := parseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		q, specificity := -1.0, -1
+		for _, spec := range specs {
+			if s := mediaMatch(spec.value, strings.ToLower(offer)); s > specificity {
+				q, specificity = spec.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// mediaMatch returns specificity of media range matching typ, 2 for exact, 1 for "type/*", 0 for
+// "*/*", and -1 if not matched
+func mediaMatch(spec, typ string) int {
+	switch {
+	case spec == typ:
+		return 2
+	case spec == "*/*" || spec == "*":
+		return 0
+	case strings.HasSuffix(spec, "/*") && strings.HasPrefix(typ, spec[:len(spec)-1]):
+		return 1
+	default:
+		return -1
+	}
+}
+
+// acceptsExplicit checks if any of types listed in Accept header with q > 0, wildcards not count
+func (c *Context) acceptsExplicit(types ...string) bool {
+	for _, spec := range parseAccept(c.Get("Accept")) {
+		if spec.q <= 0 {
+			continue
+		}
+
+		for _, typ := range types {
+			if spec.value == typ {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AcceptLanguage returns the best language in supported according to Accept-Language header
+// with q-values. A range matches tags with it as prefix, "en" matches "en-US", and "en-US"
+// falls back to "en" if only the latter supported. The first supported is returned if nothing
+// matched.
+//
+//	lang := ctx.AcceptLanguage("en", "zh-CN", "fr")
+func (c *Context) AcceptLanguage(supported ...string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+
+	for _, spec := range parseAccept(c.Get("Accept-Language")) {
+		if spec.q <= 0 {
+			continue
+		}
+
+		if spec.value == "*" {
+			return supported[0]
+		}
+
+		if lang := matchLanguage(spec.value, supported); lang != "" {
+			return lang
+		}
+	}
+
+	return supported[0]
+}
+
+// matchLanguage matches language range with supported tags, exact match first, then tags with
+// range as prefix, then the primary language of range
+func matchLanguage(lang string, supported []string) string {
+	for _, tag := range supported {
+		if strings.EqualFold(tag, lang) {
+			return tag
+		}
+	}
+
+	for _, tag := range supported {
+		if strings.HasPrefix(strings.ToLower(tag), lang+"-") {
+			return tag
+		}
+	}
+
+	if i := strings.Index(lang, "-"); i > 0 {
+		for _, tag := range supported {
+			if strings.EqualFold(tag, lang[:i]) {
+				return tag
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,57 @@
+package context
+
+import (
+	"testing"
+)
+
+func TestAccepts(t *testing.T) {
+	cases := []struct {
+		accept  string
+		offered []string
+		best    string
+	}{
+		{"application/xml;q=0.9, application/json;q=0.8", []string{"application/json", "application/xml"}, "application/xml"},
+		{"application/json, application/xml", []string{"application/xml", "application/json"}, "application/xml"},
+		{"text/*;q=0.5, text/html", []string{"text/plain", "text/html"}, "text/html"},
+		{"text/*;q=0.5, */*;q=0.1", []string{"application/json", "text/plain"}, "text/plain"},
+		{"application/json;q=0, */*", []string{"application/json", "text/html"}, "text/html"},
+		{"image/png", []string{"application/json"}, ""},
+		{"", []string{"application/json", "text/html"}, "application/json"},
+	}
+
+	for _, c := range cases {
+		ctx, _ := newTestContext("GET", "/")
+		ctx.Set("Accept", c.accept)
+		if best := ctx.Accepts(c.offered...); best != c.best {
+			t.Errorf("%q %v: expect %q, got %q", c.accept, c.offered, c.best, best)
+		}
+	}
+
+	ctx, _ := newTestContext("GET", "/")
+	ctx.Set("Accept", "text/html;q=0.9, application/json;q=0")
+	if !ctx.AcceptsHTML() || ctx.AcceptsJSON() {
+		t.Errorf("unexpected AcceptsHTML %v, AcceptsJSON %v", ctx.AcceptsHTML(), ctx.AcceptsJSON())
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	supported := []string{"en-US", "zh-CN", "fr"}
+
+	cases := map[string]string{
+		"zh-CN,zh;q=0.9,en;q=0.8": "zh-CN",
+		"de, fr;q=0.5":            "fr",
+		"en":                      "en-US",
+		"fr-CA, en;q=0.9":         "fr",
+		"ja":                      "en-US",
+		"fr;q=0, zh;q=0.5":        "zh-CN",
+		"":                        "en-US",
+	}
+
+	for header, lang := range cases {
+		ctx, _ := newTestContext("GET", "/")
+		ctx.Set("Accept-Language", header)
+		if got := ctx.AcceptLanguage(supported...); got != lang {
+			t.Errorf("%q: expect %s, got %s", header, lang, got)
+		}
+	}
+}
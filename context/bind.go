@@ -0,0 +1,428 @@
+package context
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// All binders honor the `default:"value"` tag, fields absent in request will be set to the
+// default value, and fields explicitly set, even to zero value, will be kept. For slice fields,
+// default value is split by ",".
+
+// BindJSON binds JSON body into v
+func (c *Context) BindJSON(v interface{}) error {
+	if err := applyDefaults(v); err != nil {
+		return err
+	}
+
+	if c.bodyErr != nil {
+		return errors.New("Bind: read body failed: " + c.bodyErr.Error())
+	}
+
+	if err := json.Unmarshal(c.body, v); err != nil {
+		return errors.New("Bind: invalid JSON: " + err.Error())
+	}
+
+	return nil
+}
+
+// BindXML binds XML body into v, xml struct tags are supported, including attributes like
+// `xml:"id,attr"` and namespaces like `xml:"http://example.com/ns item"`
+func (c *Context) BindXML(v interface{}) error {
+	if err := applyDefaults(v); err != nil {
+		return err
+	}
+
+	if c.bodyErr != nil {
+		return errors.New("Bind: read body failed: " + c.bodyErr.Error())
+	}
+
+	if err := xml.Unmarshal(c.body, v); err != nil {
+		return errors.New("Bind: invalid XML: " + err.Error())
+	}
+
+	return nil
+}
+
+// BindForm binds form parameters, both URL query and body form, into struct v, fields are
+// mapped by the `form:"name"` tag, or the field name if no tag set. Multipart form is parsed
+// if not yet.
+func (c *Context) BindForm(v interface{}) error {
+	if c.isMultipart() {
+		if _, err := c.MultipartForm(); err != nil {
+			return errors.New("Bind: invalid multipart form: " + err.Error())
+		}
+	}
+
+	return bindValues(c.request.Form, v, "form")
+}
+
+// BindQuery binds URL query parameters into struct v, fields are mapped by the `query:"name"`
+// tag, or the field name if no tag set. Slice fields are filled with repeated keys, like
+// tags=a&tags=b, and with a `delim:","` tag, a single value tags=a,b will be split too.
+func (c *Context) BindQuery(v interface{}) error {
+	return bindValues(c.QueryValues(), v, "query")
+}
+
+// MustBind binds request into v by Content-Type, on failure, it responds 400 by the error handler,
+// which is negotiated by Accept header by default, and returns false, handler should return then.
+//
+//	if !ctx.MustBind(&user) {
+//		return
+//	}
+func (c *Context) MustBind(v interface{}) bool {
+	if err := c.Bind(v); err != nil {
+		c.HandleError(http.StatusBadRequest, err)
+		return false
+	}
+
+	return true
+}
+
+// MustBindJSON is same as MustBind, but always binds body as JSON
+func (c *Context) MustBindJSON(v interface{}) bool {
+	if err := c.BindJSON(v); err != nil {
+		c.HandleError(http.StatusBadRequest, err)
+		return false
+	}
+
+	return true
+}
+
+// ClaimsKey is key of JWT claims saved in context by JWT midware with SetValue, as JSON bytes
+const ClaimsKey = "com.raythorn.falcon.jwt.claims"
+
+// ErrNoClaims is returned by BindClaims if request has no JWT claims, it was not authenticated
+var ErrNoClaims = errors.New("Claims: no claims, request not authenticated")
+
+// BindClaims unmarshals JWT claims saved by JWT midware into v, ErrNoClaims returned if request
+// was not authenticated, and other errors if claims don't match type of v. Claims are only read
+// from values of SetValue, query and headers with ClaimsKey are never trusted.
+//
+//	var claims struct {
+//		Subject string   `json:"sub"`
+//		Roles   []string `json:"roles"`
+//	}
+//	if err := ctx.BindClaims(&claims); err != nil {
+//		ctx.Error(http.StatusUnauthorized, err.Error())
+//		return
+//	}
+func (c *Context) BindClaims(v interface{}) error {
+	value, _ := c.Value(ClaimsKey)
+	raw, ok := value.([]byte)
+	if !ok {
+		return ErrNoClaims
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.New("Claims: invalid claims: " + err.Error())
+	}
+
+	return nil
+}
+
+// Bind binds request into v by Content-Type:
+//
+//	application/json, */*+json                             BindJSON
+//	application/xml, text/xml, */*+xml                     BindXML
+//	application/x-www-form-urlencoded, multipart/form-data BindForm
+//
+// Requests without Content-Type and body, like GET, are bound with URL query by BindForm,
+// other content types are rejected with error.
+func (c *Context) Bind(v interface{}) error {
+	if c.bodyErr != nil {
+		return errors.New("Bind: read body failed: " + c.bodyErr.Error())
+	}
+
+	contentType := c.request.Header.Get("Content-Type")
+	if contentType == "" && len(c.body) == 0 {
+		return c.BindForm(v)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return errors.New("Bind: invalid Content-Type " + strconv.Quote(contentType))
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return c.BindJSON(v)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return c.BindXML(v)
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		return c.BindForm(v)
+	default:
+		return errors.New("Bind: unsupported Content-Type " + strconv.Quote(mediaType))
+	}
+}
+
+// BindAndRespond binds request into v by Content-Type, see MustBind, then calls fn and responds
+// the result in XML if client accepts XML only, JSON otherwise. Bind failures are handled as 400
+// and errors returned by fn as 500 by the error handler, see HandleError.
+//
+//	ctx.BindAndRespond(&order, func(v interface{}) (interface{}, error) {
+//		return price(v.(*Order))
+//	})
+func (c *Context) BindAndRespond(v interface{}, fn func(v interface{}) (interface{}, error)) error {
+	if err := c.Bind(v); err != nil {
+		c.HandleError(http.StatusBadRequest, err)
+		return err
+	}
+
+	result, err := fn(v)
+	if err != nil {
+		c.HandleError(http.StatusInternalServerError, err)
+		return err
+	}
+
+	if c.Accepts("application/json", "application/xml", "text/xml") != "application/json" {
+		return c.XML(result, false)
+	}
+
+	return c.JSON(result, false)
+}
+
+// bindValues decode values into struct pointed by v with field name from tag
+func bindValues(values url.Values, v interface{}, tag string) error {
+
+	if err := applyDefaults(v); err != nil {
+		return err
+	}
+
+	return bindStruct(values, reflect.ValueOf(v).Elem(), tag)
+}
+
+// applyDefaults sets fields of struct pointed by v with value of `default` tag
+func applyDefaults(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("Bind: target must be a non-nil pointer")
+	}
+
+	return defaultStruct(rv.Elem())
+}
+
+func defaultStruct(rv reflect.Value) error {
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := defaultStruct(value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		def, ok := field.Tag.Lookup("default")
+		if !ok || !value.CanSet() {
+			continue
+		}
+
+		vals := []string{def}
+		if field.Type.Kind() == reflect.Slice {
+			vals = strings.Split(def, ",")
+		}
+
+		if err := setField(value, vals); err != nil {
+			return fmt.Errorf("Bind: default of field %s: %s", field.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// BindErrors is returned by BindQuery and BindForm if values can not be converted to types of
+// fields, Field of each error is the key of value, such as "page" of ?page=abc, and Rule is
+// "type". It can be used as details of ErrorWith directly.
+type BindErrors []FieldError
+
+func (b BindErrors) Error() string {
+	messages := make([]string, len(b))
+	for i, e := range b {
+		messages[i] = e.Field + " " + e.Message
+	}
+
+	return "Bind: " + strings.Join(messages, "; ")
+}
+
+func bindStruct(values url.Values, rv reflect.Value, tag string) error {
+	var errs BindErrors
+	if err := bindFields(values, rv, tag, &errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// bindFields binds values into fields of rv, conversion errors of all fields are collected in errs
+func bindFields(values url.Values, rv reflect.Value, tag string, errs *BindErrors) error {
+
+	if rv.Kind() != reflect.Struct {
+		return errors.New("Bind: target must be a non-nil pointer to struct")
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindFields(values, value, tag, errs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.PkgPath != "" || !value.CanSet() {
+			continue
+		}
+
+		name := field.Tag.Get(tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		if delim := field.Tag.Get("delim"); delim != "" && field.Type.Kind() == reflect.Slice {
+			split := make([]string, 0, len(vals))
+			for _, val := range vals {
+				for _, item := range strings.Split(val, delim) {
+					if item = strings.TrimSpace(item); item != "" {
+						split = append(split, item)
+					}
+				}
+			}
+			vals = split
+		}
+
+		if err := setField(value, vals); err != nil {
+			*errs = append(*errs, FieldError{
+				Field:   name,
+				Rule:    "type",
+				Message: fmt.Sprintf("must be %s, got %q", typeName(field.Type), strings.Join(vals, ",")),
+			})
+		}
+	}
+
+	return nil
+}
+
+// typeName returns readable name of field type in errors, such as "int" or "list of int"
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return "list of " + typeName(t.Elem())
+		}
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "unsigned integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// setField convert vals and set to field, only the first value used for non-slice field
+func setField(field reflect.Value, vals []string) error {
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), val); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	if len(vals) == 0 {
+		return nil
+	}
+
+	return setValue(field, vals[0])
+}
+
+// setValue convert a single string to the kind of field
+func setValue(field reflect.Value, val string) error {
+
+	switch field.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), val); err != nil {
+			return err
+		}
+		field.Set(ptr)
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Slice:
+		field.SetBytes([]byte(val))
+	case reflect.Bool:
+		if val == "" || val == "on" {
+			field.SetBool(val == "on")
+			return nil
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return errors.New("unsupported type " + field.Type().String())
+	}
+
+	return nil
+}
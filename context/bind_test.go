@@ -0,0 +1,328 @@
+package context
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestContext(method, target string) (*Context, *httptest.ResponseRecorder) {
+	return newBodyContext(method, target, "", "")
+}
+
+func newBodyContext(method, target, contentType, body string) (*Context, *httptest.ResponseRecorder) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, req)
+	return ctx, rw
+}
+
+func TestBindQuerySlice(t *testing.T) {
+	var filter struct {
+		Tags   []string `query:"tags" delim:","`
+		Ids    []int    `query:"id"`
+		Page   int      `query:"page"`
+		Active bool     `query:"active"`
+	}
+
+	ctx, _ := newTestContext("GET", "/items?tags=a,b,c&id=1&id=2&page=3&active=true")
+	if err := ctx.BindQuery(&filter); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filter.Tags) != 3 || filter.Tags[0] != "a" || filter.Tags[2] != "c" {
+		t.Errorf("comma-separated slice not bound: %v", filter.Tags)
+	}
+
+	if len(filter.Ids) != 2 || filter.Ids[0] != 1 || filter.Ids[1] != 2 {
+		t.Errorf("repeated-key slice not bound: %v", filter.Ids)
+	}
+
+	if filter.Page != 3 || !filter.Active {
+		t.Errorf("scalar fields not bound: %+v", filter)
+	}
+}
+
+func TestBindQueryInvalid(t *testing.T) {
+	var filter struct {
+		Page int `query:"page"`
+	}
+
+	ctx, _ := newTestContext("GET", "/items?page=abc")
+	if err := ctx.BindQuery(&filter); err == nil {
+		t.Error("expect error for unconvertible value")
+	}
+}
+
+func TestBindQueryFieldErrors(t *testing.T) {
+	var filter struct {
+		Page   int      `query:"page"`
+		Ids    []int    `query:"id"`
+		Tags   []string `query:"tag"`
+		Active bool     `query:"active"`
+	}
+
+	ctx, _ := newTestContext("GET", "/items?page=x&id=1&id=b&tag=a&tag=b&active=maybe")
+	err := ctx.BindQuery(&filter)
+
+	errs, ok := err.(BindErrors)
+	if !ok {
+		t.Fatalf("expect BindErrors, got %T %v", err, err)
+	}
+
+	if len(errs) != 3 || errs[0].Field != "page" || errs[1].Field != "id" || errs[2].Field != "active" {
+		t.Fatalf("expect errors of page, id and active, got %+v", errs)
+	}
+
+	if errs[0].Rule != "type" || errs[1].Message != `must be list of integer, got "1,b"` {
+		t.Errorf("unexpected field error: %+v", errs)
+	}
+
+	if len(filter.Tags) != 2 || filter.Tags[1] != "b" {
+		t.Errorf("valid fields should still be bound: %v", filter.Tags)
+	}
+}
+
+type pageOptions struct {
+	Limit  int      `json:"limit" query:"limit" default:"10"`
+	Offset int      `json:"offset" query:"offset" default:"5"`
+	Sort   []string `json:"sort" query:"sort" default:"id,name"`
+}
+
+func TestBindDefaults(t *testing.T) {
+	var opts pageOptions
+
+	ctx, _ := newBodyContext("POST", "/items", "application/json", `{"offset": 0}`)
+	if err := ctx.BindJSON(&opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.Limit != 10 || len(opts.Sort) != 2 {
+		t.Errorf("absent fields should get defaults: %+v", opts)
+	}
+
+	if opts.Offset != 0 {
+		t.Errorf("explicit zero should be preserved, got %d", opts.Offset)
+	}
+
+	opts = pageOptions{}
+	ctx, _ = newTestContext("GET", "/items?offset=0")
+	if err := ctx.BindQuery(&opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.Limit != 10 || opts.Offset != 0 || opts.Sort[1] != "name" {
+		t.Errorf("query binding defaults not applied consistently: %+v", opts)
+	}
+}
+
+type xmlOrder struct {
+	XMLName xml.Name `xml:"urn:zebra:order order"`
+	ID      string   `xml:"id,attr"`
+	Lang    string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Items   []struct {
+		Sku   string `xml:"sku,attr"`
+		Count int    `xml:"urn:zebra:order count"`
+	} `xml:"urn:zebra:order item"`
+}
+
+func TestBindXML(t *testing.T) {
+	body := `<o:order xmlns:o="urn:zebra:order" id="42" xml:lang="en">
+		<o:item sku="a1"><o:count>2</o:count></o:item>
+		<o:item sku="b2"><o:count>3</o:count></o:item>
+	</o:order>`
+
+	var order xmlOrder
+	ctx, _ := newBodyContext("POST", "/orders", "application/xml", body)
+	if err := ctx.BindXML(&order); err != nil {
+		t.Fatal(err)
+	}
+
+	if order.ID != "42" || order.Lang != "en" {
+		t.Errorf("attributes not bound: %+v", order)
+	}
+
+	if len(order.Items) != 2 || order.Items[0].Sku != "a1" || order.Items[1].Count != 3 {
+		t.Errorf("namespaced elements not bound: %+v", order.Items)
+	}
+
+	ctx, _ = newBodyContext("POST", "/orders", "application/xml", "<order><item></order>")
+	if err := ctx.BindXML(&order); err == nil {
+		t.Error("expect error for malformed XML")
+	}
+}
+
+func TestBindAndRespond(t *testing.T) {
+	type Order struct {
+		Price    int `json:"price"`
+		Quantity int `json:"quantity"`
+	}
+
+	total := func(v interface{}) (interface{}, error) {
+		order := v.(*Order)
+		if order.Quantity <= 0 {
+			return nil, errors.New("quantity must be positive")
+		}
+		return map[string]int{"total": order.Price * order.Quantity}, nil
+	}
+
+	ctx, rw := newBodyContext("POST", "/orders", "application/json", `{"price":3,"quantity":4}`)
+	if err := ctx.BindAndRespond(&Order{}, total); err != nil {
+		t.Fatal(err)
+	}
+	if rw.Code != http.StatusOK || strings.TrimSpace(rw.Body.String()) != `{"total":12}` {
+		t.Errorf("unexpected response %d %s", rw.Code, rw.Body.String())
+	}
+
+	ctx, rw = newBodyContext("POST", "/orders", "application/json", `{"price":3,"quantity":0}`)
+	ctx.Set("Accept", "application/json")
+	if err := ctx.BindAndRespond(&Order{}, total); err == nil {
+		t.Error("expect error from transform")
+	}
+	if rw.Code != http.StatusInternalServerError || rw.Body.String() != `{"error":{"code":500,"message":"quantity must be positive"}}` {
+		t.Errorf("unexpected error response %d %s", rw.Code, rw.Body.String())
+	}
+
+	ctx, rw = newBodyContext("POST", "/orders", "application/json", `{"price":`)
+	ctx.SetErrorHandler(func(c *Context, code int, err error) {
+		c.WriteHeader(code)
+		c.WriteString("custom")
+	})
+	if err := ctx.BindAndRespond(&Order{}, total); err == nil {
+		t.Error("expect bind error")
+	}
+	if rw.Code != http.StatusBadRequest || rw.Body.String() != "custom" {
+		t.Errorf("unexpected error response %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestMustBind(t *testing.T) {
+	type User struct {
+		Name string `json:"name" form:"name"`
+	}
+
+	ctx, rw := newBodyContext("POST", "/users", "application/json", `{"name":`)
+	ctx.Set("Accept", "application/json")
+	if ctx.MustBind(&User{}) {
+		t.Error("expect MustBind failed")
+	}
+	if rw.Code != http.StatusBadRequest || rw.Header().Get("Content-Type") != "application/json; charset=utf-8" ||
+		!strings.HasPrefix(rw.Body.String(), `{"error":{"code":400,"message":"Bind: invalid JSON`) {
+		t.Errorf("unexpected JSON error %d %s", rw.Code, rw.Body.String())
+	}
+
+	ctx, rw = newBodyContext("POST", "/users", "application/json", `{"name":`)
+	ctx.Set("Accept", "text/html,application/xhtml+xml")
+	if ctx.MustBind(&User{}) {
+		t.Error("expect MustBind failed")
+	}
+	if rw.Code != http.StatusBadRequest || !strings.HasPrefix(rw.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(rw.Body.String(), "<h1>400 Bad Request</h1>") {
+		t.Errorf("unexpected HTML error %d %s", rw.Code, rw.Body.String())
+	}
+
+	ctx, rw = newBodyContext("POST", "/users", "application/json", `{"name":`)
+	ctx.Set("Accept", "text/html,application/json;q=0.1")
+	if ctx.MustBind(&User{}) {
+		t.Error("expect MustBind failed")
+	}
+	if !strings.HasPrefix(rw.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("expect HTML preferred by q-value, got %s %s", rw.Header().Get("Content-Type"), rw.Body.String())
+	}
+
+	var user User
+	ctx, _ = newBodyContext("POST", "/users", "application/x-www-form-urlencoded", "name=alice")
+	if !ctx.MustBind(&user) || user.Name != "alice" {
+		t.Errorf("expect form bound, got %+v", user)
+	}
+}
+
+func TestBind(t *testing.T) {
+	type Item struct {
+		Name string `json:"name" xml:"name" form:"name"`
+	}
+
+	cases := []struct {
+		method, target, contentType, body string
+	}{
+		{"POST", "/items", "application/json; charset=utf-8", `{"name":"zebra"}`},
+		{"POST", "/items", "application/vnd.api+json", `{"name":"zebra"}`},
+		{"POST", "/items", "text/xml", `<item><name>zebra</name></item>`},
+		{"POST", "/items", "application/x-www-form-urlencoded", `name=zebra`},
+		{"GET", "/items?name=zebra", "", ""},
+	}
+
+	for _, c := range cases {
+		var item Item
+		ctx, _ := newBodyContext(c.method, c.target, c.contentType, c.body)
+		if err := ctx.Bind(&item); err != nil || item.Name != "zebra" {
+			t.Errorf("%s: expect bound, got %+v %v", c.contentType, item, err)
+		}
+	}
+
+	ctx, _ := newBodyContext("POST", "/items", "text/csv", "name\nzebra")
+	if err := ctx.Bind(&Item{}); err == nil || !strings.Contains(err.Error(), "unsupported Content-Type") {
+		t.Errorf("expect unsupported Content-Type error, got %v", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestBodyError(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", failingReader{})
+	req.Header.Set("Content-Type", "application/json")
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	if ctx.BodyError() == nil || len(ctx.Body()) != 0 {
+		t.Fatalf("expect body error, got %v", ctx.BodyError())
+	}
+
+	var v struct{ Name string }
+	if err := ctx.Bind(&v); err == nil || err.Error() != "Bind: read body failed: connection reset" {
+		t.Errorf("unexpected bind error %v", err)
+	}
+
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+	if ctx.BodyError() != nil {
+		t.Errorf("expect no body error for empty body, got %v", ctx.BodyError())
+	}
+}
+
+func TestBindClaimsForged(t *testing.T) {
+	forged := url.QueryEscape(`{"sub":"admin"}`)
+	ctx, _ := newTestContext("GET", "/public?"+ClaimsKey+"="+forged)
+	ctx.Request().Header.Set(ClaimsKey, `{"sub":"admin"}`)
+	ctx.Set(ClaimsKey, `{"sub":"admin"}`)
+
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := ctx.BindClaims(&claims); err != ErrNoClaims || claims.Subject != "" {
+		t.Errorf("expect claims from request ignored, got %v %+v", err, claims)
+	}
+
+	ctx.SetValue(ClaimsKey, []byte(`{"sub":"42"}`))
+	if err := ctx.BindClaims(&claims); err != nil || claims.Subject != "42" {
+		t.Errorf("expect claims saved by midware, got %v %+v", err, claims)
+	}
+}
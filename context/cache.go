@@ -0,0 +1,116 @@
+package context
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache allows response cached by clients and shared caches for maxAge
+func (c *Context) Cache(maxAge time.Duration) {
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+}
+
+// NoCache forbids response cached anywhere
+func (c *Context) NoCache() {
+	c.Header("Cache-Control", "no-store, no-cache")
+	c.Header("Pragma", "no-cache")
+}
+
+// SetETag sets ETag header of response, tag is quoted if not, weak tags like W/"v1" are kept
+func (c *Context) SetETag(tag string) {
+	if !strings.HasPrefix(tag, `"`) && !strings.HasPrefix(tag, `W/"`) {
+		tag = `"` + tag + `"`
+	}
+
+	c.Header("ETag", tag)
+}
+
+// SetLastModified sets Last-Modified header of response
+func (c *Context) SetLastModified(t time.Time) {
+	c.Header("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// NotModified checks If-None-Match and If-Modified-Since of request against ETag and Last-Modified
+// of response, if client's cache is still fresh, it responds 304 and returns true, the handler
+// should skip the body then. Only GET and HEAD requests are checked.
+//
+//	ctx.SetETag(version)
+//	if ctx.NotModified() {
+//		return
+//	}
+func (c *Context) NotModified() bool {
+	if c.request.Method != "GET" && c.request.Method != "HEAD" {
+		return false
+	}
+
+	header := c.rw.Header()
+	if match := c.request.Header.Get("If-None-Match"); match != "" {
+		if !etagMatch(match, header.Get("ETag")) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(c.request.Header.Get("If-Modified-Since"))
+		if err != nil {
+			return false
+		}
+
+		modified, err := http.ParseTime(header.Get("Last-Modified"))
+		if err != nil || modified.After(since) {
+			return false
+		}
+	}
+
+	// Entity headers are meaningless for 304
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	c.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// JSONCached writes data as JSON with etag, and responds 304 without body if client has the
+// same version, etag is computed from content of JSON if empty.
+func (c *Context) JSONCached(data interface{}, etag string) error {
+	content, err := marshalJSON(data, false)
+	if err != nil {
+		http.Error(c.rw, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	if etag == "" {
+		sum := sha256.Sum256(content)
+		etag = hex.EncodeToString(sum[:16])
+	}
+
+	c.SetETag(etag)
+	if c.NotModified() {
+		return nil
+	}
+
+	c.Header("Content-Type", WithCharset("application/json"))
+	_, err = c.Write(content)
+	return err
+}
+
+// etagMatch checks If-None-Match list against etag with weak comparison
+func etagMatch(match, etag string) bool {
+	if etag == "" {
+		return false
+	}
+
+	if strings.TrimSpace(match) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(match, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
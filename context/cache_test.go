@@ -0,0 +1,53 @@
+package context
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJSONCached(t *testing.T) {
+	config := map[string]string{"theme": "dark"}
+
+	ctx, rw := newTestContext("GET", "/config")
+	if err := ctx.JSONCached(config, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	etag := rw.Header().Get("ETag")
+	if rw.Code != http.StatusOK || etag == "" || rw.Body.String() != `{"theme":"dark"}` {
+		t.Fatalf("unexpected response %d %s %s", rw.Code, etag, rw.Body.String())
+	}
+
+	ctx, rw = newTestContext("GET", "/config")
+	ctx.Request().Header.Set("If-None-Match", `"other", W/`+etag)
+	ctx.JSONCached(config, "")
+	if rw.Code != http.StatusNotModified || rw.Body.Len() != 0 {
+		t.Errorf("expect 304 without body, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	ctx, rw = newTestContext("GET", "/config")
+	ctx.Request().Header.Set("If-None-Match", `"stale"`)
+	ctx.JSONCached(config, "")
+	if rw.Code != http.StatusOK || rw.Body.Len() == 0 {
+		t.Errorf("expect 200 for stale etag, got %d", rw.Code)
+	}
+}
+
+func TestNotModifiedSince(t *testing.T) {
+	modified := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ctx, rw := newTestContext("GET", "/report")
+	ctx.Request().Header.Set("If-Modified-Since", modified.Add(time.Hour).Format(http.TimeFormat))
+	ctx.SetLastModified(modified)
+	if !ctx.NotModified() || rw.Code != http.StatusNotModified {
+		t.Errorf("expect 304, got %d", rw.Code)
+	}
+
+	ctx, _ = newTestContext("GET", "/report")
+	ctx.Request().Header.Set("If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat))
+	ctx.SetLastModified(modified)
+	if ctx.NotModified() {
+		t.Error("expect modified")
+	}
+}
@@ -12,29 +12,68 @@ package context
 
 import (
 	"bufio"
+	"bytes"
+	gocontext "context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/sign"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var (
-	acceptsHTMLRegex = regexp.MustCompile(`(text/html|application/xhtml\+xml)(?:,|$)`)
-	acceptsXMLRegex  = regexp.MustCompile(`(application/xml|text/xml)(?:,|$)`)
-	acceptsJSONRegex = regexp.MustCompile(`(application/json)(?:,|$)`)
-)
+var jsonpCallbackRegex = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// Key of request id in context and header, it's set by middleware.RequestID
+const RequestIDKey = "X-Request-ID"
 
 type Context struct {
 	rw      http.ResponseWriter
+	writer  responseWriter
 	request *http.Request
 	data    map[string]string
+	params  map[string]string
+	values  map[string]interface{}
 	form    map[string]string
+	rawForm url.Values
+	query   url.Values
 	body    []byte
+	bodyErr error
+	defers  []func()
+	arounds []func(next func())
+	stages  []Stage
+	route   string
+	start   time.Time
+
+	expecting bool
+	aborted   bool
+	// body is not read yet, see ResetHeader
+	pending bool
+	// body is left unread for handler, see KeepRawBody
+	raw bool
+
+	renderer     Renderer
+	renderFuncs  []RenderFunc
+	errorHandler ErrorHandler
+	session      Session
+}
+
+// Stage is a named point of time in request lifecycle, router marks "handler" before handler
+// called and "after" after handler returned
+type Stage struct {
+	Name string
+	Time time.Time
 }
 
 // Return a new Context instance
@@ -46,35 +85,314 @@ func (c *Context) ResponseWriter() http.ResponseWriter {
 	return c.rw
 }
 
+// SetResponseWriter replaces the ResponseWriter, midwares use it to wrap the writer for
+// compressing, buffering or inspecting the response
+func (c *Context) SetResponseWriter(rw http.ResponseWriter) {
+	c.rw = rw
+}
+
 func (c *Context) Request() *http.Request {
 	return c.request
 }
 
 // Initialise Context with HTTP Request and ResponseWriter, it will parse the Request header,
 // and it also parse the get/post/put form parameters. NOTE: The Path Regexp param MUST NOT have
-// same name with HTTP Request form param, otherwise, it will override the HTTP form param
+// same name with HTTP Request form param, otherwise, it will override the HTTP form param.
+// All data of previous request will be cleared, so Context can be reused safely.
 func (c *Context) Reset(w http.ResponseWriter, r *http.Request) {
+	c.ResetHeader(w, r)
+	c.ReadBody()
+}
+
+// ResetHeader initialises Context like Reset, but the body and form are not read until ReadBody
+// called, so router can decide how to read them after route matched, see KeepRawBody.
+func (c *Context) ResetHeader(w http.ResponseWriter, r *http.Request) {
+	c.start = time.Now()
 	c.request = r
-	c.rw = w
+	c.writer.reset(w)
+	c.rw = &c.writer
+	c.body = []byte{}
+	c.bodyErr = nil
+	c.rawForm = url.Values{}
+	c.query = nil
+	c.defers = c.defers[:0]
+	c.arounds = c.arounds[:0]
+	c.stages = c.stages[:0]
+	c.aborted = false
+	c.pending = true
+	c.raw = false
+	c.session = nil
+	c.route = ""
+
+	if c.data == nil {
+		c.data = make(map[string]string)
+	}
+	for k := range c.data {
+		delete(c.data, k)
+	}
+
+	for k := range c.params {
+		delete(c.params, k)
+	}
+
+	for k := range c.values {
+		delete(c.values, k)
+	}
+
+	if c.form == nil {
+		c.form = make(map[string]string)
+	}
+	for k := range c.form {
+		delete(c.form, k)
+	}
 
 	// Parse Request Header
 	for k, v := range c.request.Header {
 		c.Set(k, strings.Join(v, ","))
 	}
 
+	// Client waits for 100 Continue before sending body, so body will not be read until
+	// Continue called, and the request can be rejected without receiving the body
+	c.expecting = strings.EqualFold(c.request.Header.Get("Expect"), "100-continue")
+}
+
+// ReadBody reads body and form of request reset by ResetHeader, it does nothing if they're read
+// already, or the request is waiting for 100 Continue, see Continue.
+func (c *Context) ReadBody() {
+	if c.pending && !c.expecting {
+		c.readBody()
+	}
+}
+
+// KeepRawBody leaves body unread and form unparsed for handler, such as webhooks forwarding the
+// body as is, Body is empty, and only query parameters are available in form. It must be
+// called before ReadBody or Continue.
+func (c *Context) KeepRawBody() {
+	c.raw = true
+}
+
+// FormJoin collapses repeated values of a form field into the single value of Get and Form, all
+// values are still available with QueryArray and RawForm
+type FormJoin func(values []string) string
+
+// JoinFirst keeps the first value, it's the default FormJoin
+func JoinFirst(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// JoinLast keeps the last value
+func JoinLast(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[len(values)-1]
+}
+
+// JoinWith joins values with sep, such as JoinWith(",") for "a,b"
+func JoinWith(sep string) FormJoin {
+	return func(values []string) string {
+		return strings.Join(values, sep)
+	}
+}
+
+// formJoin is set by SetFormJoin, read atomically since requests parse concurrently
+var formJoin atomic.Value
+
+// SetFormJoin sets how repeated values of a form field collapse into the value of Get and Form,
+// it's JoinFirst by default, so "a=1&a=2" gets "1". Values were concatenated without separator
+// before, like "12", use JoinWith("") for that. nil restores the default.
+func SetFormJoin(join FormJoin) {
+	if join == nil {
+		join = JoinFirst
+	}
+
+	formJoin.Store(join)
+}
+
+// setForm flattens values into form and data
+func (c *Context) setForm(values url.Values) {
+	join, ok := formJoin.Load().(FormJoin)
+	if !ok {
+		join = JoinFirst
+	}
+
+	for k, v := range values {
+		value := join(v)
+		c.Set(k, value)
+		c.form[k] = value
+	}
+}
+
+// readBody parses form and reads body
+func (c *Context) readBody() {
+	c.pending = false
+
+	// Raw body is left as a stream, form only has query parameters then
+	if c.raw {
+		c.rawForm = c.request.URL.Query()
+		c.setForm(c.rawForm)
+		return
+	}
+
 	// Parse Request Form
 	c.request.ParseForm()
-	for k, v := range c.request.Form {
-		c.Set(k, strings.Join(v, ""))
-		c.form[k] = strings.Join(v, "")
+	c.rawForm = c.request.Form
+	c.setForm(c.request.Form)
+
+	// Multipart body is left unread, it will be parsed on demand, see MultipartForm
+	if c.isMultipart() {
+		return
 	}
 
 	if c.request.Body != nil {
-		defer c.request.Body.Close()
 		if body, err := ioutil.ReadAll(c.request.Body); err == nil {
 			c.body = body
+		} else {
+			c.bodyErr = err
+		}
+		c.request.Body.Close()
+
+		// Body is replaced with the buffered one, so it can be read again from the start by
+		// handlers, such as httputil.ReverseProxy
+		body := c.body
+		c.request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		c.request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+}
+
+// RequestTrailer returns value of trailer key sent after a chunked request body. Trailers are
+// only available after the body is fully read, Reset reads the body for most requests, but for
+// "Expect: 100-continue" requests, it's only valid after Continue called.
+func (c *Context) RequestTrailer(key string) string {
+	if c.expecting || c.request.Trailer == nil {
+		return ""
+	}
+
+	return c.request.Trailer.Get(key)
+}
+
+// Expecting returns true if client sent "Expect: 100-continue" and the body is not read yet
+func (c *Context) Expecting() bool {
+	return c.expecting
+}
+
+// Continue accepts the body of a "Expect: 100-continue" request, 100 Continue will be sent
+// to client and the body and form will be read. To reject the request, write a status like
+// 417 or 413 instead, then client will not send the body.
+func (c *Context) Continue() {
+	if c.expecting {
+		c.expecting = false
+		c.readBody()
+	}
+}
+
+// Defer registers fn to be called when the request finished, after handler and all midwares,
+// funcs are called in LIFO order like defer statement, even if handler panicked, such as
+// rolling back a transaction or removing a temp file
+func (c *Context) Defer(fn func()) {
+	c.defers = append(c.defers, fn)
+}
+
+// Finish calls all funcs registered by Defer, it's called by router when request finished. They
+// are deferred, so all of them are called even if one panics, and the panic goes on after them.
+func (c *Context) Finish() {
+	defers := c.defers
+	c.defers = c.defers[:0]
+
+	for _, fn := range defers {
+		defer fn()
+	}
+}
+
+// Around wraps handler of the request with fn, fn must call next to run the handler, midwares use
+// it to run code around the handler, such as running it in another goroutine with a deadline. The
+// first one added is the outermost.
+func (c *Context) Around(fn func(next func())) {
+	c.arounds = append(c.arounds, fn)
+}
+
+// Invoke calls handler wrapped by funcs added by Around, it's called by router
+func (c *Context) Invoke(handler func()) {
+	arounds := c.arounds
+	c.arounds = c.arounds[:0]
+
+	for i := len(arounds) - 1; i >= 0; i-- {
+		around, next := arounds[i], handler
+		handler = func() { around(next) }
+	}
+
+	handler()
+}
+
+// StartTime returns time the request started, when context was reset for it
+func (c *Context) StartTime() time.Time {
+	return c.start
+}
+
+// Elapsed returns duration since the request started, see StartTime
+func (c *Context) Elapsed() time.Duration {
+	return time.Since(c.start)
+}
+
+// Mark records current time as stage name
+func (c *Context) Mark(name string) {
+	c.stages = append(c.stages, Stage{Name: name, Time: time.Now()})
+}
+
+// Stage returns the time stage name marked, ok is false if not marked
+func (c *Context) Stage(name string) (time.Time, bool) {
+	for _, stage := range c.stages {
+		if stage.Name == name {
+			return stage.Time, true
 		}
 	}
+
+	return time.Time{}, false
+}
+
+// StdContext returns the standard context of request, which is canceled when client disconnects,
+// pass it to downstream database or http calls to propagate cancellation
+func (c *Context) StdContext() gocontext.Context {
+	return c.request.Context()
+}
+
+// Deadline returns deadline of request context, see context.Context
+func (c *Context) Deadline() (time.Time, bool) {
+	return c.request.Context().Deadline()
+}
+
+// Done returns a channel which is closed when request canceled, see context.Context
+func (c *Context) Done() <-chan struct{} {
+	return c.request.Context().Done()
+}
+
+// Err returns why request context is canceled, see context.Context
+func (c *Context) Err() error {
+	return c.request.Context().Err()
+}
+
+// WithValue attaches key-value to request context, so it can be retrieved from StdContext
+func (c *Context) WithValue(key, value interface{}) {
+	c.request = c.request.WithContext(gocontext.WithValue(c.request.Context(), key, value))
+}
+
+// WithTimeout sets a timeout to request context, cancel should be called to release resources
+func (c *Context) WithTimeout(timeout time.Duration) gocontext.CancelFunc {
+	ctx, cancel := gocontext.WithTimeout(c.request.Context(), timeout)
+	c.request = c.request.WithContext(ctx)
+	return cancel
+}
+
+// RequestID returns id of current request, it's empty if no request id midware used
+func (c *Context) RequestID() string {
+	return c.Get(RequestIDKey)
 }
 
 // Get data from context
@@ -86,6 +404,34 @@ func (c *Context) Get(key string) string {
 	return ""
 }
 
+// MustGet returns value of key like Get, but panics if key not set, it's for values which must
+// be set by midwares, such as id of authenticated user
+func (c *Context) MustGet(key string) string {
+	if v, ok := c.data[key]; ok {
+		return v
+	}
+
+	panic("Context: key " + key + " not set")
+}
+
+// Param returns value of path parameter name, such as id of "/user/:id" or filepath of
+// "/files/*filepath". Unlike Get, query, form and headers of same name are never returned, so
+// it's safe for locating files.
+func (c *Context) Param(name string) string {
+	return c.params[name]
+}
+
+// SetParam sets value of path parameter name, it's called by router when route matched, the
+// value is available by Get as well
+func (c *Context) SetParam(name, value string) {
+	if c.params == nil {
+		c.params = make(map[string]string)
+	}
+
+	c.params[name] = value
+	c.Set(name, value)
+}
+
 // Set data to context
 func (c *Context) Set(key, value string) {
 	if c.data == nil {
@@ -95,14 +441,74 @@ func (c *Context) Set(key, value string) {
 	c.data[key] = value
 }
 
+// SetValue saves v of any type with key, midwares use it to pass objects to handlers, such as
+// authenticated user. Values are separated from the string data of Get and Set.
+func (c *Context) SetValue(key string, v interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+
+	c.values[key] = v
+}
+
+// Value returns value saved by SetValue with key, ok is false if not set
+func (c *Context) Value(key string) (interface{}, bool) {
+	v, ok := c.values[key]
+	return v, ok
+}
+
 func (c *Context) Body() []byte {
 	return c.body
 }
 
+// BodyError returns error of reading body, such as connection lost or body too large, so an
+// empty Body caused by failure can be distinguished from an empty one sent by client
+func (c *Context) BodyError() error {
+	return c.bodyErr
+}
+
 func (c *Context) Form() map[string]string {
 	return c.form
 }
 
+// RawForm returns the parsed form, including URL query and body form, all values of repeated
+// keys are kept, while Form and Get flatten them into a single string
+func (c *Context) RawForm() url.Values {
+	return c.rawForm
+}
+
+// Headers returns all raw values of repeated request header key, such as multiple
+// X-Forwarded-For entries, while Get returns them joined with ","
+func (c *Context) Headers(key string) []string {
+	return c.request.Header[http.CanonicalHeaderKey(key)]
+}
+
+// RawQuery returns the encoded URL query without "?", such as "id=1&tags=a"
+func (c *Context) RawQuery() string {
+	return c.request.URL.RawQuery
+}
+
+// QueryValues returns URL query parsed on first call, later calls return the same values, so
+// only query is parsed without merging into data of Get
+func (c *Context) QueryValues() url.Values {
+	if c.query == nil {
+		c.query = c.request.URL.Query()
+	}
+
+	return c.query
+}
+
+// QueryArray returns all values of key in URL query, such as ["a", "b"] of "tags=a&tags=b"
+func (c *Context) QueryArray(key string) []string {
+	return c.QueryValues()[key]
+}
+
+// FormArray returns all values of key in form, including URL query and body form, values of
+// body form come first
+func (c *Context) FormArray(key string) []string {
+	return c.rawForm[key]
+}
+
 //Request relate method
 
 // Protocol returns request protocol name, such as HTTP/1.1 .
@@ -120,6 +526,14 @@ func (c *Context) URL() string {
 	return c.request.URL.Path
 }
 
+// SetPath rewrites request path, such as mapping "/v1/users" to "/v2/users", routes are matched
+// with the new path if it's called before routing, by Router.PreRoute or global midwares. URI
+// keeps the original one.
+func (c *Context) SetPath(path string) {
+	c.request.URL.Path = path
+	c.request.URL.RawPath = ""
+}
+
 // Scheme returns Request scheme, "http" or "https"
 func (c *Context) Scheme() string {
 	if scheme := c.request.Header.Get("X-Forwarded-Proto"); scheme != "" {
@@ -140,11 +554,7 @@ func (c *Context) Scheme() string {
 // Host returns request host name, if no host info in requst, "localhost" will be returned
 func (c *Context) Host() string {
 	if c.request.Host != "" {
-		hostParts := strings.Split(c.request.Host, ":")
-		if len(hostParts) > 0 {
-			return hostParts[0]
-		}
-		return c.request.Host
+		return stripPort(c.request.Host)
 	}
 	return "localhost"
 }
@@ -164,18 +574,76 @@ func (c *Context) Site() string {
 	return c.Scheme() + "://" + c.Host()
 }
 
+// FullURL returns absolute url of request as scheme://host[:port]/path?query, such as
+// https://api.example.com:8443/v1/users?active=true, port is omitted if it's default of scheme
+func (c *Context) FullURL() string {
+	host := c.request.Host
+	if host == "" {
+		host = "localhost"
+	}
+
+	scheme := c.Scheme()
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+			host = strings.TrimSuffix(host, ":"+port)
+		}
+	}
+
+	// RequestURI of proxy requests is absolute already
+	uri := c.URI()
+	if !strings.HasPrefix(uri, "/") {
+		uri = c.request.URL.RequestURI()
+	}
+
+	return scheme + "://" + host + uri
+}
+
 // Domain returns host name, alias of Host
 func (c *Context) Domain() string {
 	return c.Host()
 }
 
-// SubDomain returns sub domain string, like api.raythorn.com will return api
+// domainSuffixes are public suffixes with more than one label registered by RegisterDomainSuffix
+var domainSuffixes = struct {
+	sync.RWMutex
+	suffixes map[string]bool
+}{suffixes: make(map[string]bool)}
+
+// RegisterDomainSuffix registers public suffixes with more than one label, such as "co.uk", so
+// SubDomain of api.example.co.uk returns api instead of api.example
+func RegisterDomainSuffix(suffixes ...string) {
+	domainSuffixes.Lock()
+	for _, suffix := range suffixes {
+		domainSuffixes.suffixes[strings.Trim(strings.ToLower(suffix), ".")] = true
+	}
+	domainSuffixes.Unlock()
+}
+
+// SubDomain returns sub domain string, like api.raythorn.com will return api, and a.b.raythorn.com
+// will return a.b. The registrable domain is assumed to be the last two labels, unless its suffix
+// registered by RegisterDomainSuffix. It returns "" if no sub domain or host is an ip.
 func (c *Context) SubDomain() string {
-	parts := strings.Split(c.Host(), ".")
-	if len(parts) >= 3 {
-		return strings.Join(parts[:len(parts)-2], ".")
+	host := strings.TrimSuffix(strings.ToLower(c.Host()), ".")
+	if net.ParseIP(host) != nil {
+		return ""
 	}
-	return ""
+
+	labels := strings.Split(host, ".")
+	registrable := 2
+
+	domainSuffixes.RLock()
+	for suffix := range domainSuffixes.suffixes {
+		if n := strings.Count(suffix, ".") + 2; n > registrable && strings.HasSuffix(host, "."+suffix) {
+			registrable = n
+		}
+	}
+	domainSuffixes.RUnlock()
+
+	if len(labels) <= registrable {
+		return ""
+	}
+
+	return strings.Join(labels[:len(labels)-registrable], ".")
 }
 
 // Method returns requst method
@@ -183,6 +651,17 @@ func (c *Context) Method() string {
 	return c.request.Method
 }
 
+// SetRoute sets the registered pattern of route matched, it's called by router
+func (c *Context) SetRoute(pattern string) {
+	c.route = pattern
+}
+
+// Route returns the registered pattern of route matched, such as "/users/:id" for "/users/42",
+// it's "" if no route matched
+func (c *Context) Route() string {
+	return c.route
+}
+
 // RemoteAddr returns reomte address in request
 func (c *Context) RemoteAddr() string {
 	return c.request.RemoteAddr
@@ -198,48 +677,106 @@ func (c *Context) UserAgent() string {
 	return c.request.Header.Get("User-Agent")
 }
 
-// Proxy returns proxy client ips slice.
+// Proxy returns client and proxy ips in X-Forwarded-For, spaces and ports of entries are
+// stripped, empty entries are skipped.
 func (c *Context) Proxy() []string {
-	if ips := c.Get("X-Forwarded-For"); ips != "" {
-		return strings.Split(ips, ",")
+	ips := []string{}
+	for _, ip := range strings.Split(c.Get("X-Forwarded-For"), ",") {
+		if ip = stripPort(strings.TrimSpace(ip)); ip != "" {
+			ips = append(ips, ip)
+		}
 	}
 
-	return []string{}
+	return ips
+}
+
+// stripPort removes port of addr, such as "10.0.0.1:80" or "[::1]:80", IPv6 address without
+// port is kept as is
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+// ForwardHeaders copies request headers of keys into dst, multi-values are preserved. It's
+// used by gateways to pass through auth/trace headers to upstreams.
+func (c *Context) ForwardHeaders(dst http.Header, keys ...string) {
+	for _, key := range keys {
+		values, ok := c.request.Header[http.CanonicalHeaderKey(key)]
+		if !ok {
+			continue
+		}
+
+		key = http.CanonicalHeaderKey(key)
+		dst.Del(key)
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
 }
 
 // IP returns request client ip.
 // if in proxy, return first proxy id.
 // if error, return 127.0.0.1.
 func (c *Context) Ip() string {
-	ips := c.Proxy()
-	if len(ips) > 0 && ips[0] != "" {
-		ip := strings.Split(ips[0], ":")
-		return ip[0]
+	if ips := c.Proxy(); len(ips) > 0 {
+		return ips[0]
 	}
 
-	ip := strings.Split(c.RemoteAddr(), ":")
-	if len(ip) > 0 {
-		if ip[0] != "[" {
-			return ip[0]
-		}
+	if ip := stripPort(c.RemoteAddr()); ip != "" {
+		return ip
 	}
 
-	return "127.0.01"
+	return "127.0.0.1"
 }
 
-// AcceptsHTML Checks if request accepts html response
+// VerifySignedRequest checks the query of request is signed with secret for current path and
+// not expired, signed URLs are built with sign.BuildURL
+func (c *Context) VerifySignedRequest(secret []byte) error {
+	return sign.VerifyURL(c.URL(), c.QueryValues(), secret)
+}
+
+// AcceptsHTML Checks if request accepts html response, it's true only if text/html or
+// application/xhtml+xml listed explicitly in Accept header, use Accepts for negotiation
 func (c *Context) AcceptsHTML() bool {
-	return acceptsHTMLRegex.MatchString(c.Get("Accept"))
+	return c.acceptsExplicit("text/html", "application/xhtml+xml")
 }
 
-// AcceptsXML Checks if request accepts xml response
+// AcceptsXML Checks if request accepts xml response, wildcards not count as AcceptsHTML
 func (c *Context) AcceptsXML() bool {
-	return acceptsXMLRegex.MatchString(c.Get("Accept"))
+	return c.acceptsExplicit("application/xml", "text/xml")
 }
 
-// AcceptsJSON Checks if request accepts json response
+// AcceptsJSON Checks if request accepts json response, wildcards not count as AcceptsHTML
 func (c *Context) AcceptsJSON() bool {
-	return acceptsJSONRegex.MatchString(c.Get("Accept"))
+	return c.acceptsExplicit("application/json")
+}
+
+// IsAjax checks if request sent by XMLHttpRequest with "X-Requested-With: XMLHttpRequest", such as
+// by jQuery, handlers can respond JSON instead of redirect. The header is set by client and can be
+// forged, so it's not a security boundary. Note that fetch doesn't set it by default.
+func (c *Context) IsAjax() bool {
+	return c.request.Header.Get("X-Requested-With") == "XMLHttpRequest"
+}
+
+// IsWebSocket checks if request is a WebSocket handshake, with "Connection: Upgrade" and
+// "Upgrade: websocket" headers
+func (c *Context) IsWebSocket() bool {
+	if !strings.EqualFold(c.request.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+
+	for _, value := range c.request.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 //ResponseWriter relate method
@@ -249,7 +786,7 @@ func (c *Context) Header(key, value string) {
 	c.rw.Header().Set(key, value)
 }
 
-// Set response header with a http code
+// Set response header with a http code, only the first status code will be sent
 func (c *Context) WriteHeader(code int) {
 	c.rw.WriteHeader(code)
 }
@@ -264,6 +801,17 @@ func (c *Context) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return hijack.Hijack()
 }
 
+// Push initiates a HTTP/2 server push of target, such as "/static/app.css", it should be called
+// before response written. http.ErrNotSupported is returned if push is not supported, such as
+// over HTTP/1.1. Writers wrapped by midwares like Gzip are skipped since push doesn't touch body.
+func (c *Context) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := c.rw.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+
+	return c.writer.Push(target, opts)
+}
+
 // Write all the data in cache to http.ResponseWriter
 func (c *Context) Flush() {
 	if f, ok := c.rw.(http.Flusher); ok {
@@ -271,7 +819,46 @@ func (c *Context) Flush() {
 	}
 }
 
+// Stream calls step repeatedly to write body in chunks, the response is flushed after each call.
+// It stops when step returns false or client disconnects, and returns true if client disconnected.
+// Content-Length is not set, so body is sent with chunked transfer encoding.
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	c.rw.Header().Del("Content-Length")
+
+	done := c.Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+		}
+
+		more := step(c.rw)
+		c.Flush()
+		if !more {
+			return false
+		}
+	}
+}
+
+// IsClientGone checks if client disconnected, the request context is canceled or a write to the
+// response failed. Long running handlers, like long polling, can check it to stop early.
+func (c *Context) IsClientGone() bool {
+	if c.writer.error() != nil {
+		return true
+	}
+
+	select {
+	case <-c.Done():
+		return true
+	default:
+		return false
+	}
+}
+
 // CloseNotity notify if connection closed
+//
+// Deprecated: use Done, which is closed when client disconnects
 func (c *Context) CloseNotify() <-chan bool {
 	if cn, ok := c.rw.(http.CloseNotifier); ok {
 		return cn.CloseNotify()
@@ -280,7 +867,8 @@ func (c *Context) CloseNotify() <-chan bool {
 	return nil
 }
 
-// WriteString write a string data to client
+// WriteString write a string data to client, the error should be checked by long running
+// handlers, it's usually caused by client disconnected, see IsClientGone
 func (c *Context) WriteString(data string) error {
 	_, err := c.rw.Write([]byte(data))
 
@@ -292,44 +880,208 @@ func (c *Context) Write(bytes []byte) (int, error) {
 	return c.rw.Write(bytes)
 }
 
-// Intercept write data with http status code, and current session will be finished
+// Intercept write data with http status code, and current session will be finished, see Abort.
+// reason is logged for debugging. If response already committed by the handler, status and data
+// are not written to avoid a malformed response, and a warning is logged instead.
 func (c *Context) Intercept(data []byte, code int, reason string) error {
+	c.aborted = true
+	if c.committed("Intercept", code) {
+		c.Flush()
+		log.Debug("Intercept: %s", reason)
+		return nil
+	}
+
 	c.WriteHeader(code)
-	c.Write(data)
+	_, err := c.Write(data)
 	c.Flush()
-	panic(reason)
+	log.Debug("Intercept: %s", reason)
+
+	return err
+}
+
+// Abort responds with http status code, and stops router running subsequent midwares and
+// handler, the current midware or handler should return itself. code 0 writes nothing, and
+// code is ignored with a warning if response already committed.
+func (c *Context) Abort(code int) {
+	if code > 0 && !c.committed("Abort", code) {
+		c.WriteHeader(code)
+	}
+	c.aborted = true
+}
+
+// AbortWithJSON responds data as JSON with http status code, and aborts as Abort, data is not
+// written if response already committed
+func (c *Context) AbortWithJSON(code int, data interface{}) error {
+	c.aborted = true
+	if c.committed("AbortWithJSON", code) {
+		return nil
+	}
+
+	return c.JSONStatus(code, data, false)
+}
+
+// committed checks if status of response already written, a warning is logged for caller trying
+// to respond code if so
+func (c *Context) committed(caller string, code int) bool {
+	if !c.Written() {
+		return false
+	}
+
+	log.Warning("%s: response already committed with status %d, %d not written", caller, c.Status(), code)
+	return true
+}
+
+// IsAborted returns true if Abort, AbortWithJSON or Intercept called
+func (c *Context) IsAborted() bool {
+	return c.aborted
+}
+
+// Data writes b with content type and http status code in one call
+func (c *Context) Data(code int, contentType string, b []byte) error {
+	c.Header("Content-Type", contentType)
+	c.SetContentLength(int64(len(b)))
+	c.WriteHeader(code)
+	_, err := c.Write(b)
+
+	return err
+}
+
+// Text writes s with http status code and Content-Type text/plain, so the type is not sniffed
+// from content like WriteString
+func (c *Context) Text(code int, s string) error {
+	return c.Data(code, WithCharset("text/plain"), []byte(s))
+}
+
+// HTMLString writes s with http status code and Content-Type text/html, use Render or HTML for
+// templates, s is written as is and must be escaped by caller
+func (c *Context) HTMLString(code int, s string) error {
+	return c.Data(code, WithCharset("text/html"), []byte(s))
+}
+
+// SetContentLength sets Content-Length header, so the body is not sent with chunked transfer
+// encoding, it must be called before status written. JSON, XML and Data set it automatically,
+// midwares which change the body, like Gzip, clear it.
+func (c *Context) SetContentLength(n int64) {
+	c.Header("Content-Length", strconv.FormatInt(n, 10))
 }
 
 // JSON write json-like data to client
 func (c *Context) JSON(data interface{}, indent bool) error {
+	return c.JSONStatus(0, data, indent)
+}
 
-	var err error
-	var content []byte
+// JSONStatus writes json-like data with http status code, Content-Type is set before status
+// written. 0 code writes no status, and 200 will be sent by default.
+func (c *Context) JSONStatus(code int, data interface{}, indent bool) error {
 
-	c.Header("Content-Type", "application/json; charset=utf-8")
-	if indent {
-		content, err = json.MarshalIndent(data, "", "  ")
-	} else {
-		content, err = json.Marshal(data)
+	c.Header("Content-Type", WithCharset("application/json"))
+	content, err := marshalJSON(data, indent)
+	if err != nil {
+		http.Error(c.rw, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	c.SetContentLength(int64(len(content)))
+	if code > 0 {
+		c.WriteHeader(code)
+	}
+	_, err = c.Write(content)
+
+	return err
+}
+
+// JSONP writes data as JSON wrapped in callback, like callback({...}), callback must be a valid
+// JavaScript identifier or a dot separated path of identifiers, such as "jQuery.cb", otherwise
+// an error returned and nothing written.
+func (c *Context) JSONP(callback string, data interface{}) error {
+	if !jsonpCallbackRegex.MatchString(callback) {
+		return errors.New("JSONP: invalid callback " + strconv.Quote(callback))
 	}
 
+	content, err := marshalJSON(data, false)
 	if err != nil {
 		http.Error(c.rw, err.Error(), http.StatusInternalServerError)
 		return err
 	}
 
+	c.Header("Content-Type", WithCharset("application/javascript"))
+	c.Header("X-Content-Type-Options", "nosniff")
+	// The leading comment prevents content sniffing attacks like Rosetta Flash
+	c.WriteString("/**/" + callback + "(")
 	c.Write(content)
+	c.WriteString(");")
 
 	return nil
 }
 
+// JSONStream writes data as JSON with chunked transfer encoding instead of marshalling it in one
+// buffer, slices and arrays are encoded element by element, so peak memory of large lists is one
+// element. Status is committed once the first element written, if encoding fails later, the error
+// is logged and returned, and the response is truncated.
+func (c *Context) JSONStream(data interface{}) error {
+	c.Header("Content-Type", WithCharset("application/json"))
+	c.rw.Header().Del("Content-Length")
+
+	value := reflect.ValueOf(data)
+	if (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) || (value.Kind() == reflect.Slice && value.IsNil()) {
+		return c.streamJSON(data)
+	}
+
+	if err := c.WriteString("["); err != nil {
+		return err
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			if err := c.WriteString(","); err != nil {
+				return err
+			}
+		}
+
+		if err := c.streamJSON(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+
+	return c.WriteString("]")
+}
+
+// streamJSON encodes data to response, 500 is responded if failed before status committed
+func (c *Context) streamJSON(data interface{}) error {
+	err := json.NewEncoder(c.rw).Encode(data)
+	if err == nil {
+		return nil
+	}
+
+	if c.Written() {
+		log.Error("JSONStream: encode failed after response committed, %s", err)
+	} else {
+		http.Error(c.rw, err.Error(), http.StatusInternalServerError)
+	}
+
+	return err
+}
+
+func marshalJSON(data interface{}, indent bool) ([]byte, error) {
+	if indent {
+		return json.MarshalIndent(data, "", "  ")
+	}
+
+	return json.Marshal(data)
+}
+
 // XML write xml-like data to client
 func (c *Context) XML(data interface{}, indent bool) error {
+	return c.XMLStatus(0, data, indent)
+}
+
+// XMLStatus writes xml-like data with http status code, same as JSONStatus
+func (c *Context) XMLStatus(code int, data interface{}, indent bool) error {
 
 	var err error
 	var content []byte
 
-	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Header("Content-Type", WithCharset("application/xml"))
 	if indent {
 		content, err = xml.MarshalIndent(data, "", "  ")
 	} else {
@@ -341,9 +1093,38 @@ func (c *Context) XML(data interface{}, indent bool) error {
 		return err
 	}
 
-	c.Write(content)
+	c.SetContentLength(int64(len(content)))
+	if code > 0 {
+		c.WriteHeader(code)
+	}
+	_, err = c.Write(content)
 
-	return nil
+	return err
+}
+
+// Redirect replies to the request with a redirect to url with code
+func (c *Context) Redirect(code int, url string) {
+	http.Redirect(c.rw, c.request, url, code)
+}
+
+// RedirectPermanent redirects with 301 for GET/HEAD, and 308 for other methods, which keeps
+// the method and body of request
+func (c *Context) RedirectPermanent(url string) {
+	if c.Method() == "GET" || c.Method() == "HEAD" {
+		c.Redirect(http.StatusMovedPermanently, url)
+	} else {
+		c.Redirect(http.StatusPermanentRedirect, url)
+	}
+}
+
+// RedirectTemporary redirects with 302 for GET/HEAD, and 307 for other methods, which keeps
+// the method and body of request
+func (c *Context) RedirectTemporary(url string) {
+	if c.Method() == "GET" || c.Method() == "HEAD" {
+		c.Redirect(http.StatusFound, url)
+	} else {
+		c.Redirect(http.StatusTemporaryRedirect, url)
+	}
 }
 
 func (c *Context) NotFound() {
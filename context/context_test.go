@@ -0,0 +1,805 @@
+package context
+
+import (
+	"bufio"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"github.com/raythorn/zebra/sign"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForwardHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("X-Trace", "a")
+	req.Header.Add("X-Trace", "b")
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "secret=1")
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+
+	dst := http.Header{}
+	ctx.ForwardHeaders(dst, "x-trace", "Authorization", "X-Missing")
+
+	if values := dst["X-Trace"]; len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Errorf("multi-value header not forwarded intact: %v", values)
+	}
+
+	if dst.Get("Authorization") != "Bearer token" {
+		t.Errorf("authorization not forwarded")
+	}
+
+	if _, ok := dst["Cookie"]; ok {
+		t.Errorf("unselected header forwarded")
+	}
+
+	if _, ok := dst["X-Missing"]; ok {
+		t.Errorf("absent header should not be set")
+	}
+}
+
+func TestVerifySignedRequest(t *testing.T) {
+	secret := []byte("secret")
+	values := url.Values{"user": {"42"}}
+
+	cases := []struct {
+		name   string
+		target string
+		err    error
+	}{
+		{"valid", sign.BuildURL("/unsubscribe", values, secret, time.Hour), nil},
+		{"expired", sign.BuildURL("/unsubscribe", values, secret, -time.Hour), sign.ErrExpired},
+		{"tampered", strings.Replace(sign.BuildURL("/unsubscribe", values, secret, time.Hour), "user=42", "user=43", 1), sign.ErrSignature},
+		{"other path", strings.Replace(sign.BuildURL("/unsubscribe", values, secret, time.Hour), "/unsubscribe", "/delete", 1), sign.ErrSignature},
+		{"unsigned", "/unsubscribe?user=42", sign.ErrMissing},
+	}
+
+	for _, c := range cases {
+		ctx, _ := newTestContext("GET", c.target)
+		if err := ctx.VerifySignedRequest(secret); err != c.err {
+			t.Errorf("%s: expect %v, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	cases := []struct {
+		method    string
+		permanent bool
+		code      int
+	}{
+		{"GET", true, http.StatusMovedPermanently},
+		{"POST", true, http.StatusPermanentRedirect},
+		{"GET", false, http.StatusFound},
+		{"POST", false, http.StatusTemporaryRedirect},
+	}
+
+	for _, c := range cases {
+		ctx, rw := newTestContext(c.method, "/old")
+		if c.permanent {
+			ctx.RedirectPermanent("/new")
+		} else {
+			ctx.RedirectTemporary("/new")
+		}
+
+		if rw.Code != c.code || rw.Header().Get("Location") != "/new" {
+			t.Errorf("%s permanent=%v: expect %d, got %d %s", c.method, c.permanent, c.code, rw.Code, rw.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRawForm(t *testing.T) {
+	ctx, _ := newBodyContext("POST", "/items?tag=a&tag=b", "application/x-www-form-urlencoded", "tag=c&color=red&color=blue")
+
+	form := ctx.RawForm()
+	if tags := form["tag"]; len(tags) != 3 || tags[0] != "c" || tags[1] != "a" || tags[2] != "b" {
+		t.Errorf("repeated query keys not recoverable: %v", tags)
+	}
+
+	if colors := form["color"]; len(colors) != 2 || colors[0] != "red" || colors[1] != "blue" {
+		t.Errorf("repeated form keys not recoverable: %v", colors)
+	}
+}
+
+func TestRequestTrailer(t *testing.T) {
+	raw := "POST /upload HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\nTrailer: X-Checksum\r\n\r\n" +
+		"5\r\nzebra\r\n0\r\nX-Checksum: abc123\r\n\r\n"
+
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+
+	if string(ctx.Body()) != "zebra" {
+		t.Errorf("unexpected body %s", ctx.Body())
+	}
+
+	if checksum := ctx.RequestTrailer("X-Checksum"); checksum != "abc123" {
+		t.Errorf("expect trailer abc123, got %q", checksum)
+	}
+}
+
+func TestTrailer(t *testing.T) {
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+
+	if err := ctx.Trailer("x-checksum"); err != nil {
+		t.Fatal(err)
+	}
+	ctx.WriteString("zebra")
+	if err := ctx.Trailer("X-Late"); err == nil {
+		t.Error("expect error declaring trailer after body written")
+	}
+	ctx.SetTrailer("X-Checksum", "abc")
+	ctx.SetTrailer("X-Count", "5")
+
+	res := rw.Result()
+	if res.Header.Get("Trailer") != "X-Checksum" || res.Header.Get("X-Checksum") != "" {
+		t.Errorf("unexpected headers %v", res.Header)
+	}
+	if res.Trailer.Get("X-Checksum") != "abc" || res.Trailer.Get("X-Count") != "5" {
+		t.Errorf("unexpected trailers %v", res.Trailer)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.0", 1, 0
+	ctx.Reset(httptest.NewRecorder(), req)
+	if err := ctx.SetTrailer("X-Checksum", "abc"); err == nil {
+		t.Error("expect error for HTTP/1.0")
+	}
+}
+
+func TestQueryArray(t *testing.T) {
+	ctx, _ := newBodyContext("POST", "/items?tags=a&tags=b&page=1", "application/x-www-form-urlencoded", "color=red&color=blue&tags=c")
+
+	if tags := ctx.QueryArray("tags"); !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Errorf("unexpected query tags %v", tags)
+	}
+
+	if colors := ctx.FormArray("color"); !reflect.DeepEqual(colors, []string{"red", "blue"}) {
+		t.Errorf("unexpected form colors %v", colors)
+	}
+
+	if tags := ctx.FormArray("tags"); !reflect.DeepEqual(tags, []string{"c", "a", "b"}) {
+		t.Errorf("unexpected form tags %v", tags)
+	}
+
+	if missing := ctx.QueryArray("missing"); len(missing) != 0 {
+		t.Errorf("expect no values, got %v", missing)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("X-Forwarded-For", "10.0.0.1")
+	req.Header.Add("X-Forwarded-For", "10.0.0.2, 10.0.0.3")
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+
+	if values := ctx.Headers("x-forwarded-for"); !reflect.DeepEqual(values, []string{"10.0.0.1", "10.0.0.2, 10.0.0.3"}) {
+		t.Errorf("unexpected header values %v", values)
+	}
+
+	if joined := ctx.Get("X-Forwarded-For"); joined != "10.0.0.1,10.0.0.2, 10.0.0.3" {
+		t.Errorf("unexpected joined header %q", joined)
+	}
+}
+
+func TestJSONP(t *testing.T) {
+	ctx, rw := newTestContext("GET", "/users?callback=app.render")
+	if err := ctx.JSONP("app.render", map[string]string{"name": "</script>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rw.Header().Get("Content-Type") != "application/javascript; charset=utf-8" {
+		t.Errorf("unexpected content type %s", rw.Header().Get("Content-Type"))
+	}
+
+	if body := rw.Body.String(); body != `/**/app.render({"name":"\u003c/script\u003e"});` {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	for _, callback := range []string{"", "alert(1)//", "a.b-c", "1abc", "cb;evil", "a..b"} {
+		ctx, rw = newTestContext("GET", "/users")
+		if err := ctx.JSONP(callback, "data"); err == nil {
+			t.Errorf("expect error for callback %q", callback)
+		}
+		if rw.Body.Len() != 0 {
+			t.Errorf("expect nothing written for callback %q, got %s", callback, rw.Body.String())
+		}
+	}
+}
+
+func TestData(t *testing.T) {
+	ctx, rw := newTestContext("GET", "/avatar.png")
+	if err := ctx.Data(http.StatusCreated, "image/png", []byte{0x89, 'P', 'N', 'G'}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rw.Code != http.StatusCreated || rw.Header().Get("Content-Type") != "image/png" || rw.Body.String() != "\x89PNG" {
+		t.Errorf("unexpected response %d %s %q", rw.Code, rw.Header().Get("Content-Type"), rw.Body.String())
+	}
+}
+
+func TestJSONStatus(t *testing.T) {
+	ctx, rw := newTestContext("POST", "/users")
+	if err := ctx.JSONStatus(http.StatusCreated, map[string]int{"id": 1}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if rw.Code != http.StatusCreated || rw.Header().Get("Content-Type") != "application/json; charset=utf-8" || rw.Body.String() != `{"id":1}` {
+		t.Errorf("unexpected JSON response %d %s %s", rw.Code, rw.Header().Get("Content-Type"), rw.Body.String())
+	}
+
+	type user struct {
+		ID int `xml:"id,attr"`
+	}
+
+	ctx, rw = newTestContext("POST", "/users")
+	if err := ctx.XMLStatus(http.StatusUnprocessableEntity, user{ID: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if rw.Code != http.StatusUnprocessableEntity || rw.Header().Get("Content-Type") != "application/xml; charset=utf-8" || rw.Body.String() != `<user id="1"></user>` {
+		t.Errorf("unexpected XML response %d %s %s", rw.Code, rw.Header().Get("Content-Type"), rw.Body.String())
+	}
+}
+
+func TestError(t *testing.T) {
+	ctx, rw := newTestContext("GET", "/users/1")
+	ctx.Error(http.StatusNotFound, "user not found")
+
+	if rw.Code != http.StatusNotFound || rw.Header().Get("Content-Type") != "application/json; charset=utf-8" ||
+		rw.Body.String() != `{"error":{"code":404,"message":"user not found"}}` {
+		t.Errorf("unexpected response %d %s %s", rw.Code, rw.Header().Get("Content-Type"), rw.Body.String())
+	}
+
+	ctx, rw = newTestContext("POST", "/users")
+	ctx.ErrorWith(http.StatusUnprocessableEntity, "invalid user", map[string]string{"email": "required"})
+
+	if rw.Code != http.StatusUnprocessableEntity ||
+		rw.Body.String() != `{"error":{"code":422,"message":"invalid user","details":{"email":"required"}}}` {
+		t.Errorf("unexpected response %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestWriteHeaderOnce(t *testing.T) {
+	ctx, rw := newTestContext("POST", "/users")
+	ctx.WriteHeader(http.StatusCreated)
+	ctx.WriteHeader(http.StatusInternalServerError)
+	ctx.WriteString("created")
+
+	if rw.Code != http.StatusCreated || ctx.Status() != http.StatusCreated || ctx.Size() != 7 {
+		t.Errorf("expect first status kept, got %d %d %d", rw.Code, ctx.Status(), ctx.Size())
+	}
+
+	ctx, rw = newTestContext("GET", "/users")
+	ctx.WriteString("users")
+	ctx.WriteHeader(http.StatusNotFound)
+
+	if rw.Code != http.StatusOK || ctx.Status() != http.StatusOK {
+		t.Errorf("expect implicit 200 on write, got %d %d", rw.Code, ctx.Status())
+	}
+}
+
+func TestWritten(t *testing.T) {
+	ctx, _ := newTestContext("GET", "/users")
+	ctx.Header("X-Total", "0")
+	if ctx.Written() {
+		t.Error("expect not written before status or body")
+	}
+
+	ctx.WriteString("users")
+	if !ctx.Written() {
+		t.Error("expect written after body")
+	}
+}
+
+func TestBodyRereadable(t *testing.T) {
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("zebra")))
+
+	for i := 0; i < 2; i++ {
+		body, err := ioutil.ReadAll(ctx.Request().Body)
+		if err != nil || string(body) != "zebra" {
+			t.Errorf("read %d: expect zebra, got %q %v", i, body, err)
+		}
+		ctx.Request().Body, _ = ctx.Request().GetBody()
+	}
+
+	if string(ctx.Body()) != "zebra" {
+		t.Errorf("expect Body zebra, got %q", ctx.Body())
+	}
+}
+
+func TestStream(t *testing.T) {
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/export.csv", nil))
+
+	rows := 0
+	gone := ctx.Stream(func(w io.Writer) bool {
+		rows++
+		fmt.Fprintf(w, "%d,zebra\n", rows)
+		return rows < 3
+	})
+	if gone || rw.Body.String() != "1,zebra\n2,zebra\n3,zebra\n" || !rw.Flushed {
+		t.Errorf("unexpected stream %v %q %v", gone, rw.Body.String(), rw.Flushed)
+	}
+
+	req := httptest.NewRequest("GET", "/export.csv", nil)
+	c, stop := gocontext.WithCancel(req.Context())
+	ctx.Reset(httptest.NewRecorder(), req.WithContext(c))
+	rows = 0
+	gone = ctx.Stream(func(w io.Writer) bool {
+		rows++
+		if rows == 2 {
+			stop()
+		}
+		return true
+	})
+	if !gone || rows != 2 {
+		t.Errorf("expect stopped after client gone, got %v %d", gone, rows)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	req := httptest.NewRequest("POST", "/users/1?tag=a&tag=b", strings.NewReader("name=zebra"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	ctx.Set("id", "1")
+	ctx.SetRoute("/users/:id")
+	ctx.Header("X-Trace", "1")
+
+	cp := ctx.Copy()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if cp.Get("id") != "1" || cp.Form()["name"] != "zebra" || !reflect.DeepEqual(cp.FormArray("tag"), []string{"a", "b"}) ||
+		cp.Route() != "/users/:id" || cp.ResponseWriter().Header().Get("X-Trace") != "1" {
+		t.Errorf("unexpected copy %v %v %s", cp.data, cp.form, cp.Route())
+	}
+
+	if ctx.Get("id") != "" {
+		t.Error("expect original reset")
+	}
+
+	defer func() {
+		if recover() != ErrCopiedWrite {
+			t.Error("expect panic writing a copy")
+		}
+	}()
+	cp.WriteString("late")
+}
+
+func TestMustGet(t *testing.T) {
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	ctx.Set("user_id", "")
+
+	if ctx.MustGet("user_id") != "" {
+		t.Error("expect empty value of key set")
+	}
+
+	defer func() {
+		if r := recover(); r != "Context: key token not set" {
+			t.Errorf("unexpected panic %v", r)
+		}
+	}()
+	ctx.MustGet("token")
+}
+
+func TestValue(t *testing.T) {
+	type user struct{ ID int }
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if _, ok := ctx.Value("user"); ok {
+		t.Error("expect no value")
+	}
+
+	ctx.SetValue("user", &user{ID: 42})
+	if v, ok := ctx.Value("user"); !ok || v.(*user).ID != 42 {
+		t.Errorf("unexpected value %v", v)
+	}
+	if ctx.Get("user") != "" {
+		t.Error("expect values separated from data")
+	}
+	if v, ok := ctx.Copy().Value("user"); !ok || v.(*user).ID != 42 {
+		t.Errorf("expect value copied, got %v", v)
+	}
+
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if _, ok := ctx.Value("user"); ok {
+		t.Error("expect values cleared by Reset")
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.JSON(map[string]string{"name": "zebra"}, false)
+	if rw.Header().Get("Content-Length") != "16" || rw.Body.Len() != 16 {
+		t.Errorf("unexpected Content-Length %s of %q", rw.Header().Get("Content-Length"), rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.Data(http.StatusCreated, "text/csv", []byte("a,b\n"))
+	if rw.Header().Get("Content-Length") != "4" || rw.Code != http.StatusCreated {
+		t.Errorf("unexpected Content-Length %s", rw.Header().Get("Content-Length"))
+	}
+}
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (p *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err := ctx.Push("/app.css", nil); err != http.ErrNotSupported {
+		t.Errorf("expect ErrNotSupported, got %v", err)
+	}
+
+	rw := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.SetResponseWriter(struct{ http.ResponseWriter }{ctx.ResponseWriter()})
+	if err := ctx.Push("/app.css", nil); err != nil || len(rw.pushed) != 1 || rw.pushed[0] != "/app.css" {
+		t.Errorf("expect pushed through wrapped writer, got %v %v", err, rw.pushed)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	ctx := New()
+	for header, expect := range map[string][]string{
+		" 10.0.0.1 , 10.0.0.2:8080,[2001:db8::1]:443, 2001:db8::2": {"10.0.0.1", "10.0.0.2", "2001:db8::1", "2001:db8::2"},
+		"  ": {},
+		"":   {},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", header)
+		ctx.Reset(httptest.NewRecorder(), req)
+		if ips := ctx.Proxy(); !reflect.DeepEqual(ips, expect) {
+			t.Errorf("%q: expect %v, got %v", header, expect, ips)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", " 10.0.0.1:1234, 10.0.0.2")
+	ctx.Reset(httptest.NewRecorder(), req)
+	if ctx.Ip() != "10.0.0.1" {
+		t.Errorf("expect client ip 10.0.0.1, got %s", ctx.Ip())
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[::1]:5678"
+	ctx.Reset(httptest.NewRecorder(), req)
+	if ctx.Ip() != "::1" {
+		t.Errorf("expect remote ip ::1, got %s", ctx.Ip())
+	}
+}
+
+func TestSubDomain(t *testing.T) {
+	RegisterDomainSuffix("co.uk")
+
+	ctx := New()
+	for host, expect := range map[string]string{
+		"example.com":          "",
+		"api.example.com":      "api",
+		"a.b.example.com:8080": "a.b",
+		"api.example.co.uk":    "api",
+		"example.co.uk":        "",
+		"localhost:8080":       "",
+		"192.168.1.10:8080":    "",
+		"[::1]:8080":           "",
+		"API.Example.com.":     "api",
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		ctx.Reset(httptest.NewRecorder(), req)
+		if sub := ctx.SubDomain(); sub != expect {
+			t.Errorf("%s: expect %q, got %q", host, expect, sub)
+		}
+	}
+}
+
+func TestFullURL(t *testing.T) {
+	cases := []struct {
+		target, host, proto, url string
+	}{
+		{"/v1/users?active=true", "api.example.com:8443", "https", "https://api.example.com:8443/v1/users?active=true"},
+		{"/v1/users", "api.example.com:443", "https", "https://api.example.com/v1/users"},
+		{"/", "example.com:80", "", "http://example.com/"},
+		{"/", "example.com:443", "", "http://example.com:443/"},
+		{"http://example.com:80/a?b=c", "example.com:80", "", "http://example.com/a?b=c"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.target, nil)
+		req.Host = c.host
+		if c.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", c.proto)
+		}
+
+		ctx := New()
+		ctx.Reset(httptest.NewRecorder(), req)
+		if url := ctx.FullURL(); url != c.url {
+			t.Errorf("%s: expect %s, got %s", c.target, c.url, url)
+		}
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/search?q=zebra&tags=a&tags=b", nil))
+
+	if ctx.RawQuery() != "q=zebra&tags=a&tags=b" {
+		t.Errorf("unexpected raw query %s", ctx.RawQuery())
+	}
+
+	query := ctx.QueryValues()
+	if query.Get("q") != "zebra" || !reflect.DeepEqual(ctx.QueryArray("tags"), []string{"a", "b"}) {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	query.Set("q", "changed")
+	if ctx.QueryValues().Get("q") != "changed" {
+		t.Error("expect query parsed once")
+	}
+
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/search?q=horse", nil))
+	if ctx.QueryValues().Get("q") != "horse" {
+		t.Error("expect query cleared by Reset")
+	}
+}
+
+func TestCookie(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: "abc"})
+	rw := httptest.NewRecorder()
+
+	ctx := New()
+	ctx.Reset(rw, req)
+	if ctx.Cookie("token") != "abc" || ctx.Cookie("missing") != "" {
+		t.Errorf("unexpected request cookies %q %q", ctx.Cookie("token"), ctx.Cookie("missing"))
+	}
+
+	ctx.SetCookie("lang", "en", CookieMaxAge(time.Hour), CookieHttpOnly(true))
+	ctx.ClearCookie("token", CookiePath("/api"), CookieDomain("example.com"), CookieMaxAge(time.Hour))
+
+	cookies := rw.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("expect 2 cookies, got %d", len(cookies))
+	}
+
+	if c := cookies[0]; c.Name != "lang" || c.Value != "en" || c.Path != "/" || c.MaxAge != 3600 || !c.HttpOnly {
+		t.Errorf("unexpected cookie %s", c)
+	}
+
+	c := cookies[1]
+	if c.Name != "token" || c.Value != "" || c.Path != "/api" || c.Domain != "example.com" || c.MaxAge != -1 {
+		t.Errorf("unexpected cleared cookie %s", c)
+	}
+	if !strings.Contains(rw.Header()["Set-Cookie"][1], "Expires=Thu, 01 Jan 1970") {
+		t.Errorf("cleared cookie not expired: %s", rw.Header()["Set-Cookie"][1])
+	}
+}
+
+func TestCookies(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Cookie", "session_id=1; lang=en; session_id=2")
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	cookies := ctx.Cookies()
+	if len(cookies) != 2 || cookies["session_id"] != "1" || cookies["lang"] != "en" {
+		t.Errorf("unexpected cookies %v", cookies)
+	}
+}
+
+func TestInterceptCommitted(t *testing.T) {
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+
+	ctx.WriteString("partial")
+	ctx.Intercept([]byte("denied"), http.StatusForbidden, "test")
+	if rw.Code != http.StatusOK || rw.Body.String() != "partial" || !ctx.IsAborted() {
+		t.Errorf("expect committed response kept, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	ctx.Abort(http.StatusUnauthorized)
+	ctx.AbortWithJSON(http.StatusBadRequest, map[string]string{"error": "bad"})
+	if rw.Code != http.StatusOK || rw.Body.String() != "partial" {
+		t.Errorf("expect abort not written after commit, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.Intercept([]byte("denied"), http.StatusForbidden, "test")
+	if rw.Code != http.StatusForbidden || rw.Body.String() != "denied" {
+		t.Errorf("expect intercepted, got %d %q", rw.Code, rw.Body.String())
+	}
+}
+
+func TestIsAjaxWebSocket(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	if ctx.IsAjax() || ctx.IsWebSocket() {
+		t.Errorf("plain request detected as ajax or websocket")
+	}
+
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "WebSocket")
+	ctx.Reset(httptest.NewRecorder(), req)
+	if !ctx.IsAjax() || !ctx.IsWebSocket() {
+		t.Errorf("expect ajax and websocket, got %v %v", ctx.IsAjax(), ctx.IsWebSocket())
+	}
+}
+
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w brokenWriter) Write(b []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestIsClientGone(t *testing.T) {
+	std, cancel := gocontext.WithCancel(gocontext.Background())
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(std))
+	if ctx.IsClientGone() {
+		t.Fatal("client gone before canceled")
+	}
+
+	cancel()
+	if !ctx.IsClientGone() {
+		t.Errorf("expect client gone after request canceled")
+	}
+
+	ctx.Reset(brokenWriter{httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+	if err := ctx.WriteString("data"); err == nil || !ctx.IsClientGone() {
+		t.Errorf("expect write error and client gone, got %v", err)
+	}
+
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if ctx.IsClientGone() {
+		t.Errorf("write error leaked to next request")
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	before := time.Now()
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if ctx.StartTime().Before(before) || ctx.StartTime().After(time.Now()) {
+		t.Errorf("unexpected start time %s", ctx.StartTime())
+	}
+
+	time.Sleep(time.Millisecond)
+	if ctx.Elapsed() < time.Millisecond {
+		t.Errorf("expect elapsed at least 1ms, got %s", ctx.Elapsed())
+	}
+
+	if ctx.Copy().StartTime() != ctx.StartTime() {
+		t.Errorf("start time not copied")
+	}
+}
+
+func TestJSONStream(t *testing.T) {
+	cases := []struct {
+		data interface{}
+		body string
+	}{
+		{[]map[string]int{{"id": 1}, {"id": 2}}, "[{\"id\":1}\n,{\"id\":2}\n]"},
+		{[]int{}, "[]"},
+		{map[string]string{"name": "zebra"}, "{\"name\":\"zebra\"}\n"},
+	}
+
+	for _, c := range cases {
+		rw := httptest.NewRecorder()
+		ctx := New()
+		ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+		if err := ctx.JSONStream(c.data); err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded interface{}
+		if rw.Body.String() != c.body || json.Unmarshal(rw.Body.Bytes(), &decoded) != nil {
+			t.Errorf("expect %q, got %q", c.body, rw.Body.String())
+		}
+		if rw.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("unexpected content type %q", rw.Header().Get("Content-Type"))
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	if err := ctx.JSONStream(func() {}); err == nil || rw.Code != http.StatusInternalServerError {
+		t.Errorf("expect 500 for unsupported value, got %d %v", rw.Code, err)
+	}
+}
+
+func TestTextHTMLString(t *testing.T) {
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.Text(http.StatusAccepted, "<h1>hi</h1>")
+	if rw.Code != http.StatusAccepted || rw.Header().Get("Content-Type") != "text/plain; charset=utf-8" || rw.Body.String() != "<h1>hi</h1>" {
+		t.Errorf("unexpected text response %d %q %q", rw.Code, rw.Header().Get("Content-Type"), rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.HTMLString(http.StatusOK, "<h1>hi</h1>")
+	if rw.Header().Get("Content-Type") != "text/html; charset=utf-8" || rw.Header().Get("Content-Length") != "11" {
+		t.Errorf("unexpected html response %q %q", rw.Header().Get("Content-Type"), rw.Header().Get("Content-Length"))
+	}
+}
+
+func TestFormJoin(t *testing.T) {
+	defer SetFormJoin(nil)
+
+	cases := []struct {
+		join  FormJoin
+		value string
+	}{
+		{nil, "a"},
+		{JoinLast, "b"},
+		{JoinWith(","), "a,b"},
+	}
+
+	for _, c := range cases {
+		SetFormJoin(c.join)
+
+		ctx := New()
+		ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/?tag=a&tag=b", nil))
+		if ctx.Get("tag") != c.value || ctx.Form()["tag"] != c.value {
+			t.Errorf("expect %q, got %q %q", c.value, ctx.Get("tag"), ctx.Form()["tag"])
+		}
+		if tags := ctx.QueryArray("tag"); len(tags) != 2 {
+			t.Errorf("expect all values kept, got %v", tags)
+		}
+	}
+}
+
+func TestParam(t *testing.T) {
+	ctx, _ := newTestContext("GET", "/files/a.txt?filepath=../secret&id=2")
+	ctx.SetParam("id", "1")
+
+	if ctx.Param("id") != "1" || ctx.Get("id") != "1" {
+		t.Errorf("expect path parameter set, got %q %q", ctx.Param("id"), ctx.Get("id"))
+	}
+
+	if ctx.Param("filepath") != "" || ctx.Get("filepath") != "../secret" {
+		t.Errorf("expect query not returned by Param, got %q", ctx.Param("filepath"))
+	}
+}
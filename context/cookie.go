@@ -0,0 +1,106 @@
+package context
+
+import (
+	"net/http"
+	"time"
+)
+
+// CookieOption sets attributes of a cookie written by SetCookie or ClearCookie
+type CookieOption func(*http.Cookie)
+
+// CookiePath sets Path of cookie, it's "/" by default
+func CookiePath(path string) CookieOption {
+	return func(cookie *http.Cookie) {
+		cookie.Path = path
+	}
+}
+
+// CookieDomain sets Domain of cookie
+func CookieDomain(domain string) CookieOption {
+	return func(cookie *http.Cookie) {
+		cookie.Domain = domain
+	}
+}
+
+// CookieMaxAge sets Max-Age of cookie, the cookie is kept in browser session if not set
+func CookieMaxAge(maxAge time.Duration) CookieOption {
+	return func(cookie *http.Cookie) {
+		cookie.MaxAge = int(maxAge.Seconds())
+	}
+}
+
+// CookieSecure sets Secure of cookie, it's only sent over https
+func CookieSecure(secure bool) CookieOption {
+	return func(cookie *http.Cookie) {
+		cookie.Secure = secure
+	}
+}
+
+// CookieHttpOnly sets HttpOnly of cookie, it can not be read by javascript
+func CookieHttpOnly(httpOnly bool) CookieOption {
+	return func(cookie *http.Cookie) {
+		cookie.HttpOnly = httpOnly
+	}
+}
+
+// CookieSameSite sets SameSite of cookie
+func CookieSameSite(sameSite http.SameSite) CookieOption {
+	return func(cookie *http.Cookie) {
+		cookie.SameSite = sameSite
+	}
+}
+
+// Cookie returns value of request cookie with name, "" if not exist
+func (c *Context) Cookie(name string) string {
+	cookie, err := c.request.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+// Cookies returns all request cookies as name and value, the first one is kept if a name is
+// sent more than once, such as
+//
+//	for name := range ctx.Cookies() {
+//		if strings.HasPrefix(name, "session_") {
+//			ctx.ClearCookie(name)
+//		}
+//	}
+func (c *Context) Cookies() map[string]string {
+	cookies := make(map[string]string)
+	for _, cookie := range c.request.Cookies() {
+		if _, ok := cookies[cookie.Name]; !ok {
+			cookies[cookie.Name] = cookie.Value
+		}
+	}
+
+	return cookies
+}
+
+// SetCookie adds a Set-Cookie header of name and value to response
+func (c *Context) SetCookie(name, value string, opts ...CookieOption) {
+	cookie := &http.Cookie{Name: name, Value: value, Path: "/"}
+	for _, opt := range opts {
+		opt(cookie)
+	}
+
+	http.SetCookie(c.rw, cookie)
+}
+
+// ClearCookie asks browser to delete cookie with name, Path and Domain must be same as the ones
+// it was set with, or browser keeps the old cookie, such as
+//
+//	ctx.ClearCookie("token", context.CookiePath("/api"), context.CookieDomain("example.com"))
+func (c *Context) ClearCookie(name string, opts ...CookieOption) {
+	cookie := &http.Cookie{Name: name, Path: "/"}
+	for _, opt := range opts {
+		opt(cookie)
+	}
+
+	cookie.Value = ""
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	http.SetCookie(c.rw, cookie)
+}
@@ -0,0 +1,79 @@
+package context
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// ErrCopiedWrite is the panic value of writing response of a copied Context
+var ErrCopiedWrite = errors.New("Context: response of a copied Context can not be written")
+
+// Copy returns a snapshot of Context which can be used in goroutines after the handler returned,
+// such as sending an email after responded. Contexts are reused across requests, so the original
+// one must not be touched after the request finished. Data, form and body are copied, and values
+// of SetValue are shared. Only reads are safe on a copy, writing response panics with
+// ErrCopiedWrite, and funcs of Defer are never called.
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		request:      c.request,
+		data:         make(map[string]string, len(c.data)),
+		form:         make(map[string]string, len(c.form)),
+		rawForm:      make(url.Values, len(c.rawForm)),
+		body:         append([]byte{}, c.body...),
+		bodyErr:      c.bodyErr,
+		stages:       append([]Stage{}, c.stages...),
+		route:        c.route,
+		start:        c.start,
+		expecting:    c.expecting,
+		aborted:      c.aborted,
+		renderer:     c.renderer,
+		renderFuncs:  c.renderFuncs,
+		errorHandler: c.errorHandler,
+	}
+
+	for k, v := range c.data {
+		cp.data[k] = v
+	}
+	for k, v := range c.params {
+		cp.SetParam(k, v)
+	}
+	for k, v := range c.values {
+		cp.SetValue(k, v)
+	}
+	for k, v := range c.form {
+		cp.form[k] = v
+	}
+	for k, v := range c.rawForm {
+		cp.rawForm[k] = append([]string{}, v...)
+	}
+
+	header := http.Header{}
+	for k, v := range c.rw.Header() {
+		header[k] = append([]string{}, v...)
+	}
+
+	status, size, written := c.writer.state()
+	cp.writer.reset(copiedWriter(header))
+	cp.writer.status, cp.writer.size, cp.writer.written = status, size, written
+	cp.writer.err = c.writer.error()
+	cp.rw = &cp.writer
+
+	return cp
+}
+
+// copiedWriter is ResponseWriter of a copied Context, response headers can be read, but writing
+// panics since the response of original request may be finished
+type copiedWriter http.Header
+
+func (w copiedWriter) Header() http.Header {
+	return http.Header(w)
+}
+
+func (w copiedWriter) Write([]byte) (int, error) {
+	panic(ErrCopiedWrite)
+}
+
+func (w copiedWriter) WriteHeader(int) {
+	panic(ErrCopiedWrite)
+}
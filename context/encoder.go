@@ -0,0 +1,69 @@
+package context
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// EncoderFunc writes v to w in a media type, such as CSV or YAML
+type EncoderFunc func(w io.Writer, v interface{}) error
+
+// encoders are registered by RegisterEncoder, keyed by lower case media type, types keeps the
+// order they registered, which breaks ties of negotiation
+var encoders = struct {
+	sync.RWMutex
+	funcs map[string]EncoderFunc
+	types []string
+}{funcs: make(map[string]EncoderFunc)}
+
+// RegisterEncoder registers fn to encode data of Render for mediaType, such as "text/csv" or
+// "application/yaml". Render responds with it if mediaType is accepted better than html by
+// Accept header, registering a media type again replaces its encoder.
+//
+//	context.RegisterEncoder("application/yaml", func(w io.Writer, v interface{}) error {
+//		return yaml.NewEncoder(w).Encode(v)
+//	})
+func RegisterEncoder(mediaType string, fn EncoderFunc) {
+	mediaType = strings.ToLower(mediaType)
+
+	encoders.Lock()
+	defer encoders.Unlock()
+
+	if _, ok := encoders.funcs[mediaType]; !ok {
+		encoders.types = append(encoders.types, mediaType)
+	}
+	encoders.funcs[mediaType] = fn
+}
+
+// encoder returns the registered media type and encoder best accepted by client, html is
+// preferred if it's accepted as well as others or Accept is absent
+func (c *Context) encoder() (string, EncoderFunc) {
+	encoders.RLock()
+	defer encoders.RUnlock()
+
+	if len(encoders.types) == 0 {
+		return "", nil
+	}
+
+	offered := append([]string{"text/html"}, encoders.types...)
+	mediaType := c.Accepts(offered...)
+	return mediaType, encoders.funcs[mediaType]
+}
+
+// encode writes data with encoder of mediaType
+func (c *Context) encode(mediaType string, encode EncoderFunc, data interface{}) error {
+	var buffer bytes.Buffer
+	if err := encode(&buffer, data); err != nil {
+		http.Error(c.rw, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	c.Header("Content-Type", mediaType)
+	c.rw.Header().Add("Vary", "Accept")
+	_, err := c.Write(buffer.Bytes())
+
+	return err
+}
@@ -0,0 +1,76 @@
+package context
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+// encodeYAML is a minimal YAML encoder of flat maps, real apps register one like gopkg.in/yaml
+func encodeYAML(w io.Writer, v interface{}) error {
+	m, ok := v.(map[string]string)
+	if !ok {
+		return fmt.Errorf("yaml: unsupported type %T", v)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s: %q\n", k, m[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	RegisterEncoder("application/yaml", encodeYAML)
+
+	templates := template.Must(template.New("user").Parse(`<p>{{.name}}</p>`))
+	data := map[string]string{"name": "bob", "role": "admin"}
+
+	cases := []struct {
+		accept, contentType, body string
+	}{
+		{"", "text/html; charset=utf-8", "<p>bob</p>"},
+		{"*/*", "text/html; charset=utf-8", "<p>bob</p>"},
+		{"application/yaml", "application/yaml", "name: \"bob\"\nrole: \"admin\"\n"},
+		{"text/html;q=0.5, application/yaml", "application/yaml", "name: \"bob\"\nrole: \"admin\"\n"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		rw := httptest.NewRecorder()
+
+		ctx := New()
+		ctx.Reset(rw, req)
+		ctx.SetRenderer(&TemplateRenderer{Templates: templates})
+		if err := ctx.Render("user", data); err != nil {
+			t.Fatalf("%q: render failed, %s", c.accept, err)
+		}
+
+		if rw.Header().Get("Content-Type") != c.contentType || rw.Body.String() != c.body {
+			t.Errorf("%q: expect %s %q, got %s %q", c.accept, c.contentType, c.body, rw.Header().Get("Content-Type"), rw.Body.String())
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/yaml")
+	ctx := New()
+	ctx.Reset(rw, req)
+	if err := ctx.Render("user", []int{1}); err == nil || rw.Code != 500 {
+		t.Errorf("expect encoder error responds 500, got %d %v", rw.Code, err)
+	}
+}
@@ -0,0 +1,84 @@
+package context
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+// ErrorHandler responds err with http status code, it's used by helpers like BindAndRespond
+// when they fail, set by router for each request
+type ErrorHandler func(ctx *Context, code int, err error)
+
+// SetErrorHandler sets handler used by HandleError, DefaultErrorHandler is used if nil
+func (c *Context) SetErrorHandler(handler ErrorHandler) {
+	c.errorHandler = handler
+}
+
+// HandleError responds err with code by the error handler
+func (c *Context) HandleError(code int, err error) {
+	if c.errorHandler != nil {
+		c.errorHandler(c, code, err)
+	} else {
+		DefaultErrorHandler(c, code, err)
+	}
+}
+
+// DefaultErrorHandler responds {"error":{"code":code,"message":"..."}} for JSON clients, a simple
+// page for browsers, and plain text otherwise, the format is negotiated by q-values of Accept
+// header like errors of router
+func DefaultErrorHandler(c *Context, code int, err error) {
+	// Clients accept anything, such as curl with "*/*", get plain text
+	format := "text/plain"
+	if c.AcceptsJSON() || c.AcceptsHTML() {
+		format = c.Accepts("application/json", "text/html", "text/plain")
+	}
+
+	switch format {
+	case "application/json":
+		// Field errors of binding and validation are written as details
+		switch fields := err.(type) {
+		case ValidationErrors:
+			c.ErrorFields(code, err.Error(), fields)
+		case BindErrors:
+			c.ErrorFields(code, err.Error(), fields)
+		default:
+			c.Error(code, err.Error())
+		}
+	case "text/html":
+		c.Header("Content-Type", WithCharset("text/html"))
+		c.WriteHeader(code)
+		c.WriteString(fmt.Sprintf("<html><head><title>%d %s</title></head><body><h1>%d %s</h1><p>%s</p></body></html>",
+			code, http.StatusText(code), code, http.StatusText(code), html.EscapeString(err.Error())))
+	default:
+		http.Error(c.rw, err.Error(), code)
+	}
+}
+
+// ErrorBody is the standard JSON error envelope, {"error":{"code":...,"message":...}}
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail is the content of ErrorBody, Details is omitted if nil
+type ErrorDetail struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Error writes the standard JSON error envelope with http status code
+func (c *Context) Error(code int, message string) error {
+	return c.ErrorWith(code, message, nil)
+}
+
+// ErrorWith writes the standard JSON error envelope with details, such as field errors
+func (c *Context) ErrorWith(code int, message string, details interface{}) error {
+	return c.JSONStatus(code, ErrorBody{ErrorDetail{Code: code, Message: message, Details: details}}, false)
+}
+
+// ErrorFields writes the standard JSON error envelope with field errors as details, they're
+// converted by translator set by SetTranslator
+func (c *Context) ErrorFields(code int, message string, errs []FieldError) error {
+	return c.ErrorWith(code, message, c.translate(errs))
+}
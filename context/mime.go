@@ -0,0 +1,72 @@
+package context
+
+import (
+	"mime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// mimeTypes are content types registered by RegisterMIME, keyed by lower case extension
+var mimeTypes = struct {
+	sync.RWMutex
+	types map[string]string
+}{types: make(map[string]string)}
+
+// RegisterMIME registers contentType of file extension ext, such as ".webmanifest" and
+// "application/manifest+json", file serving like oss downloads consults it before
+// mime.TypeByExtension
+func RegisterMIME(ext, contentType string) {
+	mimeTypes.Lock()
+	mimeTypes.types[normalizeExt(ext)] = contentType
+	mimeTypes.Unlock()
+}
+
+// charset is set by SetCharset, read atomically since responses are written concurrently
+var charset atomic.Value
+
+// SetCharset sets charset of text responses written by Text, HTMLString, JSON, XML, Render and
+// others, it's "utf-8" by default, "" restores the default. Content is not converted, handlers
+// must write bytes in the charset.
+func SetCharset(name string) {
+	if name == "" {
+		name = "utf-8"
+	}
+
+	charset.Store(name)
+}
+
+// WithCharset returns mediaType with charset set by SetCharset, such as
+// "text/plain; charset=utf-8"
+func WithCharset(mediaType string) string {
+	name, ok := charset.Load().(string)
+	if !ok {
+		name = "utf-8"
+	}
+
+	return mediaType + "; charset=" + name
+}
+
+// TypeByExtension returns content type of extension ext registered by RegisterMIME, or the one
+// of mime.TypeByExtension, "" if unknown
+func TypeByExtension(ext string) string {
+	ext = normalizeExt(ext)
+
+	mimeTypes.RLock()
+	contentType, ok := mimeTypes.types[ext]
+	mimeTypes.RUnlock()
+	if ok {
+		return contentType
+	}
+
+	return mime.TypeByExtension(ext)
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	return ext
+}
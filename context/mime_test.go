@@ -0,0 +1,47 @@
+package context
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterMIME(t *testing.T) {
+	RegisterMIME("webmanifest", "application/manifest+json")
+
+	if contentType := TypeByExtension(".WebManifest"); contentType != "application/manifest+json" {
+		t.Errorf("expect registered type, got %s", contentType)
+	}
+
+	if contentType := TypeByExtension(".json"); contentType != "application/json" {
+		t.Errorf("expect fallback to mime.TypeByExtension, got %s", contentType)
+	}
+
+	if contentType := TypeByExtension(".zebra-unknown"); contentType != "" {
+		t.Errorf("expect empty type, got %s", contentType)
+	}
+}
+
+func TestSetCharset(t *testing.T) {
+	SetCharset("iso-8859-1")
+	defer SetCharset("")
+
+	rw := httptest.NewRecorder()
+	ctx := New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.Text(200, "zebra")
+	if rw.Header().Get("Content-Type") != "text/plain; charset=iso-8859-1" {
+		t.Errorf("unexpected Content-Type %s", rw.Header().Get("Content-Type"))
+	}
+
+	rw = httptest.NewRecorder()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+	ctx.JSON("zebra", false)
+	if rw.Header().Get("Content-Type") != "application/json; charset=iso-8859-1" {
+		t.Errorf("unexpected Content-Type %s", rw.Header().Get("Content-Type"))
+	}
+
+	SetCharset("")
+	if WithCharset("text/html") != "text/html; charset=utf-8" {
+		t.Errorf("expect utf-8 restored, got %s", WithCharset("text/html"))
+	}
+}
@@ -0,0 +1,120 @@
+package context
+
+import (
+	"errors"
+	"github.com/raythorn/zebra/log"
+	"io"
+	"mime/multipart"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultMaxMemory is the max bytes of multipart form stored in memory, files beyond it are
+// stored in temporary files on disk
+const defaultMaxMemory = 32 << 20
+
+// maxMemory is set by SetMaxMultipartMemory, read atomically since requests parse concurrently
+var maxMemory int64 = defaultMaxMemory
+
+// SetMaxMultipartMemory sets the max bytes of multipart form stored in memory, 32MB by default,
+// files beyond it spill to temporary files on disk. Large upload servers may lower it to keep
+// memory bounded, n <= 0 restores the default.
+func SetMaxMultipartMemory(n int64) {
+	if n <= 0 {
+		n = defaultMaxMemory
+	}
+
+	atomic.StoreInt64(&maxMemory, n)
+}
+
+// isMultipart checks if request body is a multipart form
+func (c *Context) isMultipart() bool {
+	return strings.HasPrefix(c.request.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+// MultipartForm parses multipart form on first call, values of the form are available by Get
+// and Form after parsed. Body of multipart request is not read by Reset, so Body is empty.
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.request.MultipartForm != nil {
+		return c.request.MultipartForm, nil
+	}
+
+	if !c.isMultipart() {
+		return nil, errors.New("MultipartForm: request is not multipart/form-data")
+	}
+
+	if err := c.request.ParseMultipartForm(atomic.LoadInt64(&maxMemory)); err != nil {
+		return nil, err
+	}
+
+	c.rawForm = c.request.Form
+	c.setForm(c.request.MultipartForm.Value)
+	c.Defer(func() {
+		c.request.MultipartForm.RemoveAll()
+	})
+
+	return c.request.MultipartForm, nil
+}
+
+// FormFile returns the first uploaded file of field name
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+
+	if files := form.File[name]; len(files) > 0 {
+		return files[0], nil
+	}
+
+	return nil, errors.New("FormFile: no such file " + name)
+}
+
+// FormValue returns the first value of form field name, "" if not exist. Multipart form is parsed
+// by MultipartForm on first call, with the memory limit of SetMaxMultipartMemory, so a text field
+// like "metadata" can be read along with FormFile. Values of multipart body are preferred to query,
+// and values of urlencoded form and query are returned for other requests.
+//
+//	header, err := ctx.FormFile("file")
+//	...
+//	json.Unmarshal([]byte(ctx.FormValue("metadata")), &meta)
+func (c *Context) FormValue(name string) string {
+	if c.isMultipart() && !c.raw {
+		form, err := c.MultipartForm()
+		if err != nil {
+			log.Debug("FormValue: parse multipart form failed, %s", err)
+		} else if values := form.Value[name]; len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	if values := c.rawForm[name]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// SaveFile streams the first uploaded file of field name to dst
+func (c *Context) SaveFile(name, dst string) error {
+	header, err := c.FormFile(name)
+	if err != nil {
+		return err
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, src)
+	return err
+}
@@ -0,0 +1,125 @@
+package context
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultipartForm(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("title", "avatar")
+	part, _ := writer.CreateFormFile("file", "avatar.txt")
+	part.Write([]byte("zebra"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	defer ctx.Finish()
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Filename != "avatar.txt" || header.Size != 5 {
+		t.Errorf("unexpected file %s %d", header.Filename, header.Size)
+	}
+
+	if ctx.Get("title") != "avatar" || ctx.Form()["title"] != "avatar" {
+		t.Errorf("expect multipart value set, got %q", ctx.Get("title"))
+	}
+
+	dst := filepath.Join(t.TempDir(), "avatar.txt")
+	if err := ctx.SaveFile("file", dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := ioutil.ReadFile(dst); string(content) != "zebra" {
+		t.Errorf("unexpected saved content %q", content)
+	}
+
+	if _, err := ctx.FormFile("missing"); err == nil {
+		t.Error("expect error for missing file")
+	}
+}
+
+func TestFormValue(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("metadata", `{"name":"avatar"}`)
+	writer.WriteField("tag", "a")
+	writer.WriteField("tag", "b")
+	part, _ := writer.CreateFormFile("file", "avatar.txt")
+	part.Write([]byte("zebra"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload?tag=q", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	defer ctx.Finish()
+
+	if v := ctx.FormValue("metadata"); v != `{"name":"avatar"}` {
+		t.Errorf("expect multipart field, got %q", v)
+	}
+	if v := ctx.FormValue("tag"); v != "a" {
+		t.Errorf("expect first value of body before query, got %q", v)
+	}
+	if v := ctx.FormValue("file"); v != "" {
+		t.Errorf("expect file field has no value, got %q", v)
+	}
+	if header, err := ctx.FormFile("file"); err != nil || header.Size != 5 {
+		t.Errorf("expect file along with values, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/users?id=1", strings.NewReader("name=bob"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx.Reset(httptest.NewRecorder(), req)
+	if ctx.FormValue("name") != "bob" || ctx.FormValue("id") != "1" || ctx.FormValue("missing") != "" {
+		t.Errorf("expect urlencoded form and query, got %q %q", ctx.FormValue("name"), ctx.FormValue("id"))
+	}
+}
+
+func TestMaxMultipartMemory(t *testing.T) {
+	SetMaxMultipartMemory(16)
+	defer SetMaxMultipartMemory(0)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "large.bin")
+	part.Write(bytes.Repeat([]byte("z"), 1024))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	ctx := New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	defer ctx.Finish()
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, ok := file.(*os.File); !ok {
+		t.Errorf("expect file beyond max memory stored on disk, got %T", file)
+	}
+}
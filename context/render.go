@@ -0,0 +1,69 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// Renderer is the engine used by Render, html/template is used by default, implement this
+// interface to use other template engines
+type Renderer interface {
+	Render(w io.Writer, name string, data interface{}) error
+}
+
+// TemplateRenderer is the default Renderer with html/template
+type TemplateRenderer struct {
+	Templates *template.Template
+}
+
+func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}) error {
+	return t.Templates.ExecuteTemplate(w, name, data)
+}
+
+// RenderFunc transforms or augments data before rendered, it's used to inject common data,
+// such as current user or CSRF token, into every render
+type RenderFunc func(ctx *Context, data interface{}) interface{}
+
+// SetRenderer sets engine used by Render, it's set by router for each request
+func (c *Context) SetRenderer(renderer Renderer) {
+	c.renderer = renderer
+}
+
+// SetRenderFuncs sets funcs called before Render executes, it's set by router for each request
+func (c *Context) SetRenderFuncs(funcs ...RenderFunc) {
+	c.renderFuncs = funcs
+}
+
+// Render executes template name with data by renderer, data will be passed through all render funcs first.
+// If a media type registered by RegisterEncoder is accepted better than html, data is encoded with
+// its encoder instead.
+func (c *Context) Render(name string, data interface{}) error {
+
+	for _, fn := range c.renderFuncs {
+		data = fn(c, data)
+	}
+
+	if mediaType, encode := c.encoder(); encode != nil {
+		return c.encode(mediaType, encode, data)
+	}
+
+	if c.renderer == nil {
+		err := errors.New("Render: no renderer set")
+		http.Error(c.rw, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	var buffer bytes.Buffer
+	if err := c.renderer.Render(&buffer, name, data); err != nil {
+		http.Error(c.rw, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	c.Header("Content-Type", WithCharset("text/html"))
+	_, err := c.Write(buffer.Bytes())
+
+	return err
+}
@@ -0,0 +1,33 @@
+package context
+
+// Session is server side state of a client, it's loaded by session midware, see package session
+type Session interface {
+	// ID returns id of session, which is saved in cookie
+	ID() string
+
+	// Get returns value of key, nil if not exist
+	Get(key string) interface{}
+
+	// Set sets value of key
+	Set(key string, value interface{})
+
+	// Delete deletes value of key
+	Delete(key string)
+
+	// Regenerate changes id of session and keeps values, it should be called on login to
+	// prevent session fixation
+	Regenerate() error
+
+	// Destroy deletes all values and the session from store
+	Destroy()
+}
+
+// SetSession sets session of current request, it's called by session midware
+func (c *Context) SetSession(session Session) {
+	c.session = session
+}
+
+// Session returns session of current request, nil if no session midware used
+func (c *Context) Session() Session {
+	return c.session
+}
@@ -0,0 +1,321 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Validation rules are set with the `valid` tag, separated by ",":
+//
+//	required    value must not be zero, such as "", 0 or nil
+//	min=N max=N bounds of numbers
+//	len=N       exact length of strings, slices and maps, minlen=N and maxlen=N for bounds
+//	email       value must be an email address
+//	regexp=EXP  value must match EXP, it must be the last rule, and commas in EXP are kept
+//
+// Empty strings, slices, maps and nil pointers are only checked by required, so optional fields
+// can be omitted, numbers are always checked, use a pointer for an optional number.
+//
+//	type User struct {
+//		Name  string `json:"name" valid:"required,maxlen=32"`
+//		Age   int    `json:"age" valid:"min=0,max=150"`
+//		Email string `json:"email" valid:"required,email"`
+//	}
+
+// FieldError is a failed rule of a field, Field is the name in JSON if json tag set
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is returned by Validate and BindValid if any rule failed, it can be used as
+// details of ErrorWith directly
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Field + " " + e.Message
+	}
+
+	return "Valid: " + strings.Join(messages, "; ")
+}
+
+// Translator converts field errors into details of error envelope written by ErrorFields, such as
+// messages in language of Accept-Language, or shape of an existing API
+type Translator func(ctx *Context, errs []FieldError) interface{}
+
+// DefaultTranslator keeps field errors as they are, details is a list of field, rule and message
+func DefaultTranslator(ctx *Context, errs []FieldError) interface{} {
+	return errs
+}
+
+// translator is set by SetTranslator, read atomically since requests are handled concurrently
+var translator atomic.Value
+
+// SetTranslator sets translator of field errors used by ErrorFields, nil restores DefaultTranslator
+//
+//	context.SetTranslator(func(ctx *context.Context, errs []context.FieldError) interface{} {
+//		messages := make(map[string]string)
+//		for _, e := range errs {
+//			messages[e.Field] = i18n.T(ctx.Get("Accept-Language"), e.Rule)
+//		}
+//		return messages
+//	})
+func SetTranslator(fn Translator) {
+	if fn == nil {
+		fn = DefaultTranslator
+	}
+
+	translator.Store(fn)
+}
+
+// translate converts errs by translator set by SetTranslator
+func (c *Context) translate(errs []FieldError) interface{} {
+	fn, ok := translator.Load().(Translator)
+	if !ok {
+		fn = DefaultTranslator
+	}
+
+	return fn(c, errs)
+}
+
+var (
+	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+$`)
+
+	// compiled regexp rules, they're compiled once
+	regexps sync.Map
+)
+
+// BindValid binds request by Bind, then validates v with Validate
+//
+//	if err := ctx.BindValid(&user); err != nil {
+//		if fields, ok := err.(context.ValidationErrors); ok {
+//			ctx.ErrorFields(422, "invalid user", fields)
+//		}
+//		...
+//	}
+func (c *Context) BindValid(v interface{}) error {
+	if err := c.Bind(v); err != nil {
+		return err
+	}
+
+	return Validate(v)
+}
+
+// Validate checks struct pointed by v with rules in `valid` tag, nested structs are checked too,
+// ValidationErrors returned if any rule failed
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return errors.New("Valid: target must be a non-nil pointer")
+		}
+		rv = rv.Elem()
+	}
+
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		fv := rv.Field(i)
+
+		if tag := field.Tag.Get("valid"); tag != "" {
+			if err := validateField(fv, name, tag, errs); err != nil {
+				return err
+			}
+		}
+
+		if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			if err := validateStruct(fv, name+".", errs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fieldName returns name of field in JSON
+func fieldName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+
+	return field.Name
+}
+
+func validateField(fv reflect.Value, name, tag string, errs *ValidationErrors) error {
+	fail := func(rule, format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: name, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	rules := strings.Split(tag, ",")
+	for i := 0; i < len(rules); i++ {
+		rule, arg := rules[i], ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			rule, arg = rule[:j], rule[j+1:]
+		}
+
+		if rule == "regexp" {
+			// regexp takes the rest of tag
+			arg = strings.Join(append([]string{arg}, rules[i+1:]...), ",")
+			i = len(rules)
+		}
+
+		if rule == "required" {
+			if fv.IsZero() {
+				fail(rule, "is required")
+				return nil
+			}
+			continue
+		}
+
+		// Other rules are only checked for non-empty values, zero of numbers is a value
+		if empty(fv) {
+			continue
+		}
+
+		value := fv
+		for value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+
+		switch rule {
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return errors.New("Valid: field " + name + ": invalid " + rule + " " + arg)
+			}
+
+			n, ok := number(value)
+			if !ok {
+				return errors.New("Valid: field " + name + ": " + rule + " requires a number")
+			}
+
+			if rule == "min" && n < bound {
+				fail(rule, "must be at least %s", arg)
+			} else if rule == "max" && n > bound {
+				fail(rule, "must be at most %s", arg)
+			}
+		case "len", "minlen", "maxlen":
+			bound, err := strconv.Atoi(arg)
+			if err != nil {
+				return errors.New("Valid: field " + name + ": invalid " + rule + " " + arg)
+			}
+
+			n, ok := length(value)
+			if !ok {
+				return errors.New("Valid: field " + name + ": " + rule + " requires a string, slice or map")
+			}
+
+			switch {
+			case rule == "len" && n != bound:
+				fail(rule, "must have length %d", bound)
+			case rule == "minlen" && n < bound:
+				fail(rule, "must have at least length %d", bound)
+			case rule == "maxlen" && n > bound:
+				fail(rule, "must have at most length %d", bound)
+			}
+		case "email":
+			if value.Kind() != reflect.String || !emailRegex.MatchString(value.String()) {
+				fail(rule, "must be an email address")
+			}
+		case "regexp":
+			exp, err := compileRule(arg)
+			if err != nil {
+				return errors.New("Valid: field " + name + ": invalid regexp " + arg)
+			}
+
+			if value.Kind() != reflect.String || !exp.MatchString(value.String()) {
+				fail(rule, "must match %s", arg)
+			}
+		default:
+			return errors.New("Valid: field " + name + ": unknown rule " + rule)
+		}
+	}
+
+	return nil
+}
+
+func compileRule(exp string) (*regexp.Regexp, error) {
+	if r, ok := regexps.Load(exp); ok {
+		return r.(*regexp.Regexp), nil
+	}
+
+	r, err := regexp.Compile(exp)
+	if err != nil {
+		return nil, err
+	}
+
+	regexps.Store(exp, r)
+	return r, nil
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+// empty checks if v is an empty string, slice or map, or a nil pointer or interface
+func empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+func length(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len(), true
+	}
+
+	return 0, false
+}
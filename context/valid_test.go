@@ -0,0 +1,107 @@
+package context
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" valid:"required"`
+	Zip  string `json:"zip" valid:"regexp=^[0-9]{5}(-[0-9]{4})?$"`
+}
+
+type signup struct {
+	Name    string   `json:"name" valid:"required,minlen=2,maxlen=8"`
+	Age     int      `json:"age" valid:"min=13,max=150"`
+	Email   string   `json:"email" valid:"required,email"`
+	Tags    []string `json:"tags" valid:"maxlen=2"`
+	Address address  `json:"address"`
+}
+
+func TestBindValid(t *testing.T) {
+	body := `{"name":"z","age":7,"email":"not-an-email","tags":["a","b","c"],"address":{"zip":"1234"}}`
+	ctx, rw := newBodyContext("POST", "/signup", "application/json", body)
+
+	var user signup
+	err := ctx.BindValid(&user)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expect ValidationErrors, got %v", err)
+	}
+
+	var failed []string
+	for _, e := range errs {
+		failed = append(failed, e.Field+":"+e.Rule)
+	}
+
+	expect := []string{"name:minlen", "age:min", "email:email", "tags:maxlen", "address.city:required", "address.zip:regexp"}
+	if !reflect.DeepEqual(failed, expect) {
+		t.Errorf("expect %v, got %v", expect, failed)
+	}
+
+	ctx.ErrorWith(http.StatusUnprocessableEntity, "invalid signup", errs)
+	if rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expect 422, got %d", rw.Code)
+	}
+
+	body = `{"name":"zebra","age":30,"email":"zebra@example.com","address":{"city":"Paris","zip":"75001"}}`
+	ctx, _ = newBodyContext("POST", "/signup", "application/json", body)
+	if err := ctx.BindValid(&signup{}); err != nil {
+		t.Errorf("expect valid, got %v", err)
+	}
+}
+
+func TestValidateZeroNumber(t *testing.T) {
+	v := struct {
+		Count int  `valid:"min=1"`
+		Limit int  `valid:"max=-1"`
+		Page  *int `valid:"min=1"`
+	}{}
+
+	errs, ok := Validate(&v).(ValidationErrors)
+	if !ok || len(errs) != 2 || errs[0].Field != "Count" || errs[1].Field != "Limit" {
+		t.Errorf("expect zero numbers checked and nil pointer skipped, got %v", errs)
+	}
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	v := struct {
+		Name string `valid:"unique"`
+	}{"zebra"}
+
+	if err := Validate(&v); err == nil || err.Error() != "Valid: field Name: unknown rule unique" {
+		t.Errorf("expect unknown rule error, got %v", err)
+	}
+}
+
+func TestTranslator(t *testing.T) {
+	errs := ValidationErrors{{Field: "name", Rule: "required", Message: "is required"}}
+
+	ctx, rw := newTestContext("POST", "/signup")
+	ctx.ErrorFields(http.StatusUnprocessableEntity, "invalid signup", errs)
+	if rw.Body.String() != `{"error":{"code":422,"message":"invalid signup","details":[{"field":"name","rule":"required","message":"is required"}]}}` {
+		t.Errorf("unexpected default details %s", rw.Body.String())
+	}
+
+	SetTranslator(func(ctx *Context, errs []FieldError) interface{} {
+		messages := make(map[string]string)
+		for _, e := range errs {
+			if ctx.Get("Accept-Language") == "fr" && e.Rule == "required" {
+				messages[e.Field] = "obligatoire"
+			} else {
+				messages[e.Field] = e.Message
+			}
+		}
+		return messages
+	})
+	defer SetTranslator(nil)
+
+	ctx, rw = newTestContext("POST", "/signup")
+	ctx.Set("Accept", "application/json")
+	ctx.Set("Accept-Language", "fr")
+	ctx.HandleError(http.StatusUnprocessableEntity, errs)
+	if rw.Body.String() != `{"error":{"code":422,"message":"Valid: name is required","details":{"name":"obligatoire"}}}` {
+		t.Errorf("unexpected translated details %s", rw.Body.String())
+	}
+}
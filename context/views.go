@@ -0,0 +1,146 @@
+package context
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ViewOptions configure Views
+//
+//	Extension extension of template files, ".html" by default
+//	Layout    name of layout in "layouts" directory, such as "main" for layouts/main.html, pages
+//	          are rendered inside it, the layout includes page with {{template "content" .}}
+//	Funcs     functions available in all templates
+//	Reload    parse templates on every render, it's for development only
+type ViewOptions struct {
+	Extension string
+	Layout    string
+	Funcs     template.FuncMap
+	Reload    bool
+}
+
+// Views is a Renderer which loads html/template files from a directory. Files in "layouts"
+// are layouts, files in "partials" are shared by all pages, and others are pages named with
+// path relative to the directory without extension, such as "users/index". Each page defines
+// "content" to be used in layout, or renders by itself if no layout set.
+type Views struct {
+	dir   string
+	opts  ViewOptions
+	mutex sync.RWMutex
+	pages map[string]*template.Template
+}
+
+// NewViews parses all templates in dir
+func NewViews(dir string, opts ViewOptions) (*Views, error) {
+	if opts.Extension == "" {
+		opts.Extension = ".html"
+	}
+
+	v := &Views{dir: dir, opts: opts}
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *Views) Render(w io.Writer, name string, data interface{}) error {
+	if v.opts.Reload {
+		if err := v.load(); err != nil {
+			return err
+		}
+	}
+
+	v.mutex.RLock()
+	page, ok := v.pages[name]
+	v.mutex.RUnlock()
+
+	if !ok {
+		return errors.New("Views: template " + name + " not found")
+	}
+
+	if v.opts.Layout != "" {
+		return page.ExecuteTemplate(w, "layout", data)
+	}
+
+	return page.Execute(w, data)
+}
+
+// load parses each page with layout and partials into its own template set, so pages can
+// define blocks with same names
+func (v *Views) load() error {
+	var partials, pages []string
+	err := filepath.Walk(v.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != v.opts.Extension {
+			return err
+		}
+
+		rel, _ := filepath.Rel(v.dir, path)
+		switch {
+		case strings.HasPrefix(rel, "partials"+string(filepath.Separator)):
+			partials = append(partials, path)
+		case strings.HasPrefix(rel, "layouts"+string(filepath.Separator)):
+		default:
+			pages = append(pages, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var layout []byte
+	if v.opts.Layout != "" {
+		if layout, err = ioutil.ReadFile(filepath.Join(v.dir, "layouts", v.opts.Layout+v.opts.Extension)); err != nil {
+			return err
+		}
+	}
+
+	loaded := make(map[string]*template.Template)
+	for _, path := range pages {
+		rel, _ := filepath.Rel(v.dir, path)
+		name := filepath.ToSlash(strings.TrimSuffix(rel, v.opts.Extension))
+
+		t := template.New(name).Funcs(v.opts.Funcs)
+		if layout != nil {
+			if _, err := t.New("layout").Parse(string(layout)); err != nil {
+				return err
+			}
+		}
+
+		if len(partials) > 0 {
+			if _, err := t.ParseFiles(partials...); err != nil {
+				return err
+			}
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := t.Parse(string(content)); err != nil {
+			return err
+		}
+
+		loaded[name] = t
+	}
+
+	v.mutex.Lock()
+	v.pages = loaded
+	v.mutex.Unlock()
+
+	return nil
+}
+
+// HTML renders template name with data as text/html, it's same as Render
+func (c *Context) HTML(name string, data interface{}) error {
+	return c.Render(name, data)
+}
@@ -0,0 +1,71 @@
+package context
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeViews(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestViews(t *testing.T) {
+	dir := writeViews(t, map[string]string{
+		"layouts/main.html":  `<html><title>{{block "title" .}}Zebra{{end}}</title><body>{{template "content" .}}</body></html>`,
+		"partials/user.html": `{{define "user"}}<li>{{.}}</li>{{end}}`,
+		"users/index.html":   `{{define "title"}}Users{{end}}{{define "content"}}<ul>{{range .}}{{template "user" .}}{{end}}</ul>{{end}}`,
+		"home.html":          `{{define "content"}}<p>home</p>{{end}}`,
+	})
+
+	views, err := NewViews(dir, ViewOptions{Layout: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, rw := newTestContext("GET", "/users")
+	ctx.SetRenderer(views)
+	if err := ctx.HTML("users/index", []string{"<alice>", "bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `<html><title>Users</title><body><ul><li>&lt;alice&gt;</li><li>bob</li></ul></body></html>`
+	if rw.Body.String() != expect || !strings.HasPrefix(rw.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("unexpected page %s", rw.Body.String())
+	}
+
+	ctx, rw = newTestContext("GET", "/")
+	ctx.SetRenderer(views)
+	ctx.HTML("home", nil)
+	if rw.Body.String() != `<html><title>Zebra</title><body><p>home</p></body></html>` {
+		t.Errorf("unexpected page %s", rw.Body.String())
+	}
+}
+
+func TestViewsReload(t *testing.T) {
+	dir := writeViews(t, map[string]string{"hello.html": `hello {{.}}`})
+
+	views, err := NewViews(dir, ViewOptions{Reload: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "hello.html"), []byte(`hi {{.}}`), 0644)
+
+	ctx, rw := newTestContext("GET", "/")
+	ctx.SetRenderer(views)
+	ctx.HTML("hello", "zebra")
+	if rw.Body.String() != "hi zebra" {
+		t.Errorf("expect reloaded template, got %s", rw.Body.String())
+	}
+}
@@ -0,0 +1,181 @@
+package context
+
+import (
+	"bufio"
+	"errors"
+	"github.com/raythorn/zebra/log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// responseWriter tracks status and size of response, it's the innermost writer of Context, and
+// makes WriteHeader idempotent, only the first status is sent, later ones are ignored. It's safe
+// to write from another goroutine while handler checks Written or Status, such as timeout midware.
+type responseWriter struct {
+	http.ResponseWriter
+	mutex   sync.Mutex
+	status  int
+	size    int64
+	written bool
+	warned  bool
+	// first error of Write, usually client disconnected
+	err error
+}
+
+func (w *responseWriter) reset(rw http.ResponseWriter) {
+	w.ResponseWriter = rw
+	w.status = http.StatusOK
+	w.size = 0
+	w.written = false
+	w.warned = false
+	w.err = nil
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.writeHeader(code)
+}
+
+func (w *responseWriter) writeHeader(code int) {
+	if w.written {
+		if !w.warned {
+			w.warned = true
+			log.Warning("WriteHeader: status %d ignored, %d already written", code, w.status)
+		}
+		return
+	}
+
+	w.written = true
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.written {
+		w.writeHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.written {
+			w.writeHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijack, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Web server doesn't support Hijack!")
+	}
+
+	return hijack.Hijack()
+}
+
+func (w *responseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+
+	return nil
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// state returns status, size and whether written
+func (w *responseWriter) state() (int, int64, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.status, w.size, w.written
+}
+
+// error returns the first error of Write
+func (w *responseWriter) error() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.err
+}
+
+// Unwrap returns the original writer for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns http status code of response, 200 if not written yet
+func (c *Context) Status() int {
+	status, _, _ := c.writer.state()
+	return status
+}
+
+// Written returns true if status of response has been written, headers can not be changed then
+func (c *Context) Written() bool {
+	_, _, written := c.writer.state()
+	return written
+}
+
+// Size returns bytes of response body written
+func (c *Context) Size() int64 {
+	_, size, _ := c.writer.state()
+	return size
+}
+
+// Trailer declares key as a trailer of response in Trailer header, so clients know it will be sent
+// after the body, it must be called before the status or body written.
+func (c *Context) Trailer(key string) error {
+	if err := c.trailerSupported(); err != nil {
+		return err
+	}
+
+	if c.Written() {
+		return errors.New("Trailer: headers already written")
+	}
+
+	c.rw.Header().Add("Trailer", http.CanonicalHeaderKey(key))
+	return nil
+}
+
+// SetTrailer sets value of trailer key, it's sent after the body, such as checksum of streamed
+// data. Keys not declared by Trailer are sent as well, but clients may not expect them.
+func (c *Context) SetTrailer(key, value string) error {
+	if err := c.trailerSupported(); err != nil {
+		return err
+	}
+
+	c.rw.Header().Set(http.TrailerPrefix+http.CanonicalHeaderKey(key), value)
+	return nil
+}
+
+// trailerSupported checks if trailers can be sent, they need chunked body of HTTP/1.1 or later
+func (c *Context) trailerSupported() error {
+	if !c.request.ProtoAtLeast(1, 1) {
+		return errors.New("Trailer: not supported by " + c.request.Proto)
+	}
+
+	return nil
+}
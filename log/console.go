@@ -2,13 +2,15 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
 //Console logger, print log message to console, and each message level has
-//different color
+//different color, it prints plain message without color if output set by SetOutput
 type console struct {
 	level  int
+	out    io.Writer
 	colors []string
 	cache  chan *Record
 	quit   chan bool
@@ -18,6 +20,7 @@ type console struct {
 func NewConsoleLogger(level int) Logger {
 	c := &console{
 		level: level,
+		out:   os.Stdout,
 		colors: []string{
 			DEBUG: "\033[32m",
 			INFO:  "\033[36m",
@@ -35,6 +38,20 @@ func NewConsoleLogger(level int) Logger {
 	return c
 }
 
+//newWriterLogger creates a console logger prints plain message to out
+func newWriterLogger(level int, out io.Writer) Logger {
+	c := &console{
+		level: level,
+		out:   out,
+		cache: make(chan *Record, BUFFER_CAPACITY),
+		quit:  make(chan bool),
+	}
+
+	go c.run()
+
+	return c
+}
+
 //run is goroutine which actually handle the log message
 func (c *console) run() {
 
@@ -63,9 +80,19 @@ func (c *console) flush() {
 
 func (c *console) write(record *Record) {
 
-	timestr := record.timestamp.Format("2006/01/02 15:04:04")
+	if record.structured {
+		fmt.Fprintln(c.out, record.message)
+		return
+	}
+
+	timestr := record.timestamp.Format("2006/01/02 15:04:05")
+
+	if c.colors == nil {
+		fmt.Fprintln(c.out, timestr, "[", levelstr[record.level], "]:", record.message)
+		return
+	}
 
-	fmt.Fprintln(os.Stdout, c.colors[record.level], timestr, "[", levelstr[record.level], "]:", record.message, "\033[0m")
+	fmt.Fprintln(c.out, c.colors[record.level], timestr, "[", levelstr[record.level], "]:", record.message, "\033[0m")
 }
 
 func (c *console) Level() int {
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+//Fields are key-values of structured log
+type Fields map[string]interface{}
+
+//Entry is a structured logger with fields, messages are printed as JSON lines with the fields
+//and "level", "time", "msg", such as
+//
+//	{"level":"info","msg":"user login","request_id":"42","time":"2016-01-02T15:04:05Z"}
+//
+//fields named level, time or msg are overridden.
+type Entry struct {
+	fields Fields
+}
+
+//WithFields returns a structured logger with fields, it's filtered by SetLevel and printed by
+//all loggers same as Debug/Info/...
+func WithFields(fields map[string]interface{}) *Entry {
+	return (&Entry{}).WithFields(fields)
+}
+
+//WithFields returns a new structured logger with fields of e and fields
+func (e *Entry) WithFields(fields map[string]interface{}) *Entry {
+	merged := make(Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return &Entry{fields: merged}
+}
+
+//Debug print debug message with fields
+func (e *Entry) Debug(format string, args ...interface{}) {
+	log4f.log(DEBUG, e.fields, format, args...)
+}
+
+//Info print infomation message with fields
+func (e *Entry) Info(format string, args ...interface{}) {
+	log4f.log(INFO, e.fields, format, args...)
+}
+
+//Warn print warning message with fields
+func (e *Entry) Warn(format string, args ...interface{}) {
+	log4f.log(WARN, e.fields, format, args...)
+}
+
+//Error print error message with fields
+func (e *Entry) Error(format string, args ...interface{}) {
+	log4f.log(ERROR, e.fields, format, args...)
+}
+
+// structure formats record with fields as a JSON line, fields can not be marshaled are printed
+// with their error
+func structure(record *Record, fields Fields) string {
+	line := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		line[k] = v
+	}
+
+	line["level"] = strings.ToLower(levelstr[record.level])
+	line["time"] = record.timestamp.Format(time.RFC3339Nano)
+	line["msg"] = record.message
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{
+			"level": line["level"],
+			"time":  line["time"],
+			"msg":   record.message,
+			"error": "Log: marshal fields failed, " + err.Error(),
+		})
+	}
+
+	return string(data)
+}
@@ -103,7 +103,12 @@ func (f *file) sweep() {
 
 func (f *file) write(record *Record) {
 
-	timestr := record.timestamp.Format("2006/01/02 15:04:04")
+	if record.structured {
+		fmt.Fprintln(f.file, record.message)
+		return
+	}
+
+	timestr := record.timestamp.Format("2006/01/02 15:04:05")
 
 	fmt.Fprintln(f.file, timestr, "[", levelstr[record.level], "]:", record.message)
 }
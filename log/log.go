@@ -3,8 +3,10 @@ package log
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"time"
 )
 
@@ -39,6 +41,8 @@ type Record struct {
 	verbose   string
 	message   string
 	timestamp time.Time
+	// message is a JSON line of structured log, it's printed as is
+	structured bool
 }
 
 //Logger interface, all supported logger MUST implement this interface
@@ -53,11 +57,17 @@ type Logger interface {
 
 type logger struct {
 	verbose bool
+	// minimal level of messages, accessed atomically
+	level   int32
 	channel map[string]Logger
 }
 
 //Internal log function
-func (l *logger) log(level int, format string, args ...interface{}) {
+func (l *logger) log(level int, fields map[string]interface{}, format string, args ...interface{}) {
+
+	if level < int(atomic.LoadInt32(&l.level)) {
+		return
+	}
 
 	skip := true
 	for _, logger := range l.channel {
@@ -91,6 +101,11 @@ func (l *logger) log(level int, format string, args ...interface{}) {
 		timestamp: time.Now(),
 	}
 
+	if fields != nil {
+		record.message = structure(record, fields)
+		record.structured = true
+	}
+
 	for _, logger := range l.channel {
 
 		if logger.Level() <= level {
@@ -128,6 +143,29 @@ func Add(logger string, level int, args ...interface{}) error {
 	return err
 }
 
+//SetOutput replaces the console logger with one prints plain messages to out, such as a
+//RotatingFile, the level of console logger is kept
+func SetOutput(out io.Writer) {
+	level := DEBUG
+	if c, ok := log4f.channel["console"]; ok {
+		level = c.Level()
+		c.Close()
+	}
+
+	log4f.channel["console"] = newWriterLogger(level, out)
+}
+
+//SetLevel sets the minimal level of messages, messages below it are dropped by all loggers,
+//such as SetLevel(log.INFO) silences debug messages in production. It's DEBUG by default.
+func SetLevel(level int) {
+	atomic.StoreInt32(&log4f.level, int32(level))
+}
+
+//GetLevel returns the minimal level of messages set by SetLevel
+func GetLevel() int {
+	return int(atomic.LoadInt32(&log4f.level))
+}
+
 //Close log engine
 func Close() {
 	log4f.close()
@@ -135,33 +173,38 @@ func Close() {
 
 //Debug print debug message
 func Debug(format string, args ...interface{}) {
-	log4f.log(DEBUG, format, args...)
+	log4f.log(DEBUG, nil, format, args...)
 }
 
 //Info print infomation message
 func Info(format string, args ...interface{}) {
-	log4f.log(INFO, format, args...)
+	log4f.log(INFO, nil, format, args...)
 }
 
 //Warning print warning message
 func Warning(format string, args ...interface{}) {
-	log4f.log(WARN, format, args...)
+	log4f.log(WARN, nil, format, args...)
+}
+
+//Warn print warning message, same as Warning
+func Warn(format string, args ...interface{}) {
+	log4f.log(WARN, nil, format, args...)
 }
 
 //Error print error message
 func Error(format string, args ...interface{}) {
-	log4f.log(ERROR, format, args...)
+	log4f.log(ERROR, nil, format, args...)
 }
 
 //Fatal print fatal error message, and app will quit if this function called
 func Fatal(format string, args ...interface{}) {
-	log4f.log(FATAL, format, args...)
+	log4f.log(FATAL, nil, format, args...)
 	os.Exit(0)
 }
 
 //Panic print panic message, and app will trigger panic message if called
 func Panic(format string, args ...interface{}) {
-	log4f.log(PANIC, format, args...)
+	log4f.log(PANIC, nil, format, args...)
 	msg := format
 	if len(args) > 0 {
 		msg = fmt.Sprintf(format, args...)
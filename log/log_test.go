@@ -1,16 +1,96 @@
 package log
 
 import (
-	"fmt"
-	"github.com/raythorn/zebra/log"
+	"encoding/json"
+	"strings"
+	"sync"
 	"testing"
 )
 
+//recorder is a Logger records messages for testing
+type recorder struct {
+	mutex    sync.Mutex
+	level    int
+	messages []string
+}
+
+func (r *recorder) Level() int {
+	return r.level
+}
+
+func (r *recorder) Write(record *Record) {
+	r.mutex.Lock()
+	r.messages = append(r.messages, levelstr[record.level]+": "+record.message)
+	r.mutex.Unlock()
+}
+
+func (r *recorder) Close() {}
+
+func record(t *testing.T) *recorder {
+	r := &recorder{level: DEBUG}
+	log4f.channel["recorder"] = r
+	t.Cleanup(func() {
+		delete(log4f.channel, "recorder")
+		SetLevel(DEBUG)
+	})
+
+	return r
+}
+
 func TestLog(t *testing.T) {
+	r := record(t)
+
+	Debug("Test Debug")
+	Info("Test %s", "Info")
+	Warning("Test Warning")
+
+	if len(r.messages) != 3 || r.messages[1] != "Info: Test Info" {
+		t.Errorf("unexpected messages %v", r.messages)
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	r := record(t)
+
+	SetLevel(WARN)
+	if GetLevel() != WARN {
+		t.Errorf("expect level WARN, got %d", GetLevel())
+	}
+
+	Debug("dropped")
+	Info("dropped")
+	Warn("kept")
+	Error("kept")
+
+	if len(r.messages) != 2 || r.messages[0] != "Warning: kept" || r.messages[1] != "Error: kept" {
+		t.Errorf("unexpected messages %v", r.messages)
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	r := record(t)
+
+	entry := WithFields(map[string]interface{}{"request_id": "42", "route": "/users/:id"})
+	entry.WithFields(map[string]interface{}{"status": 200}).Info("served %s", "/users/1")
+	entry.Debug("fields not changed")
+
+	SetLevel(INFO)
+	entry.Debug("dropped")
+
+	if len(r.messages) != 2 {
+		t.Fatalf("unexpected messages %v", r.messages)
+	}
 
-	fmt.Println("Start testing log...")
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(r.messages[0], "Info: ")), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["level"] != "info" || line["msg"] != "served /users/1" || line["request_id"] != "42" ||
+		line["route"] != "/users/:id" || line["status"] != float64(200) || line["time"] == nil {
+		t.Errorf("unexpected structured log %v", line)
+	}
 
-	log.Debug("Test Debug")
-	log.Info("Test Info")
-	log.Warning("Test Warning")
+	if strings.Contains(r.messages[1], "status") {
+		t.Errorf("expect fields of entry not changed, got %s", r.messages[1])
+	}
 }
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+
+	r, err := NewRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := []byte(strings.Repeat("x", 39) + "\n")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Write(line)
+		}()
+	}
+	wg.Wait()
+	r.Close()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != 80 && name != path {
+			t.Errorf("expect 2 lines in %s, got %d bytes", name, len(data))
+		}
+		if len(data) > 100 {
+			t.Errorf("%s exceeds max size, %d bytes", name, len(data))
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expect only 2 backups kept")
+	}
+
+	if _, err := r.Write(line); err != os.ErrClosed {
+		t.Errorf("expect ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	defer func() { log4f.channel["console"] = NewConsoleLogger(DEBUG) }()
+
+	var buffer bytes.Buffer
+	SetOutput(&buffer)
+	Info("to buffer")
+	log4f.channel["console"].Close()
+
+	if !strings.Contains(buffer.String(), "[ Info ]: to buffer") || strings.Contains(buffer.String(), "\033[") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
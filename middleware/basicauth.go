@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"strconv"
+)
+
+//Key of authenticated user name in context, it's saved with ctx.SetValue, so it can not be forged by
+//query or headers of request, see BasicAuthUser
+const (
+	BasicAuthUserKey = "com.raythorn.falcon.auth.user"
+)
+
+//BasicAuth returns a midware which authenticates requests with HTTP Basic auth, users is a map
+//of username to password. If credentials fail, 401 with WWW-Authenticate will be responded, and
+//on success, username is saved in context with BasicAuthUserKey, which can be retrieved with
+//BasicAuthUser.
+func BasicAuth(users map[string]string, realm string) router.Midware {
+
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm)
+
+	// Compare hashes, so the comparison takes the same time regardless of password length
+	hashes := make(map[string][32]byte, len(users))
+	for user, password := range users {
+		hashes[user] = sha256.Sum256([]byte(password))
+	}
+
+	return func(ctx *context.Context) bool {
+		user, password, ok := ctx.Request().BasicAuth()
+		if ok {
+			expect, found := hashes[user]
+			if !found {
+				expect = sha256.Sum256([]byte(user + ":" + realm))
+			}
+
+			given := sha256.Sum256([]byte(password))
+			if subtle.ConstantTimeCompare(given[:], expect[:]) == 1 && found {
+				ctx.SetValue(BasicAuthUserKey, user)
+				return true
+			}
+		}
+
+		ctx.Header("WWW-Authenticate", challenge)
+		http.Error(ctx.ResponseWriter(), http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+}
+
+//BasicAuthUser returns name of user authenticated by BasicAuth midware, "" if not authenticated
+func BasicAuthUser(ctx *context.Context) string {
+	v, _ := ctx.Value(BasicAuthUserKey)
+	user, _ := v.(string)
+	return user
+}
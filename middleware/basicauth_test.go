@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newBasicAuthContext(target, user, password string) (*context.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest("GET", target, nil)
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+
+	rw := httptest.NewRecorder()
+	ctx := context.New()
+	ctx.Reset(rw, req)
+	return ctx, rw
+}
+
+func TestBasicAuth(t *testing.T) {
+	midware := BasicAuth(map[string]string{"bob": "secret"}, "admin")
+
+	cases := []struct {
+		name, user, password string
+		ok                   bool
+	}{
+		{"valid", "bob", "secret", true},
+		{"wrong password", "bob", "guess", false},
+		{"unknown user", "alice", "secret", false},
+		{"missing header", "", "", false},
+	}
+
+	for _, c := range cases {
+		ctx, rw := newBasicAuthContext("/admin", c.user, c.password)
+		if midware(ctx) != c.ok {
+			t.Errorf("%s: expect %v", c.name, c.ok)
+		}
+
+		if c.ok {
+			if user := BasicAuthUser(ctx); user != c.user {
+				t.Errorf("%s: expect user %s, got %q", c.name, c.user, user)
+			}
+			continue
+		}
+
+		if rw.Code != http.StatusUnauthorized || rw.Header().Get("WWW-Authenticate") != `Basic realm="admin"` {
+			t.Errorf("%s: expect 401 with challenge, got %d %q", c.name, rw.Code, rw.Header().Get("WWW-Authenticate"))
+		}
+
+		if user := BasicAuthUser(ctx); user != "" {
+			t.Errorf("%s: expect no user, got %q", c.name, user)
+		}
+	}
+}
+
+func TestBasicAuthForged(t *testing.T) {
+	req := httptest.NewRequest("GET", "/public?"+BasicAuthUserKey+"=admin", nil)
+	req.Header.Set(BasicAuthUserKey, "admin")
+
+	ctx := context.New()
+	ctx.Reset(httptest.NewRecorder(), req)
+	if user := BasicAuthUser(ctx); user != "" {
+		t.Errorf("expect forged user ignored, got %q", user)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Derek Ray. All rights reserved.
+// Use of this source code is governed by Apache License 2.0
+// that can be found in the LICENSE file.
+
+// Package brotli registers Brotli("br") compressor for middleware.Compress, it's a separated
+// package to keep the brotli dependency optional, import it for side effects:
+//
+//	import _ "github.com/raythorn/zebra/middleware/brotli"
+//
+//	zebra.Use(middleware.Compress())
+package brotli
+
+import (
+	"github.com/andybalholm/brotli"
+	"github.com/raythorn/zebra/middleware"
+	"io"
+)
+
+func init() {
+	middleware.RegisterCompressor("br", NewCompressor)
+}
+
+// NewCompressor creates a brotli writer with level, brotli.DefaultCompression is used if level
+// out of range, such as gzip.DefaultCompression
+func NewCompressor(w io.Writer, level int) (middleware.Compressor, error) {
+	if level < brotli.BestSpeed || level > brotli.BestCompression {
+		level = brotli.DefaultCompression
+	}
+
+	return brotli.NewWriterLevel(w, level), nil
+}
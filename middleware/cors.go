@@ -0,0 +1,122 @@
+// Copyright 2016 Derek Ray. All rights reserved.
+// Use of this source code is governed by Apache License 2.0
+// that can be found in the LICENSE file.
+
+// Package middleware provides ready-made midwares for zebra, register them with
+// zebra.Use or router.Use for global, or with Group.Before for a group of routes.
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//CORSOptions configure the CORS midware
+//
+//	AllowOrigins     origins allowed to access the resource, "*" allows all origins
+//	AllowMethods     methods allowed in preflight, default GET/HEAD/PUT/PATCH/POST/DELETE
+//	AllowHeaders     headers allowed in preflight, request headers will be echoed if empty
+//	ExposeHeaders    headers exposed to client for actual request
+//	AllowCredentials allow cookies and authorization headers, cannot be used with "*"
+//	MaxAge           seconds the preflight result can be cached, 0 means not set
+type CORSOptions struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+//CORS returns a midware which handles Cross-Origin Resource Sharing, the request Origin will
+//be validated against AllowOrigins and echoed back if allowed. Preflight requests are answered
+//with 204 and intercepted, so no handler will be called for them.
+//
+//Use it globally with Use, or for a group with Group.CORS so different groups have different
+//policies. If both set, the one of the innermost group wins, and the global one is skipped.
+func CORS(opts CORSOptions) router.Midware {
+
+	wildcard := false
+	origins := make(map[string]bool)
+	for _, origin := range opts.AllowOrigins {
+		if origin == "*" {
+			wildcard = true
+		}
+		origins[strings.ToLower(origin)] = true
+	}
+
+	if wildcard && opts.AllowCredentials {
+		log.Panic("CORS: wildcard origin cannot be used with credentials")
+	}
+
+	methods := opts.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "PUT", "PATCH", "POST", "DELETE"}
+	}
+
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(opts.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(opts.ExposeHeaders, ", ")
+
+	return func(ctx *context.Context) bool {
+		origin := ctx.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		if v, _ := ctx.Value(router.GroupCORSKey); v == true {
+			return true
+		}
+
+		header := ctx.ResponseWriter().Header()
+		header.Add("Vary", "Origin")
+
+		preflight := ctx.Method() == "OPTIONS" && ctx.Get("Access-Control-Request-Method") != ""
+
+		if !wildcard && !origins[strings.ToLower(origin)] {
+			if preflight {
+				ctx.WriteHeader(http.StatusForbidden)
+				return false
+			}
+			return true
+		}
+
+		if wildcard {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if opts.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			return true
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if headers := ctx.Get("Access-Control-Request-Headers"); headers != "" {
+			header.Set("Access-Control-Allow-Headers", headers)
+		}
+
+		if opts.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+		}
+
+		ctx.WriteHeader(http.StatusNoContent)
+		return false
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroupCORS(t *testing.T) {
+	handler := func(ctx *context.Context) { ctx.WriteString("ok") }
+	auth := func(ctx *context.Context) bool {
+		if ctx.Get("Authorization") == "" {
+			ctx.Abort(401)
+			return false
+		}
+		return true
+	}
+
+	r := router.New()
+	r.Use(CORS(CORSOptions{AllowOrigins: []string{"https://admin.com"}}))
+	r.Get("/status", handler)
+	g := &router.Group{}
+	r.Group("/public", g.Get("/docs", handler)).CORS(CORS(CORSOptions{AllowOrigins: []string{"*"}}))
+	r.Group("/api", g.Get("/users", handler), g.Post("/users", handler)).
+		CORS(CORS(CORSOptions{AllowOrigins: []string{"https://app.com"}, AllowMethods: []string{"GET", "POST"}})).
+		Before(auth)
+
+	cases := []struct {
+		method, path, origin string
+		code                 int
+		allow                string
+	}{
+		{"OPTIONS", "/api/users", "https://app.com", 204, "https://app.com"},
+		{"OPTIONS", "/api/users", "https://admin.com", 403, ""},
+		{"OPTIONS", "/public/docs", "https://any.com", 204, "*"},
+		{"OPTIONS", "/status", "https://admin.com", 204, "https://admin.com"},
+		{"GET", "/public/docs", "https://any.com", 200, "*"},
+		{"GET", "/api/users", "https://app.com", 401, "https://app.com"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("Origin", c.origin)
+		if c.method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+		if rw.Code != c.code || rw.Header().Get("Access-Control-Allow-Origin") != c.allow {
+			t.Errorf("%s %s from %s: expect %d %q, got %d %q", c.method, c.path, c.origin, c.code, c.allow,
+				rw.Code, rw.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//GzipOptions configure the Gzip and Compress midwares
+//
+//	Level     compression level, gzip.DefaultCompression if 0, it's passed to compressors as is
+//	MinSize   bodies smaller than MinSize bytes are not compressed, 1024 if 0
+//	Encodings encodings offered in preferred order, used when client prefers them equally,
+//	          all registered ones, "br", "gzip" and "deflate", if empty. Gzip only offers gzip.
+type GzipOptions struct {
+	Level     int
+	MinSize   int
+	Encodings []string
+}
+
+//Compressor is a writer compresses data written to it
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+//CompressorFunc creates a compressor writes compressed data to w with level
+type CompressorFunc func(w io.Writer, level int) (Compressor, error)
+
+var compressors = map[string]CompressorFunc{
+	"gzip": func(w io.Writer, level int) (Compressor, error) {
+		return gzip.NewWriterLevel(w, level)
+	},
+	"deflate": func(w io.Writer, level int) (Compressor, error) {
+		return flate.NewWriter(w, level)
+	},
+}
+
+//preferredEncodings are the default encodings offered in preferred order
+var preferredEncodings = []string{"br", "gzip", "deflate"}
+
+//RegisterCompressor registers compressor of encoding, such as "br", it must be called before
+//Compress midwares created. Package middleware/brotli registers "br" when imported.
+func RegisterCompressor(encoding string, fn CompressorFunc) {
+	compressors[encoding] = fn
+}
+
+//Content types which are already compressed, prefix matched
+var incompressibleTypes = []string{
+	"image/", "video/", "audio/",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/x-rar-compressed", "application/x-7z-compressed",
+	"font/woff", "font/woff2",
+}
+
+//Gzip returns a midware which compresses response body with gzip if client accepts it,
+//already compressed content types and small bodies are sent as is.
+func Gzip(opts ...GzipOptions) router.Midware {
+	opt := GzipOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	opt.Encodings = []string{"gzip"}
+
+	return Compress(opt)
+}
+
+//Compress returns a midware like Gzip, but negotiates encoding with Accept-Encoding of client,
+//the encoding with highest q-value is used, and the preferred one of Encodings if same. Body
+//is sent as is if client accepts none of them.
+func Compress(opts ...GzipOptions) router.Midware {
+
+	opt := GzipOptions{Level: gzip.DefaultCompression, MinSize: 1024}
+	if len(opts) > 0 {
+		if opts[0].Level != 0 {
+			opt.Level = opts[0].Level
+		}
+		if opts[0].MinSize != 0 {
+			opt.MinSize = opts[0].MinSize
+		}
+		opt.Encodings = opts[0].Encodings
+	}
+
+	if len(opt.Encodings) == 0 {
+		opt.Encodings = preferredEncodings
+	}
+
+	offered := []string{}
+	for _, encoding := range opt.Encodings {
+		if _, ok := compressors[encoding]; ok {
+			offered = append(offered, encoding)
+		}
+	}
+
+	return func(ctx *context.Context) bool {
+
+		ctx.ResponseWriter().Header().Add("Vary", "Accept-Encoding")
+
+		if ctx.Method() == "HEAD" {
+			return true
+		}
+
+		encoding := negotiateEncoding(ctx.Get("Accept-Encoding"), offered)
+		if encoding == "" {
+			return true
+		}
+
+		cw := &compressWriter{ResponseWriter: ctx.ResponseWriter(), opts: opt, encoding: encoding, status: http.StatusOK}
+		ctx.SetResponseWriter(cw)
+		ctx.Defer(cw.close)
+
+		return true
+	}
+}
+
+//negotiateEncoding returns the offered encoding with highest q-value in Accept-Encoding header,
+//the first offered one wins if same, "*" matches any encoding not listed, and q=0 rejects.
+func negotiateEncoding(header string, offered []string) string {
+	qualities := map[string]float64{}
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qualities[name] = q
+	}
+
+	best, bestq := "", 0.0
+	for _, encoding := range offered {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+
+		if q > bestq {
+			best, bestq = encoding, q
+		}
+	}
+
+	return best
+}
+
+func compressible(contentType string) bool {
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//compressWriter buffers body until MinSize reached, then decides whether to compress it
+type compressWriter struct {
+	http.ResponseWriter
+	opts     GzipOptions
+	encoding string
+	status   int
+	buffer   []byte
+	decided  bool
+	cw       Compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) >= w.opts.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+func (w *compressWriter) Flush() {
+	w.decide(len(w.buffer) >= w.opts.MinSize)
+
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide writes header and buffered data, the body will be compressed if large is true and
+// content type is compressible
+func (w *compressWriter) decide(large bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+
+	header := w.ResponseWriter.Header()
+	contentType := header.Get("Content-Type")
+	if contentType == "" && len(w.buffer) > 0 {
+		contentType = http.DetectContentType(w.buffer)
+		header.Set("Content-Type", contentType)
+	}
+
+	bodyless := w.status == http.StatusNoContent || w.status == http.StatusNotModified || w.status < 200
+	if large && !bodyless && compressible(contentType) && header.Get("Content-Encoding") == "" {
+		cw, err := compressors[w.encoding](w.ResponseWriter, w.opts.Level)
+		if err != nil {
+			// Invalid level, fallback to default level of compressor
+			cw, err = compressors[w.encoding](w.ResponseWriter, -1)
+		}
+
+		if err == nil {
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+			w.cw = cw
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buffer)
+	} else {
+		_, err = w.ResponseWriter.Write(buffer)
+	}
+
+	return err
+}
+
+// close flushes buffered data and closes compressor, it's deferred until request finished
+func (w *compressWriter) close() {
+	w.decide(false)
+
+	if w.cw != nil {
+		w.cw.Close()
+	}
+}
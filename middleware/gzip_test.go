@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	r := router.New()
+	r.Use(Gzip(GzipOptions{MinSize: 16}))
+	r.Get("/large", func(ctx *context.Context) {
+		ctx.JSON(map[string]string{"data": strings.Repeat("zebra", 100)}, false)
+	})
+	r.Get("/small", func(ctx *context.Context) {
+		ctx.WriteString("zebra")
+	})
+
+	req := httptest.NewRequest("GET", "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rw := httptest.NewRecorder()
+	r.Handle(rw, req)
+
+	if rw.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("large body should be compressed")
+	}
+	if rw.Header().Get("Content-Length") != "" {
+		t.Errorf("Content-Length of uncompressed body should be cleared, got %s", rw.Header().Get("Content-Length"))
+	}
+
+	gz, err := gzip.NewReader(rw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ioutil.ReadAll(gz); !strings.Contains(string(body), strings.Repeat("zebra", 100)) {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rw = httptest.NewRecorder()
+	r.Handle(rw, req)
+
+	if rw.Header().Get("Content-Encoding") != "" || rw.Body.String() != "zebra" {
+		t.Errorf("small body should not be compressed")
+	}
+
+	if rw.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("vary header not set")
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []string{"br", "gzip", "deflate"}
+	for header, expect := range map[string]string{
+		"gzip, deflate, br":         "br",
+		"gzip;q=0.5, deflate":       "deflate",
+		"deflate;q=0.5, gzip;q=0.5": "gzip",
+		"*":                         "br",
+		"*, br;q=0":                 "gzip",
+		"identity":                  "",
+		"gzip;q=0":                  "",
+		"":                          "",
+	} {
+		if encoding := negotiateEncoding(header, offered); encoding != expect {
+			t.Errorf("%q: expect %q, got %q", header, expect, encoding)
+		}
+	}
+}
+
+func TestCompressDeflate(t *testing.T) {
+	r := router.New()
+	r.Use(Compress(GzipOptions{MinSize: 16}))
+	r.Get("/large", func(ctx *context.Context) {
+		ctx.WriteString(strings.Repeat("zebra", 100))
+	})
+
+	req := httptest.NewRequest("GET", "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.8, deflate")
+	rw := httptest.NewRecorder()
+	r.Handle(rw, req)
+
+	if rw.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expect deflate, got %q", rw.Header().Get("Content-Encoding"))
+	}
+	if body, _ := ioutil.ReadAll(flate.NewReader(rw.Body)); string(body) != strings.Repeat("zebra", 100) {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/large", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	rw = httptest.NewRecorder()
+	r.Handle(rw, req)
+
+	if rw.Header().Get("Content-Encoding") != "" || rw.Body.String() != strings.Repeat("zebra", 100) {
+		t.Errorf("expect identity if br not registered, got %q", rw.Header().Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"strings"
+)
+
+//Paths of health checks, which are probed with internal host names and should never be redirected
+var HealthPaths = []string{"/health", "/healthz", "/readyz"}
+
+//CanonicalHost returns a midware which redirects requests whose Host differs from host to the
+//canonical one with code (301 if 0), path and query are preserved. Requests to localhost and
+//health checks are passed through.
+func CanonicalHost(host string, code int) router.Midware {
+
+	if code == 0 {
+		code = http.StatusMovedPermanently
+	}
+
+	host = strings.ToLower(host)
+	withPort := strings.Contains(host, ":")
+
+	return func(ctx *context.Context) bool {
+
+		current := strings.ToLower(ctx.Host())
+		if current == "localhost" || current == "127.0.0.1" || strings.HasPrefix(ctx.Request().Host, "[") {
+			return true
+		}
+
+		for _, path := range HealthPaths {
+			if ctx.URL() == path {
+				return true
+			}
+		}
+
+		if withPort {
+			current = strings.ToLower(ctx.Request().Host)
+		}
+
+		if current == host {
+			return true
+		}
+
+		http.Redirect(ctx.ResponseWriter(), ctx.Request(), ctx.Scheme()+"://"+host+ctx.Request().URL.RequestURI(), code)
+		return false
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newContext(method, target string) (*context.Context, *httptest.ResponseRecorder) {
+	rw := httptest.NewRecorder()
+	ctx := context.New()
+	ctx.Reset(rw, httptest.NewRequest(method, target, nil))
+	return ctx, rw
+}
+
+func TestCanonicalHostRedirect(t *testing.T) {
+	midware := CanonicalHost("www.example.com", http.StatusMovedPermanently)
+
+	ctx, rw := newContext("GET", "http://example.com/users?page=2")
+	if midware(ctx) {
+		t.Fatal("non-canonical host should be intercepted")
+	}
+
+	if rw.Code != http.StatusMovedPermanently {
+		t.Errorf("expect status 301, got %d", rw.Code)
+	}
+
+	if location := rw.Header().Get("Location"); location != "http://www.example.com/users?page=2" {
+		t.Errorf("unexpected location %s", location)
+	}
+}
+
+func TestCanonicalHostPassThrough(t *testing.T) {
+	midware := CanonicalHost("www.example.com", http.StatusMovedPermanently)
+
+	for _, target := range []string{"http://www.example.com/users", "http://localhost:8080/users", "http://example.com/healthz"} {
+		ctx, rw := newContext("GET", target)
+		if !midware(ctx) {
+			t.Errorf("%s should pass through", target)
+		}
+
+		if rw.Header().Get("Location") != "" {
+			t.Errorf("%s should not be redirected", target)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Key of JWT claims in context, claims are saved as JSON bytes with ctx.SetValue, so they can not be
+//forged by query or headers of request, see Context.BindClaims
+const (
+	JWTClaimsKey = context.ClaimsKey
+)
+
+//JWTOptions configure the JWT midware
+//
+//	Algorithm  signing algorithm, HS256/HS384/HS512 or RS256/RS384/RS512
+//	Secret     secret for HS algorithms
+//	PublicKey  public key for RS algorithms
+//	Header     header carries the bearer token, default "Authorization"
+//	Cookie     cookie carries the token, used if header has no token
+//	Leeway     tolerance of clock skew when checking exp and nbf
+type JWTOptions struct {
+	Algorithm string
+	Secret    []byte
+	PublicKey *rsa.PublicKey
+	Header    string
+	Cookie    string
+	Leeway    time.Duration
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+}
+
+//JWT returns a midware which verifies JSON Web Token of requests, the token is extracted from
+//the bearer Authorization header or cookie, and its signature, exp and nbf are checked. On any
+//failure 401 will be responded, and on success, claims are saved in context with JWTClaimsKey,
+//which can be retrieved with Claims.
+func JWT(opts JWTOptions) router.Midware {
+
+	if _, ok := jwtHashes[opts.Algorithm]; !ok {
+		log.Panic("JWT: unsupported algorithm %s", opts.Algorithm)
+	}
+
+	if strings.HasPrefix(opts.Algorithm, "HS") && len(opts.Secret) == 0 {
+		log.Panic("JWT: secret required for %s", opts.Algorithm)
+	}
+
+	if strings.HasPrefix(opts.Algorithm, "RS") && opts.PublicKey == nil {
+		log.Panic("JWT: public key required for %s", opts.Algorithm)
+	}
+
+	if opts.Header == "" {
+		opts.Header = "Authorization"
+	}
+
+	return func(ctx *context.Context) bool {
+		token := ""
+		if value := ctx.Request().Header.Get(opts.Header); len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+			token = strings.TrimSpace(value[7:])
+		} else if opts.Cookie != "" {
+			if cookie, err := ctx.Request().Cookie(opts.Cookie); err == nil {
+				token = cookie.Value
+			}
+		}
+
+		claims, err := verifyJWT(token, opts)
+		if err != nil {
+			log.Debug("%s", err.Error())
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(ctx.ResponseWriter(), http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return false
+		}
+
+		ctx.SetValue(JWTClaimsKey, claims)
+		return true
+	}
+}
+
+//Claims returns JWT claims saved by JWT midware, nil if not authenticated, use ctx.BindClaims
+//for claims in a struct
+func Claims(ctx *context.Context) map[string]interface{} {
+	v, _ := ctx.Value(JWTClaimsKey)
+	raw, ok := v.([]byte)
+	if !ok {
+		return nil
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil
+	}
+
+	return claims
+}
+
+// verifyJWT verifies token and returns the JSON payload
+func verifyJWT(token string, opts JWTOptions) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("JWT: malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("JWT: malformed header")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("JWT: malformed header")
+	}
+
+	// Algorithm must be the configured one, never trust the token to choose it
+	if header.Alg != opts.Algorithm {
+		return nil, errors.New("JWT: unexpected algorithm " + header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("JWT: malformed signature")
+	}
+
+	hash := jwtHashes[opts.Algorithm]
+	signing := []byte(parts[0] + "." + parts[1])
+
+	if strings.HasPrefix(opts.Algorithm, "HS") {
+		mac := hmac.New(hash.New, opts.Secret)
+		mac.Write(signing)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, errors.New("JWT: invalid signature")
+		}
+	} else {
+		h := hash.New()
+		h.Write(signing)
+		if err := rsa.VerifyPKCS1v15(opts.PublicKey, hash, h.Sum(nil), signature); err != nil {
+			return nil, errors.New("JWT: invalid signature")
+		}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("JWT: malformed payload")
+	}
+
+	var times struct {
+		Exp *float64 `json:"exp"`
+		Nbf *float64 `json:"nbf"`
+	}
+	if err := json.Unmarshal(payload, &times); err != nil {
+		return nil, errors.New("JWT: malformed payload")
+	}
+
+	now := time.Now()
+	if times.Exp != nil && now.Add(-opts.Leeway).After(time.Unix(int64(*times.Exp), 0)) {
+		return nil, errors.New("JWT: token expired")
+	}
+
+	if times.Nbf != nil && now.Add(opts.Leeway).Before(time.Unix(int64(*times.Nbf), 0)) {
+		return nil, errors.New("JWT: token not active")
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signHS256(payload string, secret []byte) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	body := base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(header + "." + body))
+
+	return header + "." + body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+
+	r := router.New()
+	r.Use(JWT(JWTOptions{Algorithm: "HS256", Secret: secret}))
+	r.Get("/me", func(ctx *context.Context) {
+		ctx.WriteString(Claims(ctx)["sub"].(string))
+	})
+
+	exp := time.Now().Add(time.Hour).Unix()
+	cases := []struct {
+		name  string
+		token string
+		code  int
+	}{
+		{"valid", signHS256(fmt.Sprintf(`{"sub":"42","exp":%d}`, exp), secret), http.StatusOK},
+		{"expired", signHS256(fmt.Sprintf(`{"sub":"42","exp":%d}`, time.Now().Add(-time.Hour).Unix()), secret), http.StatusUnauthorized},
+		{"not active", signHS256(fmt.Sprintf(`{"sub":"42","nbf":%d}`, exp), secret), http.StatusUnauthorized},
+		{"wrong secret", signHS256(`{"sub":"42"}`, []byte("other")), http.StatusUnauthorized},
+		{"malformed", "abc", http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+
+		if rw.Code != c.code {
+			t.Errorf("%s: expect %d, got %d", c.name, c.code, rw.Code)
+		}
+
+		if c.code == http.StatusOK && rw.Body.String() != "42" {
+			t.Errorf("%s: claims not saved, got %s", c.name, rw.Body.String())
+		}
+	}
+}
+
+func TestClaimsForged(t *testing.T) {
+	r := router.New()
+	r.Get("/public", func(ctx *context.Context) {
+		if claims := Claims(ctx); claims != nil {
+			t.Errorf("expect forged claims ignored, got %v", claims)
+		}
+	})
+
+	req := httptest.NewRequest("GET", "/public?"+JWTClaimsKey+`={"sub":"admin"}`, nil)
+	req.Header.Set(JWTClaimsKey, `{"sub":"admin"}`)
+	r.Handle(httptest.NewRecorder(), req)
+}
+
+func TestBindClaims(t *testing.T) {
+	secret := []byte("secret")
+
+	var claims struct {
+		Subject string   `json:"sub"`
+		Roles   []string `json:"roles"`
+	}
+	var errs []error
+
+	r := router.New()
+	r.Get("/public", func(ctx *context.Context) {
+		errs = append(errs, ctx.BindClaims(&claims))
+	})
+	r.Get("/me", func(ctx *context.Context) {
+		errs = append(errs, ctx.BindClaims(&claims))
+		var mismatch struct {
+			Subject int `json:"sub"`
+		}
+		errs = append(errs, ctx.BindClaims(&mismatch))
+	}, JWT(JWTOptions{Algorithm: "HS256", Secret: secret}))
+
+	r.Test("GET", "/public", nil)
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+signHS256(`{"sub":"42","roles":["admin"]}`, secret))
+	r.Handle(httptest.NewRecorder(), req)
+
+	if len(errs) != 3 || errs[0] != context.ErrNoClaims || errs[1] != nil || errs[2] == nil || errs[2] == context.ErrNoClaims {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	if claims.Subject != "42" || len(claims.Roles) != 1 || claims.Roles[0] != "admin" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
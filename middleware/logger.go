@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/router"
+	"time"
+)
+
+//AccessLog is a finished request logged by Logger
+type AccessLog struct {
+	Method  string
+	Path    string
+	Status  int
+	Size    int64
+	IP      string
+	Latency time.Duration
+}
+
+//LogFormatter formats an access log to one line
+type LogFormatter func(entry AccessLog) string
+
+//LoggerOptions configure the Logger midware
+//
+//	Level      level of access logs, log.DEBUG to log.ERROR
+//	Format     formats access logs, DefaultLogFormatter if nil
+//	Structured logs JSON lines with fields instead of Format, see log.WithFields, fields are
+//	           method, path, route, status, size, ip, latency_ms and request_id if set
+type LoggerOptions struct {
+	Level      int
+	Format     LogFormatter
+	Structured bool
+}
+
+//DefaultLoggerOptions is used by Logger if no options given
+var DefaultLoggerOptions = LoggerOptions{
+	Level:  log.INFO,
+	Format: DefaultLogFormatter,
+}
+
+//DefaultLogFormatter formats access log like `GET /users/42 200 512B 10.0.0.1 1.234ms`
+func DefaultLogFormatter(entry AccessLog) string {
+	return fmt.Sprintf("%s %s %d %dB %s %.3fms", entry.Method, entry.Path, entry.Status, entry.Size,
+		entry.IP, float64(entry.Latency)/float64(time.Millisecond))
+}
+
+//Logger returns a midware which logs one line per request with method, path, status, response
+//size, client ip and latency. Latency is measured from the midware called until the request
+//finished, so it should be the first midware registered.
+func Logger(opts ...LoggerOptions) router.Midware {
+
+	opt := DefaultLoggerOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Format == nil {
+		opt.Format = DefaultLogFormatter
+	}
+
+	return func(ctx *context.Context) bool {
+		ctx.Defer(func() {
+			entry := AccessLog{
+				Method:  ctx.Method(),
+				Path:    ctx.URL(),
+				Status:  ctx.Status(),
+				Size:    ctx.Size(),
+				IP:      ctx.Ip(),
+				Latency: ctx.Elapsed(),
+			}
+
+			if !opt.Structured {
+				logFunc(opt.Level, nil)("%s", opt.Format(entry))
+				return
+			}
+
+			fields := log.Fields{
+				"method":     entry.Method,
+				"path":       entry.Path,
+				"route":      ctx.Route(),
+				"status":     entry.Status,
+				"size":       entry.Size,
+				"ip":         entry.IP,
+				"latency_ms": float64(entry.Latency) / float64(time.Millisecond),
+			}
+			if id := ctx.RequestID(); id != "" {
+				fields["request_id"] = id
+			}
+
+			logFunc(opt.Level, log.WithFields(fields))("request")
+		})
+
+		return true
+	}
+}
+
+// logFunc returns log func of level, or the one of structured logger if entry is not nil
+func logFunc(level int, entry *log.Entry) func(format string, args ...interface{}) {
+	if entry != nil {
+		switch level {
+		case log.DEBUG:
+			return entry.Debug
+		case log.WARN:
+			return entry.Warn
+		case log.ERROR:
+			return entry.Error
+		default:
+			return entry.Info
+		}
+	}
+
+	switch level {
+	case log.DEBUG:
+		return log.Debug
+	case log.WARN:
+		return log.Warning
+	case log.ERROR:
+		return log.Error
+	default:
+		return log.Info
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var entries []AccessLog
+
+	r := router.New()
+	r.Use(Logger(LoggerOptions{Format: func(entry AccessLog) string {
+		entries = append(entries, entry)
+		return DefaultLogFormatter(entry)
+	}}))
+	r.Get("/users/:id", func(ctx *context.Context) {
+		time.Sleep(time.Millisecond)
+		ctx.WriteString("zebra")
+	})
+
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.RemoteAddr = "10.0.0.1:5678"
+	r.Handle(httptest.NewRecorder(), req)
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+
+	if len(entries) != 2 {
+		t.Fatalf("expect 2 access logs, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Method != "GET" || entry.Path != "/users/42" || entry.Status != 200 || entry.Size != 5 ||
+		entry.IP != "10.0.0.1" || entry.Latency < time.Millisecond {
+		t.Errorf("unexpected access log %+v", entry)
+	}
+
+	if entries[1].Status != 404 {
+		t.Errorf("expect 404 logged, got %d", entries[1].Status)
+	}
+}
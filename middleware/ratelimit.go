@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Buckets idle longer than RateLimitIdle will be evicted
+var RateLimitIdle = 10 * time.Minute
+
+//RateLimit returns a midware which limits requests with token bucket per client, rate is the
+//tokens refilled per second and burst is the bucket size. Clients are keyed by ctx.Ip() by
+//default, and a custom key function, like keying by API token header, can be supplied. If limit
+//exceeded, 429 with Retry-After will be responded.
+func RateLimit(rate float64, burst int, key ...func(*context.Context) string) router.Midware {
+
+	keyfunc := func(ctx *context.Context) string {
+		return ctx.Ip()
+	}
+	if len(key) > 0 && key[0] != nil {
+		keyfunc = key[0]
+	}
+
+	limiter := &limiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket), sweep: time.Now()}
+
+	return func(ctx *context.Context) bool {
+		wait := limiter.take(keyfunc(ctx), time.Now())
+		if wait == 0 {
+			return true
+		}
+
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(ctx.ResponseWriter(), http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return false
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	sweep   time.Time
+}
+
+// take takes a token from bucket of key, returns 0 if succeed, or the duration to wait for
+// next token
+func (l *limiter) take(key string, now time.Time) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	if now.Sub(l.sweep) > RateLimitIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > RateLimitIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.sweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	if l.rate <= 0 {
+		return RateLimitIdle
+	}
+
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitBurst(t *testing.T) {
+	midware := RateLimit(1, 2)
+
+	for i := 0; i < 2; i++ {
+		ctx, _ := newContext("GET", "/users")
+		if !midware(ctx) {
+			t.Fatalf("request %d within burst should pass", i)
+		}
+	}
+
+	ctx, rw := newContext("GET", "/users")
+	if midware(ctx) {
+		t.Fatal("request beyond burst should be limited")
+	}
+
+	if rw.Code != http.StatusTooManyRequests || rw.Header().Get("Retry-After") != "1" {
+		t.Errorf("expect 429 with Retry-After 1, got %d %q", rw.Code, rw.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	l := &limiter{rate: 2, burst: 2, buckets: make(map[string]*bucket), sweep: time.Now()}
+	now := l.sweep
+
+	l.take("a", now)
+	l.take("a", now)
+	if wait := l.take("a", now); wait != 500*time.Millisecond {
+		t.Errorf("expect wait 500ms for next token, got %s", wait)
+	}
+
+	if wait := l.take("a", now.Add(500*time.Millisecond)); wait != 0 {
+		t.Errorf("expect a token refilled, got wait %s", wait)
+	}
+
+	// Tokens never exceed burst however long the bucket idles
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if wait := l.take("a", now); wait != 0 {
+			t.Fatalf("expect bucket refilled to burst, got wait %s", wait)
+		}
+	}
+	if wait := l.take("a", now); wait == 0 {
+		t.Error("expect tokens capped by burst")
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	midware := RateLimit(1, 1, func(ctx *context.Context) string {
+		return ctx.Get("token")
+	})
+
+	for _, target := range []string{"/users?token=a", "/users?token=b"} {
+		ctx, _ := newContext("GET", target)
+		if !midware(ctx) {
+			t.Errorf("%s: expect first request of key passes", target)
+		}
+	}
+
+	ctx, _ := newContext("GET", "/users?token=a")
+	if midware(ctx) {
+		t.Error("expect second request of same key limited")
+	}
+}
+
+func TestRateLimitSweep(t *testing.T) {
+	l := &limiter{rate: 1, burst: 1, buckets: make(map[string]*bucket), sweep: time.Now()}
+	now := l.sweep
+
+	l.take("idle", now)
+	l.take("active", now.Add(RateLimitIdle))
+	if len(l.buckets) != 2 {
+		t.Fatalf("expect no sweep before idle duration, got %d buckets", len(l.buckets))
+	}
+
+	l.take("active", now.Add(RateLimitIdle+time.Second))
+	if _, ok := l.buckets["idle"]; ok || len(l.buckets) != 1 {
+		t.Errorf("expect idle bucket evicted, got %d buckets", len(l.buckets))
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+)
+
+//RequestID returns a midware which reads X-Request-ID header of request, or generates a new
+//UUID if absent or invalid, the id is saved in context and echoed in response header, and can
+//be retrieved with ctx.RequestID(). A custom generator can be supplied to replace UUID.
+func RequestID(generator ...func() string) router.Midware {
+
+	generate := UUID
+	if len(generator) > 0 && generator[0] != nil {
+		generate = generator[0]
+	}
+
+	return func(ctx *context.Context) bool {
+		id := ctx.Request().Header.Get(context.RequestIDKey)
+		if !validRequestID(id) {
+			id = generate()
+		}
+
+		ctx.Set(context.RequestIDKey, id)
+		ctx.Header(context.RequestIDKey, id)
+
+		return true
+	}
+}
+
+//UUID generates a random(version 4) UUID
+func UUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+//validRequestID accepts printable ascii ids not longer than 128, so a client cannot inject
+//arbitrary data into logs and headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
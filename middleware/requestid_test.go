@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newRequestIDContext(id string) (*context.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest("GET", "/users", nil)
+	if id != "" {
+		req.Header.Set(context.RequestIDKey, id)
+	}
+
+	rw := httptest.NewRecorder()
+	ctx := context.New()
+	ctx.Reset(rw, req)
+	return ctx, rw
+}
+
+func TestRequestID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	cases := []struct {
+		name, inbound string
+		kept         bool
+	}{
+		{"valid", "req-42.abc", true},
+		{"absent", "", false},
+		{"oversized", strings.Repeat("a", 129), false},
+		{"control character", "req\x0142", false},
+		{"space", "req 42", false},
+	}
+
+	for _, c := range cases {
+		ctx, rw := newRequestIDContext(c.inbound)
+		if !RequestID()(ctx) {
+			t.Fatalf("%s: request id midware should pass", c.name)
+		}
+
+		id := ctx.RequestID()
+		if c.kept && id != c.inbound {
+			t.Errorf("%s: expect inbound id kept, got %q", c.name, id)
+		}
+		if !c.kept && !uuid.MatchString(id) {
+			t.Errorf("%s: expect generated uuid, got %q", c.name, id)
+		}
+
+		if echoed := rw.Header().Get(context.RequestIDKey); echoed != id {
+			t.Errorf("%s: expect id echoed in response, got %q", c.name, echoed)
+		}
+	}
+}
+
+func TestRequestIDGenerator(t *testing.T) {
+	midware := RequestID(func() string { return "custom-1" })
+
+	ctx, rw := newRequestIDContext("")
+	midware(ctx)
+	if ctx.RequestID() != "custom-1" || rw.Header().Get(context.RequestIDKey) != "custom-1" {
+		t.Errorf("expect id of generator, got %q", ctx.RequestID())
+	}
+
+	ctx, _ = newRequestIDContext("bad\nid")
+	midware(ctx)
+	if ctx.RequestID() != "custom-1" {
+		t.Errorf("expect invalid inbound id replaced by generator, got %q", ctx.RequestID())
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"strconv"
+)
+
+//SecureOptions configure the SecureHeaders midware, empty fields are skipped
+//
+//	ContentTypeOptions    X-Content-Type-Options, "nosniff"
+//	FrameOptions          X-Frame-Options, "DENY" or "SAMEORIGIN"
+//	HSTSMaxAge            max-age seconds of Strict-Transport-Security, only sent over HTTPS
+//	HSTSIncludeSubdomains adds includeSubDomains to Strict-Transport-Security
+//	ContentSecurityPolicy Content-Security-Policy, such as "default-src 'self'"
+//	ReferrerPolicy        Referrer-Policy, such as "strict-origin-when-cross-origin"
+type SecureOptions struct {
+	ContentTypeOptions    string
+	FrameOptions          string
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+}
+
+//DefaultSecureOptions is used by SecureHeaders if no options given, Content-Security-Policy is
+//not set by default since it depends on the application
+var DefaultSecureOptions = SecureOptions{
+	ContentTypeOptions: "nosniff",
+	FrameOptions:       "SAMEORIGIN",
+	HSTSMaxAge:         365 * 24 * 3600,
+	ReferrerPolicy:     "strict-origin-when-cross-origin",
+}
+
+//SecureHeaders returns a midware which sets security headers to all responses, start from
+//DefaultSecureOptions to customize:
+//
+//	opts := middleware.DefaultSecureOptions
+//	opts.ContentSecurityPolicy = "default-src 'self'"
+//	zebra.Use(middleware.SecureHeaders(opts))
+func SecureHeaders(opts ...SecureOptions) router.Midware {
+
+	opt := DefaultSecureOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	hsts := ""
+	if opt.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(opt.HSTSMaxAge)
+		if opt.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(ctx *context.Context) bool {
+		header := ctx.ResponseWriter().Header()
+
+		if opt.ContentTypeOptions != "" {
+			header.Set("X-Content-Type-Options", opt.ContentTypeOptions)
+		}
+		if opt.FrameOptions != "" {
+			header.Set("X-Frame-Options", opt.FrameOptions)
+		}
+		if hsts != "" && ctx.Scheme() == "https" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if opt.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", opt.ContentSecurityPolicy)
+		}
+		if opt.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", opt.ReferrerPolicy)
+		}
+
+		return true
+	}
+}
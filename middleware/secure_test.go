@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	midware := SecureHeaders()
+
+	ctx, rw := newContext("GET", "http://example.com/")
+	midware(ctx)
+	header := rw.Header()
+	if header.Get("X-Content-Type-Options") != "nosniff" || header.Get("X-Frame-Options") != "SAMEORIGIN" ||
+		header.Get("Referrer-Policy") != "strict-origin-when-cross-origin" {
+		t.Errorf("unexpected default headers %v", header)
+	}
+	if header.Get("Strict-Transport-Security") != "" || header.Get("Content-Security-Policy") != "" {
+		t.Errorf("expect no HSTS over http and no CSP by default, got %v", header)
+	}
+
+	ctx, rw = newContext("GET", "https://example.com/")
+	midware(ctx)
+	if hsts := rw.Header().Get("Strict-Transport-Security"); hsts != "max-age=31536000" {
+		t.Errorf("expect HSTS over https, got %q", hsts)
+	}
+
+	opts := DefaultSecureOptions
+	opts.FrameOptions = ""
+	opts.HSTSIncludeSubdomains = true
+	opts.ContentSecurityPolicy = "default-src 'self'"
+
+	ctx, rw = newContext("GET", "https://example.com/")
+	SecureHeaders(opts)(ctx)
+	header = rw.Header()
+	if header.Get("X-Frame-Options") != "" || header.Get("Content-Security-Policy") != "default-src 'self'" ||
+		header.Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Errorf("unexpected custom headers %v", header)
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	gocontext "context"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Timeout returns a midware which bounds how long a handler can run, the handler runs in its own
+//goroutine, and 503 will be responded if it's not finished within d. The deadline is set to request
+//context, so handler can see the cancellation with ctx.Done(), and any write after timeout will
+//fail with http.ErrHandlerTimeout. The request still waits for handler returned before finished,
+//since context is reused after then. It's usually attached to single routes, like
+//zebra.Get("/report", report, middleware.Timeout(5*time.Second))
+func Timeout(d time.Duration) router.Midware {
+	return func(ctx *context.Context) bool {
+		cancel := ctx.WithTimeout(d)
+		ctx.Defer(cancel)
+
+		deadline := ctx.StdContext()
+		tw := &timeoutWriter{ResponseWriter: ctx.ResponseWriter(), header: make(http.Header), deadline: deadline}
+		ctx.SetResponseWriter(tw)
+
+		ctx.Around(func(next func()) {
+			// Panics of handler are handed off to request goroutine, so router recovers them
+			done := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					done <- recover()
+				}()
+				next()
+			}()
+
+			select {
+			case p := <-done:
+				if p != nil {
+					panic(p)
+				}
+				return
+			case <-deadline.Done():
+				if deadline.Err() == gocontext.DeadlineExceeded {
+					tw.timeout()
+				}
+			}
+
+			if p := <-done; p != nil {
+				panic(p)
+			}
+		})
+
+		return true
+	}
+}
+
+//timeoutWriter keeps headers of handler in its own map until written, so the timeout
+//response and handler never write headers both
+type timeoutWriter struct {
+	http.ResponseWriter
+	mutex       sync.Mutex
+	header      http.Header
+	deadline    gocontext.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.expired() || w.wroteHeader {
+		return
+	}
+
+	w.writeHeader(code)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !w.wroteHeader {
+		w.writeHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.timedOut {
+		return
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *timeoutWriter) writeHeader(code int) {
+	w.wroteHeader = true
+
+	header := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// expired checks whether timed out, the timeout response will be written if deadline exceeded
+// before the request goroutine does, it must be called with mutex locked
+func (w *timeoutWriter) expired() bool {
+	if !w.timedOut && !w.wroteHeader && w.deadline.Err() == gocontext.DeadlineExceeded {
+		w.writeTimeout()
+	}
+
+	return w.timedOut
+}
+
+// timeout writes 503 if handler hasn't written anything yet
+func (w *timeoutWriter) timeout() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.wroteHeader || w.timedOut {
+		return
+	}
+
+	w.writeTimeout()
+}
+
+func (w *timeoutWriter) writeTimeout() {
+	w.timedOut = true
+
+	body := http.StatusText(http.StatusServiceUnavailable)
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Type", context.WithCharset("text/plain"))
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	w.ResponseWriter.Write([]byte(body))
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
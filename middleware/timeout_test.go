@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	r := router.New()
+
+	r.Get("/slow", func(ctx *context.Context) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+		ctx.WriteString("late")
+	}, Timeout(10*time.Millisecond))
+
+	r.Get("/fast", func(ctx *context.Context) {
+		ctx.WriteString("fast")
+	}, Timeout(time.Second))
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/slow", nil))
+	if rw.Code != http.StatusServiceUnavailable || rw.Body.String() != "Service Unavailable" {
+		t.Errorf("expect timeout response, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/fast", nil))
+	if rw.Code != http.StatusOK || rw.Body.String() != "fast" {
+		t.Errorf("expect normal response, got %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestTimeoutRace(t *testing.T) {
+	r := router.New()
+
+	var written bool
+	var status int
+	var err error
+	finished := make(chan struct{})
+	r.Get("/slow", func(ctx *context.Context) {
+		defer close(finished)
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+
+		written, status = ctx.Written(), ctx.Status()
+		ctx.IsClientGone()
+		ctx.Header("X-Late", "1")
+		err = ctx.WriteString("late")
+	}, Timeout(10*time.Millisecond))
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/slow", nil))
+
+	select {
+	case <-finished:
+	default:
+		t.Fatal("expect request waits for handler finished")
+	}
+
+	if rw.Code != http.StatusServiceUnavailable || rw.Body.String() != "Service Unavailable" || rw.Header().Get("X-Late") != "" {
+		t.Errorf("expect timeout response, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	if !written || status != http.StatusServiceUnavailable || err != http.ErrHandlerTimeout {
+		t.Errorf("expect handler sees timeout, got %v %d %v", written, status, err)
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	r := router.New()
+	r.Get("/panic", func(ctx *context.Context) {
+		panic("boom")
+	}, Timeout(time.Second))
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/panic", nil))
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("expect panic of handler recovered by router, got %d", rw.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"time"
+)
+
+//ServerTiming returns a midware which emits Server-Timing header with time spent in midwares
+//and handler, like "mw;dur=1.2, handler;dur=30.5", durations are in milliseconds. It should
+//be the first midware registered, and since headers are sent before body, handler duration is
+//measured until the response header written.
+func ServerTiming() router.Midware {
+	return func(ctx *context.Context) bool {
+		tw := &timingWriter{ResponseWriter: ctx.ResponseWriter(), ctx: ctx, start: ctx.StartTime()}
+		ctx.SetResponseWriter(tw)
+		ctx.Defer(tw.emit)
+
+		return true
+	}
+}
+
+type timingWriter struct {
+	http.ResponseWriter
+	ctx     *context.Context
+	start   time.Time
+	emitted bool
+}
+
+func (w *timingWriter) WriteHeader(code int) {
+	w.emit()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	w.emit()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timingWriter) Flush() {
+	w.emit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// emit sets Server-Timing header with durations measured so far, only once
+func (w *timingWriter) emit() {
+	if w.emitted {
+		return
+	}
+	w.emitted = true
+
+	now := time.Now()
+	handler, ok := w.ctx.Stage("handler")
+	if !ok {
+		w.Header().Add("Server-Timing", metric("mw", now.Sub(w.start)))
+		return
+	}
+
+	after, ok := w.ctx.Stage("after")
+	if !ok {
+		after = now
+	}
+
+	w.Header().Add("Server-Timing", metric("mw", handler.Sub(w.start))+", "+metric("handler", after.Sub(handler)))
+}
+
+func metric(name string, d time.Duration) string {
+	return fmt.Sprintf("%s;dur=%.3f", name, float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestServerTiming(t *testing.T) {
+	r := router.New()
+	r.Use(ServerTiming())
+	r.Get("/slow", func(ctx *context.Context) {
+		time.Sleep(2 * time.Millisecond)
+		ctx.WriteString("done")
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/slow", nil))
+
+	timing := rw.Header().Get("Server-Timing")
+	if !regexp.MustCompile(`^mw;dur=\d+\.\d{3}, handler;dur=\d+\.\d{3}$`).MatchString(timing) {
+		t.Errorf("malformed Server-Timing header %q", timing)
+	}
+}
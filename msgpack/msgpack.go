@@ -0,0 +1,61 @@
+// Copyright 2016 Derek Ray. All rights reserved.
+// Use of this source code is governed by Apache License 2.0
+// that can be found in the LICENSE file.
+
+// Package msgpack reads and writes MessagePack bodies with context, it's a separated package to
+// keep the msgpack dependency optional. Importing it registers an encoder for Context.Render, so
+// clients sending "Accept: application/msgpack" get rendered data in MessagePack.
+//
+//	func user(ctx *context.Context) {
+//		var req UserRequest
+//		if err := msgpack.Bind(ctx, &req); err != nil {
+//			ctx.Error(http.StatusBadRequest, err.Error())
+//			return
+//		}
+//		msgpack.Write(ctx, http.StatusOK, lookup(req))
+//	}
+package msgpack
+
+import (
+	"errors"
+	"github.com/raythorn/zebra/context"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"net/http"
+)
+
+// ContentType is the media type of msgpack body
+const ContentType = "application/msgpack"
+
+func init() {
+	context.RegisterEncoder(ContentType, Encode)
+}
+
+// Encode writes v to w in MessagePack, it's the encoder registered for Context.Render
+func Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).Encode(v)
+}
+
+// Bind unmarshals msgpack body of request into v
+func Bind(ctx *context.Context, v interface{}) error {
+	if err := ctx.BodyError(); err != nil {
+		return errors.New("Bind: read body failed: " + err.Error())
+	}
+
+	if err := msgpack.Unmarshal(ctx.Body(), v); err != nil {
+		return errors.New("Bind: invalid msgpack: " + err.Error())
+	}
+
+	return nil
+}
+
+// Write marshals v and writes it with http status code and Content-Type application/msgpack
+func Write(ctx *context.Context, code int, v interface{}) error {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		http.Error(ctx.ResponseWriter(), err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return ctx.Data(code, ContentType, data)
+}
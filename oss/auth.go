@@ -0,0 +1,43 @@
+package oss
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"net/http"
+)
+
+// Operations on objects passed to Authorizer
+const (
+	OpRead   = "read"
+	OpWrite  = "write"
+	OpDelete = "delete"
+)
+
+// Authorizer checks if request can do op on object of objectPath, which is the request path,
+// such as "/objects/<md5>.png", a non-nil error rejects the request with 403
+type Authorizer func(ctx *context.Context, objectPath, op string) error
+
+// SetAuthorizer sets the authorizer called by oss handlers before touching storage, downloads and
+// listings are OpRead, uploads OpWrite and deletes OpDelete. Presigned requests are authorized by
+// signature and skip it.
+func (oss *Oss) SetAuthorizer(authorizer Authorizer) *Oss {
+	oss.authorizer = authorizer
+	return oss
+}
+
+// authorize calls authorizer of oss serving ctx, it responds 403 and returns false if rejected
+func authorize(ctx *context.Context, op string) bool {
+	v, _ := ctx.Value(OssKey)
+	oss, ok := v.(*Oss)
+	if !ok || oss.authorizer == nil || Presigned(ctx) {
+		return true
+	}
+
+	if err := oss.authorizer(ctx, ctx.URL(), op); err != nil {
+		log.Debug("Oss: %s %s rejected, %s", op, ctx.URL(), err)
+		ctx.Abort(http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
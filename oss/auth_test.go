@@ -0,0 +1,47 @@
+package oss
+
+import (
+	"errors"
+	"github.com/raythorn/zebra/context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuthorizer(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "public.txt"), []byte("public"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "private.txt"), []byte("private"), 0644)
+
+	var ops []string
+	o := (&Oss{root: dir}).SetAuthorizer(func(ctx *context.Context, objectPath, op string) error {
+		ops = append(ops, op+" "+objectPath)
+		if strings.HasSuffix(objectPath, "/private.txt") {
+			return errors.New("forbidden")
+		}
+		return nil
+	})
+
+	ctx, rw := newContext("GET", "/objects/public.txt", filepath.Join(dir, "public.txt"))
+	ctx.SetValue(OssKey, o)
+	ServeContent(ctx)
+	if rw.Code != 200 || rw.Body.String() != "public" {
+		t.Errorf("expect public object served, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	ctx, rw = newContext("DELETE", "/objects/private.txt", filepath.Join(dir, "private.txt"))
+	ctx.SetValue(OssKey, o)
+	DeleteContent(ctx)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("expect 403 for private object, got %d", rw.Code)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "private.txt")); err != nil {
+		t.Errorf("expect private object kept, %s", err)
+	}
+
+	if strings.Join(ops, ",") != "read /objects/public.txt,delete /objects/private.txt" {
+		t.Errorf("unexpected authorized ops %v", ops)
+	}
+}
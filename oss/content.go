@@ -2,22 +2,38 @@ package oss
 
 import (
 	"bufio"
+	gocontext "context"
 	"crypto/md5"
 	"fmt"
 	"github.com/raythorn/zebra/context"
 	"github.com/raythorn/zebra/log"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
 	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// ServeContent downloads object of oss path, Range requests are served with 206 Partial Content
+// by http.ServeContent, so interrupted downloads can be resumed. Directories are listed by
+// ListContent. Objects are streamed in chunks, and reading stops once the request canceled, such
+// as client disconnected.
 func ServeContent(ctx *context.Context) {
+	if !authorize(ctx, OpRead) {
+		return
+	}
+
+	if isMultipart(ctx) {
+		ListParts(ctx)
+		return
+	}
 
-	respath := ctx.Get(OssPathKey)
+	respath := ObjectPath(ctx)
 	if len(respath) == 0 || !isExist(respath) {
 		ctx.NotFound()
 		return
@@ -37,15 +53,61 @@ func ServeContent(ctx *context.Context) {
 	}
 
 	if fileinfo.IsDir() {
-		http.ServeFile(ctx.ResponseWriter(), ctx.Request(), respath)
+		ListContent(ctx)
 		return
 	}
 
-	http.ServeContent(ctx.ResponseWriter(), ctx.Request(), respath, fileinfo.ModTime(), file)
+	// Content-Type and filename recorded when uploaded, objects without metadata are typed by
+	// extension, see context.RegisterMIME, http.ServeContent sniffs content if still unknown
+	if meta, ok := loadMeta(respath); ok {
+		ctx.Header("Content-Type", meta.ContentType)
+		if meta.Filename != "" {
+			ctx.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": meta.Filename}))
+		}
+	} else if contentType := context.TypeByExtension(path.Ext(respath)); contentType != "" {
+		ctx.Header("Content-Type", contentType)
+	}
+
+	reader := &cancelReader{ctx: ctx.Request().Context(), file: file}
+	http.ServeContent(ctx.ResponseWriter(), ctx.Request(), respath, fileinfo.ModTime(), reader)
+}
+
+// cancelReader reads file until ctx done, it's checked before each chunk copied to client
+type cancelReader struct {
+	ctx  gocontext.Context
+	file *os.File
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.file.Read(p)
 }
 
+func (r *cancelReader) Seek(offset int64, whence int) (int64, error) {
+	return r.file.Seek(offset, whence)
+}
+
+// DepositContent uploads object of oss path, with Content-Range, object can be uploaded in
+// chunks. Multipart upload is initiated with ?uploads and completed with ?uploadId, see
+// InitiateUpload and CompleteUpload.
 func DepositContent(ctx *context.Context) {
-	respath := ctx.Get(OssPathKey)
+	if !authorize(ctx, OpWrite) {
+		return
+	}
+
+	if isMultipart(ctx) {
+		if ctx.Request().URL.Query().Get("uploadId") != "" {
+			CompleteUpload(ctx)
+		} else {
+			InitiateUpload(ctx)
+		}
+		return
+	}
+
+	respath := ObjectPath(ctx)
 	if len(respath) == 0 {
 		resp := map[string]interface{}{}
 		resp["code"] = 1
@@ -134,6 +196,9 @@ func DepositContent(ctx *context.Context) {
 			if err != nil {
 				ctx.WriteHeader(HTTP_INTERNAL)
 			} else {
+				if err := saveMeta(respath, requestMeta(ctx)); err != nil {
+					log.Error("Save meta of %s failed, %s", respath, err)
+				}
 				ctx.WriteHeader(HTTP_SUCCESS)
 			}
 			return
@@ -143,6 +208,79 @@ func DepositContent(ctx *context.Context) {
 	ctx.WriteHeader(HTTP_INTERNAL)
 }
 
+// Object is metadata of a stored object returned by ListContent
+type Object struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modtime"`
+}
+
+// ListContent responds metadata of objects in directory of oss path as a JSON array, incomplete
+// uploads and metadata files are not listed
+func ListContent(ctx *context.Context) {
+	respath := ObjectPath(ctx)
+	if len(respath) == 0 {
+		ctx.NotFound()
+		return
+	}
+
+	infos, err := ioutil.ReadDir(respath)
+	if err != nil {
+		ctx.NotFound()
+		return
+	}
+
+	objects := make([]Object, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || path.Ext(info.Name()) == ".cache" || path.Ext(info.Name()) == ".meta" {
+			continue
+		}
+
+		objects = append(objects, Object{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	ctx.JSON(objects, false)
+}
+
+// DeleteContent deletes object of oss path, responds 204 if deleted, multipart upload is
+// aborted with ?uploadId, see AbortUpload
+func DeleteContent(ctx *context.Context) {
+	if !authorize(ctx, OpDelete) {
+		return
+	}
+
+	if isMultipart(ctx) {
+		AbortUpload(ctx)
+		return
+	}
+
+	respath := ObjectPath(ctx)
+	if len(respath) == 0 {
+		ctx.NotFound()
+		return
+	}
+
+	fileinfo, err := os.Stat(respath)
+	if err != nil {
+		ctx.NotFound()
+		return
+	}
+
+	if fileinfo.IsDir() {
+		ctx.WriteHeader(HTTP_REQUEST)
+		return
+	}
+
+	if err := os.Remove(respath); err != nil {
+		log.Error("Delete %s failed, %s", respath, err)
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+	os.Remove(respath + ".meta")
+
+	ctx.WriteHeader(http.StatusNoContent)
+}
+
 func isExist(file string) bool {
 	_, err := os.Stat(file)
 	if err == nil {
@@ -0,0 +1,126 @@
+package oss
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"github.com/raythorn/zebra/context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newContext(method, target, respath string) (*context.Context, *httptest.ResponseRecorder) {
+	rw := httptest.NewRecorder()
+	ctx := context.New()
+	ctx.Reset(rw, httptest.NewRequest(method, target, nil))
+	ctx.SetValue(OssPathKey, respath)
+	return ctx, rw
+}
+
+func TestListAndDeleteContent(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("zebra"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.cache"), []byte("partial"), 0644)
+
+	ctx, rw := newContext("GET", "/objects", dir)
+	ServeContent(ctx)
+
+	var objects []Object
+	if err := json.Unmarshal(rw.Body.Bytes(), &objects); err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Name != "a.txt" || objects[0].Size != 5 {
+		t.Errorf("unexpected objects %+v", objects)
+	}
+
+	ctx, rw = newContext("DELETE", "/objects/a.txt", filepath.Join(dir, "a.txt"))
+	DeleteContent(ctx)
+	if rw.Code != http.StatusNoContent {
+		t.Errorf("expect 204, got %d", rw.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Error("expect object deleted")
+	}
+
+	ctx, rw = newContext("DELETE", "/objects/a.txt", filepath.Join(dir, "a.txt"))
+	DeleteContent(ctx)
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for missing object, got %d", rw.Code)
+	}
+}
+
+// cancelWriter cancels request after first chunk written, like a client disconnected
+type cancelWriter struct {
+	*httptest.ResponseRecorder
+	cancel gocontext.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	defer w.cancel()
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestServeContentCanceled(t *testing.T) {
+	respath := filepath.Join(t.TempDir(), "large.bin")
+	ioutil.WriteFile(respath, bytes.Repeat([]byte("z"), 1<<20), 0644)
+
+	c, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+
+	rw := &cancelWriter{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+	ctx := context.New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/objects/large.bin", nil).WithContext(c))
+	ctx.SetValue(OssPathKey, respath)
+	ServeContent(ctx)
+
+	if rw.Body.Len() == 0 || rw.Body.Len() >= 1<<20 {
+		t.Errorf("expect download stopped after canceled, got %d bytes", rw.Body.Len())
+	}
+}
+
+func TestServeContentRange(t *testing.T) {
+	respath := filepath.Join(t.TempDir(), "video.bin")
+	ioutil.WriteFile(respath, []byte("0123456789"), 0644)
+
+	ctx, rw := newContext("GET", "/objects/video.bin", respath)
+	ctx.Request().Header.Set("Range", "bytes=2-5")
+	ServeContent(ctx)
+
+	if rw.Code != http.StatusPartialContent || rw.Body.String() != "2345" {
+		t.Errorf("expect 206 with 2345, got %d %s", rw.Code, rw.Body.String())
+	}
+	if rw.Header().Get("Content-Range") != "bytes 2-5/10" || rw.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("unexpected range headers %v", rw.Header())
+	}
+
+	ctx, rw = newContext("GET", "/objects/video.bin", respath)
+	ctx.Request().Header.Set("Range", "bytes=7-")
+	ServeContent(ctx)
+	if rw.Code != http.StatusPartialContent || rw.Body.String() != "789" {
+		t.Errorf("expect resumed tail 789, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	ctx, rw = newContext("GET", "/objects/video.bin", respath)
+	ctx.Request().Header.Set("Range", "bytes=20-30")
+	ServeContent(ctx)
+	if rw.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("expect 416, got %d", rw.Code)
+	}
+}
+
+func TestServeContentRegisteredMIME(t *testing.T) {
+	context.RegisterMIME(".webmanifest", "application/manifest+json")
+
+	respath := filepath.Join(t.TempDir(), "site.webmanifest")
+	ioutil.WriteFile(respath, []byte(`{"name":"zebra"}`), 0644)
+
+	ctx, rw := newContext("GET", "/objects/site.webmanifest", respath)
+	ServeContent(ctx)
+	if rw.Header().Get("Content-Type") != "application/manifest+json" {
+		t.Errorf("expect registered type, got %s", rw.Header().Get("Content-Type"))
+	}
+}
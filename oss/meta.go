@@ -0,0 +1,92 @@
+package oss
+
+import (
+	"encoding/json"
+	"github.com/raythorn/zebra/context"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+)
+
+// Meta is metadata of object saved in "<object>.meta" when uploaded
+type Meta struct {
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename,omitempty"`
+}
+
+// requestMeta returns metadata declared by upload request, Content-Type is ignored if generic,
+// and Filename is from Content-Disposition, like `attachment; filename="report.pdf"`
+func requestMeta(ctx *context.Context) Meta {
+	var meta Meta
+
+	if mediaType, _, err := mime.ParseMediaType(ctx.Request().Header.Get("Content-Type")); err == nil {
+		switch mediaType {
+		case "application/octet-stream", "application/x-www-form-urlencoded", "multipart/form-data":
+		default:
+			meta.ContentType = ctx.Request().Header.Get("Content-Type")
+		}
+	}
+
+	if _, params, err := mime.ParseMediaType(ctx.Request().Header.Get("Content-Disposition")); err == nil {
+		meta.Filename = params["filename"]
+	}
+
+	return meta
+}
+
+// saveMeta saves metadata of object, content type is detected by extension of filename or object
+// path if not declared, and sniffed from content if extension unknown
+func saveMeta(respath string, meta Meta) error {
+	if meta.ContentType == "" && meta.Filename != "" {
+		meta.ContentType = context.TypeByExtension(path.Ext(meta.Filename))
+	}
+
+	if meta.ContentType == "" {
+		meta.ContentType = context.TypeByExtension(path.Ext(respath))
+	}
+
+	if meta.ContentType == "" {
+		file, err := os.Open(respath)
+		if err != nil {
+			return err
+		}
+
+		head := make([]byte, 512)
+		n, _ := file.Read(head)
+		file.Close()
+
+		meta.ContentType = http.DetectContentType(head[:n])
+	}
+
+	return writeJSON(respath+".meta", meta)
+}
+
+// loadMeta returns metadata of object, false if no metadata saved
+func loadMeta(respath string) (Meta, bool) {
+	var meta Meta
+	if err := readJSON(respath+".meta", &meta); err != nil {
+		return meta, false
+	}
+
+	return meta, true
+}
+
+func writeJSON(file string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(file, data)
+}
+
+func readJSON(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,271 @@
+package oss
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Multipart upload stores large objects in parts, so an interrupted upload can be resumed by
+// uploading missing parts only:
+//
+//	POST   /objects/x?uploads                  initiates, responds {"uploadId":"..."}, Content-Type
+//	                                           and Content-Disposition are saved as Meta
+//	PUT    /objects/x?uploadId=ID&partNumber=N uploads part N with body, N starts from 1
+//	GET    /objects/x?uploadId=ID              lists parts uploaded
+//	POST   /objects/x?uploadId=ID              completes, parts are assembled by number
+//	DELETE /objects/x?uploadId=ID              aborts, parts are removed
+//
+// Parts are kept in directory "<object>.uploads/<uploadId>" next to the object until completed.
+
+// MaxParts is max number of parts in a multipart upload
+const MaxParts = 10000
+
+// Part is metadata of an uploaded part
+type Part struct {
+	Number int   `json:"partNumber"`
+	Size   int64 `json:"size"`
+}
+
+// InitiateUpload starts a multipart upload of object in oss path, responds the upload id
+func InitiateUpload(ctx *context.Context) {
+	respath := ObjectPath(ctx)
+	if len(respath) == 0 {
+		ctx.NotFound()
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	id := hex.EncodeToString(b)
+	dir := uploadDir(respath, id)
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		log.Error("Initiate upload of %s failed, %s", respath, err)
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	// Metadata is declared by initiating request, and saved with object when completed
+	if err := writeJSON(filepath.Join(dir, "meta"), requestMeta(ctx)); err != nil {
+		log.Error("Initiate upload of %s failed, %s", respath, err)
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	ctx.JSON(map[string]string{"uploadId": id}, false)
+}
+
+// UploadPart saves body as part partNumber of upload uploadId, uploading same part again
+// overwrites it
+func UploadPart(ctx *context.Context) {
+	if !authorize(ctx, OpWrite) {
+		return
+	}
+
+	dir, ok := upload(ctx)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(ctx.Request().URL.Query().Get("partNumber"))
+	if err != nil || number < 1 || number > MaxParts {
+		ctx.WriteHeader(HTTP_REQUEST)
+		return
+	}
+
+	part := filepath.Join(dir, partName(number))
+	if err := writeFile(part, ctx.Body()); err != nil {
+		log.Error("Upload part %s failed, %s", part, err)
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	ctx.WriteHeader(HTTP_SUCCESS)
+}
+
+// ListParts responds parts uploaded of upload uploadId, ordered by part number
+func ListParts(ctx *context.Context) {
+	dir, ok := upload(ctx)
+	if !ok {
+		return
+	}
+
+	parts, err := listParts(dir)
+	if err != nil {
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	ctx.JSON(parts, false)
+}
+
+// CompleteUpload assembles parts into the object, parts must be numbered continuously from 1
+func CompleteUpload(ctx *context.Context) {
+	dir, ok := upload(ctx)
+	if !ok {
+		return
+	}
+
+	parts, err := listParts(dir)
+	if err != nil {
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	if len(parts) == 0 {
+		ctx.WriteHeader(HTTP_REQUEST)
+		return
+	}
+
+	for i, part := range parts {
+		if part.Number != i+1 {
+			ctx.Error(HTTP_REQUEST, "Part "+strconv.Itoa(i+1)+" missing")
+			return
+		}
+	}
+
+	respath := ObjectPath(ctx)
+	tmp := filepath.Join(dir, "object")
+	if err := assemble(tmp, dir, parts); err != nil {
+		log.Error("Assemble %s failed, %s", respath, err)
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	if err := os.Rename(tmp, respath); err != nil {
+		log.Error("Complete upload of %s failed, %s", respath, err)
+		ctx.WriteHeader(HTTP_INTERNAL)
+		return
+	}
+
+	var meta Meta
+	readJSON(filepath.Join(dir, "meta"), &meta)
+	if err := saveMeta(respath, meta); err != nil {
+		log.Error("Save meta of %s failed, %s", respath, err)
+	}
+
+	removeUpload(respath, dir)
+	ctx.WriteHeader(HTTP_SUCCESS)
+}
+
+// AbortUpload removes all parts of upload uploadId
+func AbortUpload(ctx *context.Context) {
+	dir, ok := upload(ctx)
+	if !ok {
+		return
+	}
+
+	removeUpload(ObjectPath(ctx), dir)
+	ctx.WriteHeader(http.StatusNoContent)
+}
+
+// upload returns directory of upload uploadId in request, and responds 404 if not exist
+func upload(ctx *context.Context) (string, bool) {
+	respath := ObjectPath(ctx)
+	id := ctx.Request().URL.Query().Get("uploadId")
+
+	// Upload id is hex generated by InitiateUpload, others may escape the upload directory
+	if _, err := hex.DecodeString(id); err != nil || len(respath) == 0 || len(id) == 0 {
+		ctx.NotFound()
+		return "", false
+	}
+
+	dir := uploadDir(respath, id)
+	if !isExist(dir) {
+		ctx.NotFound()
+		return "", false
+	}
+
+	return dir, true
+}
+
+// isMultipart checks if request is a multipart upload operation
+func isMultipart(ctx *context.Context) bool {
+	query := ctx.Request().URL.Query()
+	_, initiate := query["uploads"]
+	return initiate || query.Get("uploadId") != ""
+}
+
+func uploadDir(respath, id string) string {
+	return filepath.Join(respath+".uploads", id)
+}
+
+func partName(number int) string {
+	return strconv.Itoa(number + 100000)[1:]
+}
+
+func listParts(dir string) ([]Part, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]Part, 0, len(infos))
+	for _, info := range infos {
+		number, err := strconv.Atoi(info.Name())
+		if err != nil || info.IsDir() {
+			continue
+		}
+		parts = append(parts, Part{Number: number, Size: info.Size()})
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+
+	return parts, nil
+}
+
+func assemble(dst, dir string, parts []Part) error {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for _, part := range parts {
+		in, err := os.Open(filepath.Join(dir, partName(part.Number)))
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(out, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return out.Sync()
+}
+
+// writeFile writes data to a temporary file first, so a broken upload never leaves a partial part
+func writeFile(file string, data []byte) error {
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0660); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+func removeUpload(respath, dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		log.Error("Remove upload %s failed, %s", dir, err)
+	}
+
+	// Remove uploads directory of object if no other uploads
+	os.Remove(respath + ".uploads")
+}
@@ -0,0 +1,126 @@
+package oss
+
+import (
+	"encoding/json"
+	"github.com/raythorn/zebra/context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newBodyContext(method, target, respath, body string) (*context.Context, *httptest.ResponseRecorder) {
+	rw := httptest.NewRecorder()
+	ctx := context.New()
+	ctx.Reset(rw, httptest.NewRequest(method, target, strings.NewReader(body)))
+	ctx.SetValue(OssPathKey, respath)
+	return ctx, rw
+}
+
+func TestMultipartUpload(t *testing.T) {
+	respath := filepath.Join(t.TempDir(), "archive.zip")
+
+	ctx, rw := newContext("POST", "/objects/archive.zip?uploads", respath)
+	DepositContent(ctx)
+
+	var initiated struct {
+		UploadID string `json:"uploadId"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &initiated); err != nil || initiated.UploadID == "" {
+		t.Fatalf("expect upload id, got %s", rw.Body.String())
+	}
+
+	target := "/objects/archive.zip?uploadId=" + initiated.UploadID
+	for number, body := range map[string]string{"2": "world", "1": "hello "} {
+		ctx, rw = newBodyContext("PUT", target+"&partNumber="+number, respath, body)
+		UploadPart(ctx)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("upload part %s: expect 200, got %d", number, rw.Code)
+		}
+	}
+
+	ctx, rw = newContext("GET", target, respath)
+	ServeContent(ctx)
+	if rw.Body.String() != `[{"partNumber":1,"size":6},{"partNumber":2,"size":5}]` {
+		t.Errorf("unexpected parts %s", rw.Body.String())
+	}
+
+	ctx, rw = newContext("POST", target, respath)
+	DepositContent(ctx)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expect completed, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	if content, _ := ioutil.ReadFile(respath); string(content) != "hello world" {
+		t.Errorf("unexpected object %q", content)
+	}
+
+	if _, err := os.Stat(respath + ".uploads"); !os.IsNotExist(err) {
+		t.Error("expect upload state removed")
+	}
+}
+
+func TestMultipartUploadInvalid(t *testing.T) {
+	respath := filepath.Join(t.TempDir(), "archive.zip")
+
+	ctx, rw := newBodyContext("PUT", "/objects/archive.zip?uploadId=../../etc&partNumber=1", respath, "x")
+	UploadPart(ctx)
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for invalid upload id, got %d", rw.Code)
+	}
+
+	ctx, rw = newContext("POST", "/objects/archive.zip?uploads", respath)
+	InitiateUpload(ctx)
+	var initiated struct {
+		UploadID string `json:"uploadId"`
+	}
+	json.Unmarshal(rw.Body.Bytes(), &initiated)
+
+	target := "/objects/archive.zip?uploadId=" + initiated.UploadID
+	ctx, _ = newBodyContext("PUT", target+"&partNumber=2", respath, "world")
+	UploadPart(ctx)
+
+	ctx, rw = newContext("POST", target, respath)
+	CompleteUpload(ctx)
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("expect 400 for missing part, got %d", rw.Code)
+	}
+
+	ctx, rw = newContext("DELETE", target, respath)
+	DeleteContent(ctx)
+	if rw.Code != http.StatusNoContent || isExist(uploadDir(respath, initiated.UploadID)) {
+		t.Errorf("expect upload aborted, got %d", rw.Code)
+	}
+}
+
+func TestUploadMeta(t *testing.T) {
+	respath := filepath.Join(t.TempDir(), "logo")
+
+	ctx, rw := newContext("POST", "/objects/logo?uploads", respath)
+	ctx.Request().Header.Set("Content-Disposition", `attachment; filename="logo.png"`)
+	InitiateUpload(ctx)
+
+	var initiated struct {
+		UploadID string `json:"uploadId"`
+	}
+	json.Unmarshal(rw.Body.Bytes(), &initiated)
+
+	target := "/objects/logo?uploadId=" + initiated.UploadID
+	ctx, _ = newBodyContext("PUT", target+"&partNumber=1", respath, "\x89PNG\r\n\x1a\n0000")
+	UploadPart(ctx)
+
+	ctx, _ = newContext("POST", target, respath)
+	CompleteUpload(ctx)
+
+	ctx, rw = newContext("GET", "/objects/logo", respath)
+	ServeContent(ctx)
+	if rw.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("expect image/png, got %s", rw.Header().Get("Content-Type"))
+	}
+	if rw.Header().Get("Content-Disposition") != `inline; filename=logo.png` {
+		t.Errorf("unexpected Content-Disposition %s", rw.Header().Get("Content-Disposition"))
+	}
+}
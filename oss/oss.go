@@ -1,93 +1,128 @@
-package oss
-
-import (
-	"github.com/raythorn/zebra/context"
-	"github.com/raythorn/zebra/log"
-	"os"
-	"os/exec"
-	"path"
-	"path/filepath"
-)
-
-const (
-	HTTP_CONTINUE     = 100
-	HTTP_SUCCESS      = 200
-	HTTP_PARTIAL      = 206
-	HTTP_REQUEST      = 400
-	HTTP_UNAUTHORIZED = 401
-	HTTP_FORBIDDEN    = 403
-	HTTP_NOTFOUND     = 404
-	HTTP_RANGE        = 416
-	HTTP_INTERNAL     = 500
-)
-
-//Keys of oss elements, this will save in context which can be referred by upload/download handler
-const (
-	//relative path of current file
-	OssPathKey = "com.raythorn.falcon.oss.path"
-)
-
-// OSS archive manager, which can arrange objects path with your own algrithem
-type Archive interface {
-	Path(oss *Oss, ctx *context.Context) string
-}
-
-// Object storage service, handle object upload and download request
-type Oss struct {
-	root    string
-	archive Archive
-}
-
-func New(root string, archive Archive) *Oss {
-
-	log.Debug(applicationPath())
-
-	if root == "" || !path.IsAbs(root) {
-		root = path.Clean(applicationPath() + "/" + root)
-	}
-
-	log.Debug(root)
-
-	_, err := os.Stat(root)
-	if os.IsNotExist(err) {
-		err = os.MkdirAll(root, 0770)
-		if err != nil {
-			log.Fatal("Create root directory fail")
-		}
-	}
-
-	fi, err := os.Stat(root)
-	if err != nil {
-		log.Fatal("Cannot stat root directory")
-	}
-
-	if !fi.IsDir() || (fi.Mode()&0700) != 0700 {
-		log.Fatal("Root is not a directory or does not have read/write permission")
-	}
-
-	oss := &Oss{root: root, archive: archive}
-	return oss
-}
-
-func (oss *Oss) Root() string {
-	return oss.root
-}
-
-func (oss *Oss) Archive() Archive {
-	return oss.archive
-}
-
-func applicationPath() string {
-
-	file, err := exec.LookPath(os.Args[0])
-	if err != nil {
-		log.Fatal("Cannot find application path!")
-	}
-
-	fp, err := filepath.Abs(filepath.Dir(file))
-	if err != nil {
-		log.Fatal("Cannot find application path!")
-	}
-
-	return fp
-}
+package oss
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	HTTP_CONTINUE     = 100
+	HTTP_SUCCESS      = 200
+	HTTP_PARTIAL      = 206
+	HTTP_REQUEST      = 400
+	HTTP_UNAUTHORIZED = 401
+	HTTP_FORBIDDEN    = 403
+	HTTP_NOTFOUND     = 404
+	HTTP_RANGE        = 416
+	HTTP_INTERNAL     = 500
+)
+
+//Keys of oss elements, this will save in context which can be referred by upload/download handler
+const (
+	//path of current object resolved by archive, saved in context values, see ObjectPath
+	OssPathKey = "com.raythorn.falcon.oss.path"
+	//Oss serving current request, saved in context values
+	OssKey = "com.raythorn.falcon.oss"
+)
+
+// ObjectPath returns path of current object resolved by archive of oss, it's saved with
+// ctx.SetValue by router, so it can not be forged by query or headers, "" if not resolved
+func ObjectPath(ctx *context.Context) string {
+	v, _ := ctx.Value(OssPathKey)
+	respath, _ := v.(string)
+	return respath
+}
+
+// OSS archive manager, which can arrange objects path with your own algrithem
+type Archive interface {
+	Path(oss *Oss, ctx *context.Context) string
+}
+
+// Object storage service, handle object upload and download request
+type Oss struct {
+	root       string
+	archive    Archive
+	secret     []byte
+	authorizer Authorizer
+}
+
+func New(root string, archive Archive) *Oss {
+
+	log.Debug(applicationPath())
+
+	if root == "" || !path.IsAbs(root) {
+		root = path.Clean(applicationPath() + "/" + root)
+	}
+
+	log.Debug(root)
+
+	_, err := os.Stat(root)
+	if os.IsNotExist(err) {
+		err = os.MkdirAll(root, 0770)
+		if err != nil {
+			log.Fatal("Create root directory fail")
+		}
+	}
+
+	fi, err := os.Stat(root)
+	if err != nil {
+		log.Fatal("Cannot stat root directory")
+	}
+
+	if !fi.IsDir() || (fi.Mode()&0700) != 0700 {
+		log.Fatal("Root is not a directory or does not have read/write permission")
+	}
+
+	oss := &Oss{root: root, archive: archive}
+	return oss
+}
+
+func (oss *Oss) Root() string {
+	return oss.root
+}
+
+func (oss *Oss) Archive() Archive {
+	return oss.archive
+}
+
+// Locate returns path of object of request resolved by archive, "" if there is no archive or the
+// path is out of root, such as category "../.." of MD5Archive
+func (oss *Oss) Locate(ctx *context.Context) string {
+	if oss.archive == nil {
+		return ""
+	}
+
+	respath := oss.archive.Path(oss, ctx)
+	if respath == "" {
+		return ""
+	}
+
+	respath = filepath.Clean(respath)
+	root := filepath.Clean(oss.root)
+	if respath != root && !strings.HasPrefix(respath, root+string(filepath.Separator)) {
+		log.Warning("Oss: path %s is out of root %s", respath, root)
+		return ""
+	}
+
+	return respath
+}
+
+func applicationPath() string {
+
+	file, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		log.Fatal("Cannot find application path!")
+	}
+
+	fp, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		log.Fatal("Cannot find application path!")
+	}
+
+	return fp
+}
@@ -0,0 +1,45 @@
+package oss
+
+import (
+	"github.com/raythorn/zebra/context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocate(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "objects")
+	storage := New(root, &MD5Archive{})
+	resid := "0123456789abcdef0123456789abcdef.txt"
+
+	cases := []struct {
+		category, respath string
+	}{
+		{"images", filepath.Join(root, "images", "01", "234", resid)},
+		{"", filepath.Join(root, "01", "234", resid)},
+		{"../..", ""},
+		{"images/../../..", ""},
+	}
+
+	for _, c := range cases {
+		ctx := context.New()
+		ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/objects/"+resid, nil))
+		ctx.SetParam("category", c.category)
+		ctx.SetParam("resid", resid)
+
+		if respath := storage.Locate(ctx); respath != c.respath {
+			t.Errorf("%q: expect %q, got %q", c.category, c.respath, respath)
+		}
+	}
+
+	ctx := context.New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/objects/"+resid+"?category=../..", nil))
+	ctx.SetParam("resid", resid)
+	if respath := storage.Locate(ctx); respath != filepath.Join(root, "01", "234", resid) {
+		t.Errorf("expect category of query ignored, got %q", respath)
+	}
+
+	if respath := New(root, nil).Locate(ctx); respath != "" {
+		t.Errorf("expect no path without archive, got %q", respath)
+	}
+}
@@ -0,0 +1,54 @@
+package oss
+
+import (
+	"errors"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/sign"
+	"time"
+)
+
+var (
+	ErrNoSecret = errors.New("Oss: secret for presigned URL not set")
+	ErrTTL      = errors.New("Oss: ttl of presigned URL must be positive")
+)
+
+// SetSecret sets the secret used to sign and verify presigned URLs
+func (oss *Oss) SetSecret(secret []byte) *Oss {
+	oss.secret = secret
+	return oss
+}
+
+// PresignURL returns objectPath with an expires and HMAC signature query, which downloads the
+// object without normal authorization until ttl elapsed. objectPath is the request path of object,
+// such as "/objects/<md5>.png".
+func (oss *Oss) PresignURL(objectPath string, ttl time.Duration) (string, error) {
+	if len(oss.secret) == 0 {
+		return "", ErrNoSecret
+	}
+
+	if ttl <= 0 {
+		return "", ErrTTL
+	}
+
+	return sign.BuildURL(objectPath, nil, oss.secret, ttl), nil
+}
+
+// Presigned checks if request carries a presigned signature, which should be verified by
+// VerifyPresigned
+func Presigned(ctx *context.Context) bool {
+	return ctx.Request().URL.Query().Get(sign.SignatureKey) != ""
+}
+
+// VerifyPresigned checks signature and expiry of a request to URL built by PresignURL, only
+// download(GET/HEAD) requests can be presigned.
+func (oss *Oss) VerifyPresigned(ctx *context.Context) error {
+	if len(oss.secret) == 0 {
+		return ErrNoSecret
+	}
+
+	if ctx.Method() != "GET" && ctx.Method() != "HEAD" {
+		return sign.ErrSignature
+	}
+
+	return sign.VerifyURL(ctx.URL(), ctx.Request().URL.Query(), oss.secret)
+}
@@ -0,0 +1,59 @@
+package oss
+
+import (
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/sign"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func presignedContext(method, target string) *context.Context {
+	ctx := context.New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest(method, target, nil))
+	return ctx
+}
+
+func TestPresignURL(t *testing.T) {
+	o := &Oss{}
+	if _, err := o.PresignURL("/objects/a.png", time.Minute); err != ErrNoSecret {
+		t.Errorf("expect ErrNoSecret, got %v", err)
+	}
+
+	o.SetSecret([]byte("secret"))
+	if _, err := o.PresignURL("/objects/a.png", 0); err != ErrTTL {
+		t.Errorf("expect ErrTTL, got %v", err)
+	}
+
+	url, err := o.PresignURL("/objects/a.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := presignedContext("GET", url)
+	if !Presigned(ctx) {
+		t.Fatal("expect presigned request")
+	}
+	if err := o.VerifyPresigned(ctx); err != nil {
+		t.Errorf("expect valid signature, got %v", err)
+	}
+
+	if err := o.VerifyPresigned(presignedContext("DELETE", url)); err == nil {
+		t.Error("expect DELETE rejected")
+	}
+
+	tampered := strings.Replace(url, "/objects/a.png", "/objects/b.png", 1)
+	if err := o.VerifyPresigned(presignedContext("GET", tampered)); err != sign.ErrSignature {
+		t.Errorf("expect ErrSignature for tampered path, got %v", err)
+	}
+
+	expired := sign.BuildURL("/objects/a.png", nil, []byte("secret"), -time.Minute)
+	if err := o.VerifyPresigned(presignedContext("GET", expired)); err != sign.ErrExpired {
+		t.Errorf("expect ErrExpired, got %v", err)
+	}
+
+	if Presigned(presignedContext("GET", "/objects/a.png")) {
+		t.Error("expect unsigned request")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Derek Ray. All rights reserved.
+// Use of this source code is governed by Apache License 2.0
+// that can be found in the LICENSE file.
+
+// Package protobuf reads and writes protocol buffers messages with context, it's a separated
+// package to keep the protobuf dependency optional.
+//
+//	func user(ctx *context.Context) {
+//		req := &pb.GetUserRequest{}
+//		if err := protobuf.Bind(ctx, req); err != nil {
+//			ctx.Error(http.StatusBadRequest, err.Error())
+//			return
+//		}
+//		protobuf.Negotiate(ctx, http.StatusOK, lookup(req))
+//	}
+package protobuf
+
+import (
+	"errors"
+	"github.com/raythorn/zebra/context"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+)
+
+// ContentType is the media type of protobuf body
+const ContentType = "application/x-protobuf"
+
+// Bind unmarshals protobuf body of request into msg
+func Bind(ctx *context.Context, msg proto.Message) error {
+	if err := proto.Unmarshal(ctx.Body(), msg); err != nil {
+		return errors.New("Bind: invalid protobuf: " + err.Error())
+	}
+
+	return nil
+}
+
+// Write marshals msg and writes it with http status code and Content-Type application/x-protobuf
+func Write(ctx *context.Context, code int, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		http.Error(ctx.ResponseWriter(), err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return ctx.Data(code, ContentType, data)
+}
+
+// Negotiate writes msg as protobuf if client prefers it in Accept header, or as JSON with
+// protojson otherwise, so a handler can serve both service-to-service and external clients
+func Negotiate(ctx *context.Context, code int, msg proto.Message) error {
+	if ctx.Accepts("application/json", ContentType) == ContentType {
+		return Write(ctx, code, msg)
+	}
+
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		http.Error(ctx.ResponseWriter(), err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return ctx.Data(code, "application/json; charset=utf-8", data)
+}
@@ -2,15 +2,36 @@ package router
 
 import (
 	"github.com/raythorn/zebra/context"
-	"strings"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/oss"
+	"regexp"
+	"sort"
 )
 
+// Group is a set of routes with a same prefix, which share the midwares added by Before and After.
+// A group is assembled from routes created by Get/Post/... of a Group (GGet/GPost/... in package
+// zebra) and sub-groups created by Sub (GSub), see Router.Group. Prefix can have parameters like
+// routes, such as "/api/:version", they are set in context for all routes in the group.
 type Group struct {
 	pattern string
 	routes  map[string]*Route
-	groups  map[string]*Group
-	before  []Midware
-	after   []Midware
+	// tree of routes for lookup, rebuilt when routes changed
+	tree *node
+	// regexp routes can not be added to tree, sorted by rank
+	regexps []*Route
+	// match request path case-insensitively
+	fold bool
+	// panic instead of warning when a method and pattern registered again
+	strict bool
+	// methods registered more than once while group assembled, reported when mounted to router
+	dups []duplicate
+	groups map[string]*Group
+	// group this group added to, whose midwares are inherited
+	parent *Group
+	before []Midware
+	after  []Midware
+	// CORS midware of group, run before before midwares and for preflight requests
+	cors Midware
 }
 
 func newGroup() *Group {
@@ -23,8 +44,9 @@ func newGroup() *Group {
 	}
 }
 
-// Before set midwares which will be called before actually http handler.
-// All routes in this group will be affected if set
+// Before adds midwares which will be called before actually http handler, after global midwares.
+// All routes in this group will be affected, a midware returns false or aborts the request stops
+// the rest midwares and the handler.
 func (g *Group) Before(midwares ...Midware) *Group {
 	if g.before == nil {
 		g.before = make([]Midware, 0)
@@ -34,8 +56,10 @@ func (g *Group) Before(midwares ...Midware) *Group {
 	return g
 }
 
-// After set midwares which will be called after actually http handler.
-// All routes in this group will be affected if set
+// After adds midwares which will be called after actually http handler, they are skipped if the
+// request aborted. All routes in this group will be affected, a midware returns false stops the
+// rest midwares. Headers can only be added if ctx.Written() is false, and a fallback response
+// should be written only then too.
 func (g *Group) After(midwares ...Midware) *Group {
 	if g.after == nil {
 		g.after = make([]Midware, 0)
@@ -45,42 +69,95 @@ func (g *Group) After(midwares ...Midware) *Group {
 	return g
 }
 
+// CORS sets the CORS midware of group, such as middleware.CORS(opts), it runs before midwares
+// added by Before, and answers preflight requests to routes of the group even if they have no
+// OPTIONS handler. The CORS midware of the innermost group wins, and global CORS midware added
+// by Use is skipped for routes in groups with CORS.
+func (g *Group) CORS(midware Midware) *Group {
+	g.cors = midware
+	return g
+}
+
+// corsMidware returns CORS midware of g or its nearest ancestor, nil if not exist
+func (g *Group) corsMidware() Midware {
+	for ; g != nil; g = g.parent {
+		if g.cors != nil {
+			return g.cors
+		}
+	}
+
+	return nil
+}
+
+// befores returns before midwares of g and its ancestors, from the outermost group to g
+func (g *Group) befores() []Midware {
+	if g == nil {
+		return nil
+	}
+
+	return append(g.parent.befores(), g.before...)
+}
+
+// afters returns after midwares of g and its ancestors, from g to the outermost group
+func (g *Group) afters() []Midware {
+	if g == nil {
+		return nil
+	}
+
+	return append(append([]Midware{}, g.after...), g.parent.afters()...)
+}
+
+// Sub creates a sub-group with prefix, args are same as Router.Group
 func (g *Group) Sub(prefix string, args ...interface{}) *Group {
 	group := newGroup()
+	group.pattern = cleanPath(prefix)
 	return group.group(prefix, args...)
 }
 
-func (g *Group) Get(pattern string, handler Handler) *Route {
-	return g.add("GET", pattern, handler)
+func (g *Group) Get(pattern string, handler Handler, midwares ...Midware) *Route {
+	return g.add("GET", pattern, handler, midwares...)
 }
 
-func (g *Group) Patch(pattern string, handler Handler) *Route {
-	return g.add("PATCH", pattern, handler)
+func (g *Group) Patch(pattern string, handler Handler, midwares ...Midware) *Route {
+	return g.add("PATCH", pattern, handler, midwares...)
 }
 
-func (g *Group) Put(pattern string, handler Handler) *Route {
-	return g.add("PUT", pattern, handler)
+func (g *Group) Put(pattern string, handler Handler, midwares ...Midware) *Route {
+	return g.add("PUT", pattern, handler, midwares...)
 }
 
-func (g *Group) Post(pattern string, handler Handler) *Route {
-	return g.add("POST", pattern, handler)
+func (g *Group) Post(pattern string, handler Handler, midwares ...Midware) *Route {
+	return g.add("POST", pattern, handler, midwares...)
 }
 
-func (g *Group) Delete(pattern string, handler Handler) *Route {
-	return g.add("DELETE", pattern, handler)
+func (g *Group) Delete(pattern string, handler Handler, midwares ...Midware) *Route {
+	return g.add("DELETE", pattern, handler, midwares...)
 }
 
-func (g *Group) Head(pattern string, handler Handler) *Route {
-	return g.add("HEAD", pattern, handler)
+func (g *Group) Head(pattern string, handler Handler, midwares ...Midware) *Route {
+	return g.add("HEAD", pattern, handler, midwares...)
 }
 
-func (g *Group) Options(pattern string, handler Handler) *Route {
-	return g.add("OPTIONS", pattern, handler)
+func (g *Group) Options(pattern string, handler Handler, midwares ...Midware) *Route {
+	return g.add("OPTIONS", pattern, handler, midwares...)
 }
 
-func (g *Group) Any(pattern string, handler Handler) *Route {
+func (g *Group) Any(pattern string, handler Handler, midwares ...Midware) *Route {
 
-	return g.add("ANY", pattern, handler)
+	return g.add("ANY", pattern, handler, midwares...)
+}
+
+// Oss creates a object storage route like Router.Oss, it should be added to a group, so requests
+// to objects run through before and after midwares of the group, such as authorization. Storage
+// of the route can be got with Route.Oss, such as for presigning URLs.
+func (g *Group) Oss(pattern, root string, archive oss.Archive) *Route {
+	route := g.add("GET", pattern, oss.ServeContent)
+	route.actions["HEAD"] = oss.ServeContent
+	route.actions["POST"] = oss.DepositContent
+	route.actions["PUT"] = oss.UploadPart
+	route.actions["DELETE"] = oss.DeleteContent
+	route.oss = oss.New(root, archive)
+	return route
 }
 
 func (g *Group) group(pattern string, args ...interface{}) *Group {
@@ -89,15 +166,12 @@ func (g *Group) group(pattern string, args ...interface{}) *Group {
 		switch arg.(type) {
 		case *Route:
 			route, _ := arg.(*Route)
-			route.pattern = cleanPath(pattern + route.pattern)
-			route.regexpCompile()
+			route.prefix(pattern)
 			route.group = g
 
 			if r, ok := g.routes[route.pattern]; ok {
-				for m, h := range route.actions {
-					r.actions[m] = h
-				}
-
+				g.dups = append(g.dups, r.duplicates(route)...)
+				r.merge(route)
 				route = nil
 			} else {
 				g.routes[route.pattern] = route
@@ -105,13 +179,20 @@ func (g *Group) group(pattern string, args ...interface{}) *Group {
 		case *Group:
 			grp, _ := arg.(*Group)
 			grp.pattern = cleanPath(pattern + grp.pattern)
+			grp.parent = g
 			g.groups[grp.pattern] = grp
+			g.dups = append(g.dups, grp.dups...)
+			grp.dups = nil
 
 			if len(grp.routes) > 0 {
 				for _, route := range grp.routes {
-					route.pattern = cleanPath(pattern + route.pattern)
-					route.regexpCompile()
-					g.routes[route.pattern] = route
+					route.prefix(pattern)
+					if r, ok := g.routes[route.pattern]; ok {
+						g.dups = append(g.dups, r.duplicates(route)...)
+						r.merge(route)
+					} else {
+						g.routes[route.pattern] = route
+					}
 				}
 			}
 
@@ -119,75 +200,153 @@ func (g *Group) group(pattern string, args ...interface{}) *Group {
 				for _, group := range grp.groups {
 					group.pattern = cleanPath(pattern + group.pattern)
 					g.groups[group.pattern] = group
-					if len(grp.before) > 0 {
-						if group.before == nil {
-							group.before = make([]Midware, 0)
-						}
-						group.before = append(grp.before, group.before...)
-					}
-					if len(grp.after) > 0 {
-						if group.after == nil {
-							group.after = make([]Midware, 0)
-						}
-
-						group.after = append(group.after, grp.after...)
-					}
 				}
 			}
+		default:
+			log.Panic("Group: unsupported argument %T of %s, expect *Route or *Group", arg, pattern)
 		}
 	}
 
+	g.sort()
 	return g
 }
 
-func (g *Group) add(method, pattern string, handler Handler) *Route {
+// mount adds routes of group to g for lookup, routes keep their own group for midwares. Methods
+// registered more than once in group or by other groups are reported then.
+func (g *Group) mount(group *Group) {
+	dups := group.dups
+	group.dups = nil
+
+	g.groups[group.pattern] = group
+	for _, route := range group.routes {
+		if r, ok := g.routes[route.pattern]; ok {
+			dups = append(dups, r.duplicates(route)...)
+			r.merge(route)
+		} else {
+			g.routes[route.pattern] = route
+		}
+	}
+
+	g.sort()
+	g.report(dups)
+}
+
+// report warns methods registered more than once, the last one is used, or panics if strict
+func (g *Group) report(dups []duplicate) {
+	for _, dup := range dups {
+		if g.strict {
+			log.Panic("Router: %s %s is registered more than once", dup.method, dup.route.pattern)
+		}
+		log.Warn("Router: %s %s is registered more than once, the last one is used", dup.method, dup.route.pattern)
+	}
+}
+
+func (g *Group) add(method, pattern string, handler Handler, midwares ...Midware) *Route {
 	route := newRoute()
 	route.pattern = cleanPath(pattern)
 	route.actions[method] = handler
+	if len(midwares) > 0 {
+		route.midwares[method] = midwares
+	}
 	route.regexpCompile()
 
 	return route
 }
 
-func (g *Group) insert(method, pattern string, handler Handler) *Route {
+func (g *Group) insert(method, pattern string, handler Handler, midwares ...Midware) *Route {
 
 	route := newRoute()
 
 	route.pattern = cleanPath(pattern)
 	route.actions[method] = handler
+	if len(midwares) > 0 {
+		route.midwares[method] = midwares
+	}
 	route.regexpCompile()
 
 	if rt, ok := g.routes[route.pattern]; ok {
-		for m, h := range route.actions {
-			rt.actions[m] = h
-		}
-
-		if route.oss != nil {
-			rt.oss = route.oss
-		}
-
+		g.report(rt.duplicates(route))
+		rt.merge(route)
 		route = nil
 		return rt
 	} else {
 		g.routes[route.pattern] = route
+		g.sort()
 		return route
 	}
 }
 
+// sort rebuilds the route tree, and regexp routes which can not be added to it ordered by rank,
+// routes with same rank are ordered by pattern
+func (g *Group) sort() {
+	g.tree = &node{}
+	g.regexps = g.regexps[:0]
+	for _, r := range g.routes {
+		if !g.tree.insert(r) {
+			if r.foldexp == nil {
+				r.foldexp = regexp.MustCompile("(?i)" + r.regexp.String())
+			}
+			g.regexps = append(g.regexps, r)
+		}
+	}
+
+	sort.Slice(g.regexps, func(i, j int) bool {
+		a, b := g.regexps[i], g.regexps[j]
+		if a.rank() != b.rank() {
+			return a.rank() < b.rank()
+		}
+		return a.pattern < b.pattern
+	})
+}
+
 func (g *Group) match(ctx *context.Context) *Route {
+	return g.lookup(ctx, true)
+}
+
+// matchPath searches route matches the request path regardless of method
+func (g *Group) matchPath(ctx *context.Context) *Route {
+	return g.lookup(ctx, false)
+}
+
+// find searches route matches the request path regardless of method, like matchPath, but
+// parameters and route are not set in context, it's used to inspect route before routing
+func (g *Group) find(ctx *context.Context) *Route {
+	if r, _ := g.tree.match(ctx.URL(), "", g.fold); r != nil {
+		return r
+	}
 
-	if r, ok := g.routes[ctx.URL()]; ok {
-		if r.match(ctx) {
+	for _, r := range g.regexps {
+		if r.matches(ctx.URL(), g.fold) {
 			return r
 		}
-	} else {
+	}
 
-		for p, r := range g.routes {
-			if strings.Contains(p, "(?P") {
-				if r.match(ctx) {
-					return r
-				}
-			}
+	return nil
+}
+
+func (g *Group) lookup(ctx *context.Context, method bool) *Route {
+
+	allow := ""
+	if method {
+		allow = ctx.Method()
+	}
+
+	if r, params := g.tree.match(ctx.URL(), allow, g.fold); r != nil {
+		for i := 0; i < len(params); i += 2 {
+			ctx.SetParam(params[i], params[i+1])
+		}
+		ctx.SetRoute(r.path)
+		return r
+	}
+
+	for _, r := range g.regexps {
+		if method && !r.allows(ctx.Method()) {
+			continue
+		}
+
+		if (g.fold && r.matchPathFold(ctx)) || (!g.fold && r.matchPath(ctx)) {
+			ctx.SetRoute(r.path)
+			return r
 		}
 	}
 
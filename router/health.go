@@ -0,0 +1,50 @@
+package router
+
+import (
+	"github.com/raythorn/zebra/context"
+	"net/http"
+	"reflect"
+	"runtime"
+)
+
+// CheckResult is result of a failed readiness check
+type CheckResult struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+func (r *router) Health(path string) {
+	r.Get(path, func(ctx *context.Context) {
+		ctx.NoCache()
+		ctx.JSONStatus(http.StatusOK, map[string]string{"status": "ok"}, false)
+	})
+}
+
+func (r *router) Ready(path string, checks ...func() error) {
+	r.Get(path, func(ctx *context.Context) {
+		ctx.NoCache()
+
+		failed := []CheckResult{}
+		for _, check := range checks {
+			if err := check(); err != nil {
+				failed = append(failed, CheckResult{Name: checkName(check), Error: err.Error()})
+			}
+		}
+
+		if len(failed) > 0 {
+			ctx.JSONStatus(http.StatusServiceUnavailable, map[string]interface{}{"status": "unavailable", "checks": failed}, false)
+			return
+		}
+
+		ctx.JSONStatus(http.StatusOK, map[string]string{"status": "ok"}, false)
+	})
+}
+
+// checkName returns name of check func, such as "main.pingDatabase"
+func checkName(check func() error) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(check).Pointer()); fn != nil {
+		return fn.Name()
+	}
+
+	return "unknown"
+}
@@ -4,41 +4,274 @@ import (
 	"fmt"
 	"github.com/raythorn/zebra/context"
 	"github.com/raythorn/zebra/oss"
+	"net/http"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 type Route struct {
+	// registered pattern, such as "/users/:id", and pattern is compiled from it
+	path    string
 	pattern string
 	regexp  *regexp.Regexp
-	actions map[string]Handler
-	group   *Group
-	oss     *oss.Oss
+	// case-insensitive regexp, only compiled for routes not in the tree
+	foldexp  *regexp.Regexp
+	actions  map[string]Handler
+	midwares map[string][]Midware
+	// midwares added by Use, run for all methods before ones of method
+	uses   []Midware
+	name   string
+	group  *Group
+	// groups of methods merged from other groups, their midwares are run instead of ones of group
+	groups map[string]*Group
+	oss    *oss.Oss
+	schema map[string]interface{}
+	// handler called when path matched but method not allowed, overrides NotAllowed of router
+	notallowed Handler
+	// HEAD requests are not served by GET handler automatically if set
+	nohead bool
+	// body is left unread for handler, see RawBody
+	raw bool
+	// pattern ends with a catch-all segment, such as "/files/*filepath"
+	catchall bool
+	// pattern has parameters with constraint, such as "/users/:id(int)"
+	constrained bool
 }
 
 func newRoute() *Route {
-	return &Route{"", nil, make(map[string]Handler), nil, nil}
+	return &Route{
+		pattern:  "",
+		actions:  make(map[string]Handler),
+		midwares: make(map[string][]Midware),
+	}
+}
+
+// MethodNotAllowed sets the handler called when path of this route matched but method not
+// allowed, it overrides the NotAllowed handler of router
+func (r *Route) MethodNotAllowed(handler Handler) *Route {
+	r.notallowed = handler
+	return r
+}
+
+// AutoHead enables or disables serving HEAD requests with GET handler of this route, it's enabled
+// by default. A HEAD handler registered explicitly always takes precedence.
+func (r *Route) AutoHead(enable bool) *Route {
+	r.nohead = !enable
+	return r
+}
+
+// Name sets name of route, such as "user.show", it identifies the route in logs and listings
+func (r *Route) Name(name string) *Route {
+	r.name = name
+	return r
+}
+
+// Use adds midwares run for all methods of route, before midwares passed with handler
+func (r *Route) Use(midwares ...Midware) *Route {
+	r.uses = append(r.uses, midwares...)
+	return r
+}
+
+// WithOSS attaches object storage to route, so path of object resolved by archive of storage is
+// saved in context for handlers like oss.ServeContent, see oss.ObjectPath and Router.Oss for a
+// route with all oss handlers
+func (r *Route) WithOSS(storage *oss.Oss) *Route {
+	r.oss = storage
+	return r
+}
+
+// RawBody leaves request body unread and form unparsed for handlers of route, such as webhooks
+// forwarding the body as is, handlers read ctx.Request().Body as a stream, and only query
+// parameters are available in form
+func (r *Route) RawBody() *Route {
+	r.raw = true
+	return r
+}
+
+// duplicate is a method of route registered more than once
+type duplicate struct {
+	method string
+	route  *Route
+}
+
+// duplicates returns methods of route which are handled by r already
+func (r *Route) duplicates(route *Route) []duplicate {
+	dups := make([]duplicate, 0)
+	for m := range route.actions {
+		if _, ok := r.actions[m]; ok {
+			dups = append(dups, duplicate{method: m, route: r})
+		}
+	}
+
+	sort.Slice(dups, func(i, j int) bool {
+		return dups[i].method < dups[j].method
+	})
+	return dups
+}
+
+// merge copies actions and midwares of route into r, used when same pattern registered again
+func (r *Route) merge(route *Route) {
+	for m, h := range route.actions {
+		if g := route.groupOf(m); g != r.group {
+			if r.groups == nil {
+				r.groups = make(map[string]*Group)
+			}
+			r.groups[m] = g
+		} else {
+			delete(r.groups, m)
+		}
+		r.actions[m] = h
+	}
+
+	for m, midwares := range route.midwares {
+		r.midwares[m] = midwares
+	}
+
+	if route.schema != nil {
+		r.schema = route.schema
+	}
+
+	if route.notallowed != nil {
+		r.notallowed = route.notallowed
+	}
+
+	if route.oss != nil {
+		r.oss = route.oss
+	}
+
+	if route.name != "" {
+		r.name = route.name
+	}
+
+	r.uses = append(r.uses, route.uses...)
+
+	if route.nohead {
+		r.nohead = true
+	}
+
+	if route.raw {
+		r.raw = true
+	}
 }
 
 func (r *Route) match(ctx *context.Context) bool {
+	return r.allows(ctx.Method()) && r.matchPath(ctx)
+}
+
+// allows checks if method is handled by route
+func (r *Route) allows(method string) bool {
+	_, _, ok := r.handler(method)
+	return ok
+}
+
+// Methods returns methods allowed by route in sorted order, HEAD is included if served by GET
+func (r *Route) Methods() []string {
+	methods := []string{}
+	for method := range r.actions {
+		methods = append(methods, method)
+	}
 
-	if _, ok := r.actions[ctx.Method()]; !ok {
-		if _, ok := r.actions["ANY"]; !ok {
-			return false
+	if _, ok := r.actions["HEAD"]; !ok && !r.nohead {
+		if _, ok := r.actions["GET"]; ok {
+			methods = append(methods, "HEAD")
 		}
 	}
 
+	sort.Strings(methods)
+	return methods
+}
+
+// handler returns handler for method and the method it registered with, HEAD falls back to GET
+// unless disabled, then ANY.
+func (r *Route) handler(method string) (Handler, string, bool) {
+	if h, ok := r.actions[method]; ok {
+		return h, method, true
+	}
+
+	if method == "HEAD" && !r.nohead {
+		if h, ok := r.actions["GET"]; ok {
+			return h, "GET", true
+		}
+	}
+
+	if h, ok := r.actions["ANY"]; ok {
+		return h, "ANY", true
+	}
+
+	return nil, "", false
+}
+
+// groupOf returns group of method, which differs from group of route if the method is merged
+// from another group
+func (r *Route) groupOf(method string) *Group {
+	if _, m, ok := r.handler(method); ok {
+		method = m
+	}
+
+	if g, ok := r.groups[method]; ok {
+		return g
+	}
+	return r.group
+}
+
+// static checks if pattern of route has no parameter
+func (r *Route) static() bool {
+	return !strings.Contains(r.pattern, "(?P")
+}
+
+// matchPath matches request path regardless of method
+func (r *Route) matchPath(ctx *context.Context) bool {
+
 	if ctx.URL() == r.pattern {
 		return true
 	}
 
-	matches := r.regexp.FindStringSubmatch(ctx.URL())
+	return r.submatch(ctx, r.regexp)
+}
+
+// matchPathFold matches request path case-insensitively, values of parameters keep their case
+func (r *Route) matchPathFold(ctx *context.Context) bool {
+	if strings.EqualFold(ctx.URL(), r.pattern) {
+		return true
+	}
+
+	if r.foldexp == nil {
+		return false
+	}
+
+	return r.submatch(ctx, r.foldexp)
+}
+
+// matches checks if path matched without setting parameters
+func (r *Route) matches(path string, fold bool) bool {
+	exp := r.regexp
+	if fold {
+		if strings.EqualFold(path, r.pattern) {
+			return true
+		}
+		exp = r.foldexp
+	} else if path == r.pattern {
+		return true
+	}
+
+	if exp == nil {
+		return false
+	}
+
+	loc := exp.FindStringIndex(path)
+	return loc != nil && loc[0] == 0 && loc[1] == len(path)
+}
+
+// submatch matches request path with exp, named groups are set in context if matched
+func (r *Route) submatch(ctx *context.Context, exp *regexp.Regexp) bool {
+	matches := exp.FindStringSubmatch(ctx.URL())
 
 	if len(matches) > 0 && matches[0] == ctx.URL() {
-		for i, name := range r.regexp.SubexpNames() {
+		for i, name := range exp.SubexpNames() {
 			// log.Println(name)
 			if len(name) > 0 {
-				ctx.Set(name, matches[i])
+				ctx.SetParam(name, matches[i])
 			}
 		}
 		return true
@@ -47,12 +280,78 @@ func (r *Route) match(ctx *context.Context) bool {
 	return false
 }
 
+// paramExp matches named parameter with optional constraint, such as ":id", ":id(int)" or ":slug([a-z-]+)"
+var paramExp = regexp.MustCompile(`:([^/#?()\.\\]+)(?:\(([^/]+?)\))?`)
+
+// constraints are builtin parameter constraints, others in parentheses are used as regexp
+var constraints = map[string]string{
+	"int":   `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// rank is priority of route when matching with regexp, lower first, constrained routes are tried
+// before ones with plain parameters, and catch-all routes are the last
+func (r *Route) rank() int {
+	switch {
+	case r.catchall:
+		return 2
+	case r.constrained:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// headWriter discards body written by GET handler serving a HEAD request, headers and status are kept
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Oss returns object storage of route added by Group.Oss or Router.Oss, nil if not exist
+func (r *Route) Oss() *oss.Oss {
+	return r.oss
+}
+
+// Pattern returns the registered pattern of route, such as "/users/:id"
+func (r *Route) Pattern() string {
+	return r.path
+}
+
+// prefix prepends prefix of group to the registered pattern and compiles it again
+func (r *Route) prefix(prefix string) {
+	r.pattern = cleanPath(prefix + r.path)
+	r.regexpCompile()
+}
+
 func (r *Route) regexpCompile() {
-	routeExp := regexp.MustCompile(`:[^/#?()\.\\]+`)
-	r.pattern = routeExp.ReplaceAllStringFunc(r.pattern, func(m string) string {
-		return fmt.Sprintf(`(?P<%s>[^/#?]+)`, m[1:])
+	r.path = r.pattern
+	r.pattern = paramExp.ReplaceAllStringFunc(r.pattern, func(m string) string {
+		sub := paramExp.FindStringSubmatch(m)
+		exp := `[^/#?]+`
+		if sub[2] != "" {
+			r.constrained = true
+			if e, ok := constraints[sub[2]]; ok {
+				exp = e
+			} else {
+				exp = sub[2]
+			}
+		}
+		return fmt.Sprintf(`(?P<%s>%s)`, sub[1], exp)
 	})
 
+	// Catch-all segment is only allowed at the end of pattern, it matches the rest of path
+	catchExp := regexp.MustCompile(`/\*([^/#?()\.\\]+)$`)
+	if catchExp.MatchString(r.pattern) {
+		r.catchall = true
+		r.pattern = catchExp.ReplaceAllString(r.pattern, `/(?P<$1>.*)`)
+	}
+
 	pattern := r.pattern
 	if !strings.HasSuffix(pattern, `\/?`) {
 		pattern += `\/?`
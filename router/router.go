@@ -1,70 +1,390 @@
 package router
 
 import (
+	gocontext "context"
+	"fmt"
 	"github.com/raythorn/zebra/context"
 	"github.com/raythorn/zebra/log"
 	"github.com/raythorn/zebra/oss"
+	"html"
+	"html/template"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type Handler func(*context.Context)
 type Midware func(*context.Context) bool
 
+// WrapFunc wraps the next handler of chain, it can run code before and after calling next, such
+// as timing or committing a transaction, and skips the rest of chain if next not called
+type WrapFunc func(next Handler) Handler
+
+// ExpectFunc checks a "Expect: 100-continue" request before the body sent, it returns 0 to
+// accept the body, or a http status code, like 413 or 417, to reject the request
+type ExpectFunc func(*context.Context) int
+
+// GroupCORSKey is set in context values before global midwares if the request has Origin header
+// and the route matched has CORS set by Group.CORS, global CORS midwares should skip the request
+const GroupCORSKey = "com.raythorn.falcon.cors.group"
+
+// Default limits of request headers, see Router.HeaderLimit
+const (
+	DefaultMaxHeaders     = 1000
+	DefaultMaxHeaderBytes = 1 << 20
+)
+
+// DefaultMaxURLLength is the default limit of request URI, see Router.MaxURLLength
+const DefaultMaxURLLength = 8 << 10
+
+// DefaultMaxPathDepth is the default limit of path segments, see Router.MaxPathDepth
+const DefaultMaxPathDepth = 100
+
+// ShutdownTimeout is the max duration to drain in-flight requests when SIGINT/SIGTERM received
+var ShutdownTimeout = 30 * time.Second
+
+// RunOptions sets timeouts of servers started by Run and RunTLS, see http.Server for meaning of
+// each. Zero fields use the ones of DefaultRunOptions, and negative ones disable the timeout.
+type RunOptions struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+// DefaultRunOptions protects servers from slow clients like slowloris by default. WriteTimeout is
+// disabled since it cuts long polling and event streams, set it if no handler streams responses.
+var DefaultRunOptions = RunOptions{
+	ReadTimeout:       30 * time.Second,
+	ReadHeaderTimeout: 10 * time.Second,
+	IdleTimeout:       120 * time.Second,
+}
+
+// Trailing slash handling modes, registered patterns are always cleaned without trailing slash
+const (
+	// SlashStrict only matches the exact path, "/users/" will not match "/users"
+	SlashStrict = iota
+	// SlashRedirect redirects to the other form if only it matched, 301 for GET/HEAD and 308 for others
+	SlashRedirect
+	// SlashMerge treats "/users/" and "/users" as the same route
+	SlashMerge
+)
+
 type Router interface {
 
 	// Add midware to router, these handler will called before every request handler.
 	// If more than one midware added, the will be called with their add order.
 	// If Midware return false, this session will be intercepted, and will return immediately，
 	// all following midwares and handlers will not be executed
+	//
+	// Midwares can also be attached to a group with Before/After, or to a single route by
+	// passing them to Get/Post/..., they are executed in order:
+	//	global(Use) -> group before -> route midwares -> handler -> group after
 	Use(Midware)
 
+	// SafeUse adds midware like Use, but a panic in it is logged and the chain continues as if it
+	// returned true, for best-effort midwares like analytics. Midwares of Use fail fast with 500.
+	SafeUse(Midware)
+
+	// UseFunc adds a wrapping midware, it wraps everything after global midwares of Use, including
+	// routing, group and route midwares, handler and not found ones. The first one added is the
+	// outermost, so it's called first and returns last.
+	//
+	//	r.UseFunc(func(next Handler) Handler {
+	//		return func(ctx *context.Context) {
+	//			tx := db.Begin()
+	//			next(ctx)
+	//			tx.Commit()
+	//		}
+	//	})
+	UseFunc(WrapFunc)
+
+	// UseForMethods adds midware for requests of methods, such as audit of POST/PUT/PATCH/DELETE,
+	// it runs after route matched and global midwares, before midwares of group and route.
+	UseForMethods([]string, Midware)
+
+	// PreRoute adds a hook run before anything depends on the route, such as rewriting path with
+	// ctx.SetPath. Global midwares can rewrite path too, but route specific settings, like
+	// Route.RawBody and Group.CORS, have been resolved with the original path then.
+	PreRoute(func(*context.Context))
+
 	// Group add a groupped router, all router has a same prefix, and should use GGet/GPut/GPatch...
-	// for add groupped router, and GSub can add a sub-group for current group
+	// for add groupped router, and GSub can add a sub-group for current group. Args must be *Route
+	// or *Group, others panic. Midwares of the returned group can be added with Before and After.
 	Group(string, ...interface{}) *Group
 
-	// Oss add a object storage sevice, which can download and upload objects(file/image...)
-	Oss(string, string, oss.Archive)
+	// Oss add a object storage sevice, which can download(GET), upload(POST) and delete(DELETE)
+	// objects(file/image...), GET a directory lists objects in it. Large objects can be uploaded
+	// in parts with PUT, see package oss for multipart upload. The returned Oss can presign
+	// download URLs. Other methods are answered with 405 and Allow header like other routes.
+	Oss(string, string, oss.Archive) *oss.Oss
 
 	// Get adds a route for a HTTP GET request to the specified matching pattern.
-	Get(string, Handler)
+	Get(string, Handler, ...Midware)
 
 	// Patch adds a route for a HTTP PATCH request to the specified matching pattern.
-	Patch(string, Handler)
+	Patch(string, Handler, ...Midware)
 
 	// Put adds a route for a HTTP PUT request to the specified matching pattern.
-	Put(string, Handler)
+	Put(string, Handler, ...Midware)
 
 	// Post adds a route for a HTTP POST request to the specified matching pattern.
-	Post(string, Handler)
+	Post(string, Handler, ...Midware)
 
 	// Delete adds a route for a HTTP DELETE request to the specified matching pattern.
-	Delete(string, Handler)
+	Delete(string, Handler, ...Midware)
 
 	// Head adds a route for a HTTP HEAD request to the specified matching pattern.
-	Head(string, Handler)
+	Head(string, Handler, ...Midware)
 
 	// Options adds a route for a HTTP OPTIONS request to the specified matching pattern.
-	Options(string, Handler)
+	Options(string, Handler, ...Midware)
 
 	// Any adds a route for any HTTP method request to the specified matching pattern.
-	Any(string, Handler)
+	Any(string, Handler, ...Midware)
+
+	// Route adds a route for HTTP requests of method to the specified matching pattern, and returns
+	// it for further configuration, such as Route("GET", "/users/:id", show).Name("user.show").Use(auth).
+	// Routes should be configured before serving.
+	Route(string, string, Handler, ...Midware) *Route
+
+	// Routes returns all registered routes, one for each method of a route, sorted by host,
+	// pattern and method, such as for dumping routes at startup or generating API docs.
+	Routes() []RouteInfo
+
+	// Match adds a route for HTTP requests of methods to the specified matching pattern, such as
+	// Match([]string{"POST", "PUT"}, "/users/:id", save) for create and update treated identically.
+	Match([]string, string, Handler, ...Midware)
 
 	// NotFound sets the handlers that are called when a no route matches a request. Throws a basic 404 by default.
+	// ctx.Route() is "" in the handler.
 	NotFound(Handler)
 
-	// NotAllowed sets the handler that are called when a not allowed http method request
+	// DefaultNotFound sets the fallback renderer used when no NotFound handler set, the default one
+	// responds 404 in JSON or HTML according to Accept header, and plain text otherwise.
+	DefaultNotFound(Handler)
+
+	// NotAllowed sets the handler that are called when a not allowed http method request, Allow header
+	// is set before called, and the allowed methods can be read with ctx.Get("Allow"), ctx.Route()
+	// is the pattern of route matched.
 	NotAllowed(Handler)
 
+	// MethodNotAllowed sets the handler called when path of route with pattern matched but method
+	// not allowed, it overrides NotAllowed for this route. For grouped routes, use Route.MethodNotAllowed.
+	MethodNotAllowed(string, Handler)
+
+	// AutoHead enables or disables serving HEAD requests with GET handler of route with pattern,
+	// it's enabled by default. For grouped routes, use Route.AutoHead.
+	AutoHead(string, bool)
+
+	// Expect adds a check for "Expect: 100-continue" requests, checks run before the body sent,
+	// if all checks accept, 100 Continue will be sent and body read, otherwise the request will
+	// be rejected with the status code returned.
+	Expect(ExpectFunc)
+
+	// LazyContinue delays 100 Continue of "Expect: 100-continue" requests until global, group and
+	// route midwares passed, so auth midwares can reject uploads before the body sent. Body and
+	// form are not available in midwares if enabled, they're read right before handler.
+	LazyContinue(bool)
+
+	// Templates sets html/template templates used by Context.Render, it's same as
+	// SetRenderer(&context.TemplateRenderer{templates})
+	Templates(*template.Template)
+
+	// SetViews loads html/template files in dir for Context.HTML, see context.Views for layout
+	// and partial composition. Set ViewOptions.Reload to reload templates on change in develop.
+	SetViews(string, ...context.ViewOptions)
+
+	// SetRenderer sets the engine used by Context.Render
+	SetRenderer(context.Renderer)
+
+	// RenderFunc adds a func which transforms or augments data before Context.Render executes,
+	// funcs are called with the order they added.
+	RenderFunc(context.RenderFunc)
+
+	// ErrorHandler sets handler responds errors of helpers like Context.BindAndRespond,
+	// context.DefaultErrorHandler is used by default
+	ErrorHandler(context.ErrorHandler)
+
+	// Schema declares expected JSON schema of responses of the route with pattern, see Route.Schema
+	Schema(string, string)
+
+	// Develop enables develop mode, response schemas will be validated in this mode, and stack
+	// traces of panics are included in 500 responses.
+	Develop(bool)
+
+	// MethodOverride enables method override, a POST request with X-HTTP-Method-Override header or
+	// _method form field of PUT, PATCH or DELETE is routed as that method. It's disabled by default.
+	MethodOverride(bool)
+
+	// TrailingSlash sets how trailing slash is handled, SlashStrict(default), SlashRedirect or SlashMerge.
+	// Routes with named regexp always match an optional trailing slash.
+	TrailingSlash(int)
+
+	// CaseInsensitive enables matching request path case-insensitively, such as "/API/Users" matches
+	// "/api/users", values of parameters keep their case. It's disabled by default, and works with
+	// TrailingSlash. Sub routers of Host have their own setting.
+	CaseInsensitive(bool)
+
+	// StrictRoutes makes registering a method and pattern again panic, such as GET "/users" twice,
+	// which replaces the former handler. It's disabled by default, and a warning is logged instead.
+	// Sub routers of Host have their own setting.
+	StrictRoutes(bool)
+
+	// Run starts a http server listening on addr with this router, it blocks until the server stopped.
+	// When SIGINT or SIGTERM received, the server will be shutdown gracefully, see Shutdown.
+	Run(string) error
+
+	// RunTLS starts a https server listening on addr with cert and key file, same as Run.
+	RunTLS(string, string, string) error
+
+	// Shutdown stops all servers started by Run/RunTLS gracefully, it stops accepting new
+	// connections and waits in-flight requests finished until ctx is done.
+	Shutdown(gocontext.Context) error
+
+	// RunOptions sets timeouts of servers started by Run and RunTLS since then, zero fields use
+	// DefaultRunOptions, such as RunOptions(RunOptions{WriteTimeout: time.Minute}).
+	RunOptions(RunOptions)
+
+	// MaxConcurrent limits number of requests handled concurrently to n, requests beyond the limit
+	// wait for at most timeout, then rejected with 503. Zero timeout rejects immediately, and n <= 0
+	// removes the limit. It should be set before server started.
+	MaxConcurrent(n int, timeout time.Duration)
+
+	// HeaderLimit limits number of request header fields and their total bytes of names and
+	// values, requests exceeding either are rejected with 431 before headers parsed into context.
+	// Defaults are DefaultMaxHeaders and DefaultMaxHeaderBytes, and n <= 0 removes the limit.
+	HeaderLimit(count int, size int)
+
+	// MaxURLLength limits bytes of request URI, path and query, requests with longer ones are
+	// rejected with 414 before matching. Default is DefaultMaxURLLength, and n <= 0 removes it.
+	MaxURLLength(n int)
+
+	// MaxPathDepth limits number of segments of request path, requests with deeper paths, like
+	// "/a/a/.../a", are answered by NotFound handler without matching. Default is
+	// DefaultMaxPathDepth, and n <= 0 removes it.
+	MaxPathDepth(n int)
+
+	// Host returns a sub router whose routes only serve requests to host matched pattern, such as
+	// "api.example.com" or "*.example.com". Midwares of sub router run after global midwares, and
+	// requests not matched by any route of sub routers fall through to the default routes. If none
+	// of them matched, but a route of sub router matched path, 405 is responded by the sub router.
+	Host(string) Router
+
+	// StaticSPA serves a single page app in dir under prefix, files in dir are served as is, and
+	// index, such as "index.html", is served for paths without file, so client side routing works.
+	// Other routes are always tried before it, even if they are under prefix.
+	StaticSPA(string, string, string)
+
+	// Favicon serves file at /favicon.ico, it's cached by clients for FaviconMaxAge
+	Favicon(string)
+
+	// Robots serves content as /robots.txt, it's cached by clients for RobotsMaxAge
+	Robots(string)
+
+	// Health registers a GET liveness probe at path, which always responds 200 {"status":"ok"}
+	Health(string)
+
+	// Ready registers a GET readiness probe at path, it responds 200 {"status":"ok"} if all checks
+	// pass, otherwise 503 with names and errors of failed checks.
+	Ready(string, ...func() error)
+
+	// Mount serves requests under prefix with a http.Handler, such as "/debug" for a pprof or admin
+	// handler, prefix is stripped from request path. Mounted handlers are tried after global
+	// midwares and before routes, the longest prefix wins.
+	Mount(string, http.Handler)
+
+	// Freeze stops further registration, routes, midwares, hosts, mounts and settings such as
+	// NotFound or MaxConcurrent added after it panic.
+	// Registration is safe from multiple goroutines, but it waits for in-flight requests, call
+	// Freeze once all routes registered so that requests are matched without locking.
+	Freeze()
+
 	// Handle is the entry point for routing.
 	Handle(http.ResponseWriter, *http.Request)
+
+	// Test runs a request built from method, target and body through Handle without network,
+	// target is a path like "/users/1?active=true" or an absolute url with host, the recorder
+	// is returned to check status, headers and body.
+	Test(method, target string, body io.Reader) (*httptest.ResponseRecorder, error)
+
+	// ServeHTTP makes Router a http.Handler, it's same as Handle.
+	ServeHTTP(http.ResponseWriter, *http.Request)
 }
 
+var _ http.Handler = (*router)(nil)
+
 type router struct {
 	route      *Group
 	group      *Group
 	midwares   []Midware
+	wrappers   []WrapFunc
+	methods    map[string][]Midware
+	preroutes  []func(*context.Context)
 	notfound   Handler
 	notallowed Handler
+	fallback   Handler
+	slash      int
+	develop    bool
+	override   bool
+	renderer   context.Renderer
+	renders    []context.RenderFunc
+	errors     context.ErrorHandler
+	expects    []ExpectFunc
+	lazy       bool
+	pool       sync.Pool
+	mutex      sync.Mutex
+	// lock guards route tables and midwares, read lock is skipped once frozen
+	lock      sync.RWMutex
+	frozen    int32
+	servers   []*http.Server
+	hosts     []*hostRouter
+	mounts    []*mount
+	semaphore chan struct{}
+	queue     time.Duration
+	// limits of request headers, no limit if <= 0
+	maxHeaders     int
+	maxHeaderBytes int
+	maxURLLength   int
+	maxPathDepth   int
+	runOptions     RunOptions
+}
+
+// mount is a http.Handler serves requests under prefix
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+// match checks path is prefix or under prefix
+func (m *mount) match(path string) bool {
+	return path == m.prefix || strings.HasPrefix(path, m.prefix+"/")
+}
+
+// hostRouter is a sub router serves requests to hosts matched pattern
+type hostRouter struct {
+	pattern string
+	router  *router
+}
+
+// match checks host matched, "*.example.com" matches any sub domains of example.com, but not itself
+func (h *hostRouter) match(host string) bool {
+	host = strings.ToLower(host)
+	if strings.HasPrefix(h.pattern, "*.") {
+		return strings.HasSuffix(host, h.pattern[1:]) && len(host) > len(h.pattern)-1
+	}
+
+	return host == h.pattern
 }
 
 func New() Router {
@@ -75,157 +395,1259 @@ func New() Router {
 		midwares:   make([]Midware, 0),
 		notfound:   nil,
 		notallowed: nil,
+		fallback:   defaultNotFound,
+
+		maxHeaders:     DefaultMaxHeaders,
+		maxHeaderBytes: DefaultMaxHeaderBytes,
+		maxURLLength:   DefaultMaxURLLength,
+		maxPathDepth:   DefaultMaxPathDepth,
 	}
 
 	r.route.pattern = "/"
+	r.pool.New = func() interface{} {
+		return context.New()
+	}
 
 	return r
 }
 
 func (r *router) Use(midware Midware) {
+	r.writable("Use")
+	defer r.lock.Unlock()
+
 	r.midwares = append(r.midwares, midware)
 }
 
+func (r *router) SafeUse(midware Midware) {
+	r.Use(safeMidware(midware))
+}
+
+// safeMidware recovers panics of midware, the request goes on as if it returned true
+func safeMidware(midware Midware) Midware {
+	return func(ctx *context.Context) (ok bool) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Error("SafeUse: midware panicked on %s %s, %v", ctx.Method(), ctx.URL(), err)
+				ok = true
+			}
+		}()
+
+		return midware(ctx)
+	}
+}
+
+func (r *router) UseFunc(wrapper WrapFunc) {
+	r.writable("UseFunc")
+	defer r.lock.Unlock()
+
+	r.wrappers = append(r.wrappers, wrapper)
+}
+
+func (r *router) UseForMethods(methods []string, midware Midware) {
+	r.writable("UseForMethods")
+	defer r.lock.Unlock()
+
+	if r.methods == nil {
+		r.methods = make(map[string][]Midware)
+	}
+
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		r.methods[method] = append(r.methods[method], midware)
+	}
+}
+
+func (r *router) PreRoute(hook func(*context.Context)) {
+	r.writable("PreRoute")
+	defer r.lock.Unlock()
+
+	r.preroutes = append(r.preroutes, hook)
+}
+
+// wrap wraps handler with wrappers added by UseFunc
+func (r *router) wrap(handler Handler) Handler {
+	for i := len(r.wrappers) - 1; i >= 0; i-- {
+		handler = r.wrappers[i](handler)
+	}
+
+	return handler
+}
+
 func (r *router) Group(prefix string, args ...interface{}) *Group {
 
+	r.writable("Group")
+	defer r.lock.Unlock()
+
 	path := cleanPath(prefix)
 
-	return r.group.group(path, args...)
+	group := newGroup()
+	group.pattern = path
+	group.group(path, args...)
+	r.group.mount(group)
+
+	return group
 }
 
-func (r *router) Oss(pattern, root string, archive oss.Archive) {
+func (r *router) Oss(pattern, root string, archive oss.Archive) *oss.Oss {
+
+	r.writable("Oss")
+	defer r.lock.Unlock()
 
 	route := r.route.insert("GET", pattern, oss.ServeContent)
 	route.actions["HEAD"] = oss.ServeContent
 	route.actions["POST"] = oss.DepositContent
+	route.actions["PUT"] = oss.UploadPart
+	route.actions["DELETE"] = oss.DeleteContent
 	route.oss = oss.New(root, archive)
+	return route.oss
+}
+
+func (r *router) Get(pattern string, handler Handler, midwares ...Midware) {
+
+	r.writable("Get")
+	defer r.lock.Unlock()
+
+	r.route.insert("GET", pattern, handler, midwares...)
 }
 
-func (r *router) Get(pattern string, handler Handler) {
+func (r *router) Patch(pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Patch")
+	defer r.lock.Unlock()
 
-	r.route.insert("GET", pattern, handler)
+	r.route.insert("PATCH", pattern, handler, midwares...)
 }
 
-func (r *router) Patch(pattern string, handler Handler) {
-	r.route.insert("PATCH", pattern, handler)
+func (r *router) Put(pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Put")
+	defer r.lock.Unlock()
+
+	r.route.insert("PUT", pattern, handler, midwares...)
 }
 
-func (r *router) Put(pattern string, handler Handler) {
-	r.route.insert("PUT", pattern, handler)
+func (r *router) Post(pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Post")
+	defer r.lock.Unlock()
+
+	r.route.insert("POST", pattern, handler, midwares...)
 }
 
-func (r *router) Post(pattern string, handler Handler) {
-	r.route.insert("POST", pattern, handler)
+func (r *router) Delete(pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Delete")
+	defer r.lock.Unlock()
+
+	r.route.insert("DELETE", pattern, handler, midwares...)
 }
 
-func (r *router) Delete(pattern string, handler Handler) {
-	r.route.insert("DELETE", pattern, handler)
+func (r *router) Head(pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Head")
+	defer r.lock.Unlock()
+
+	r.route.insert("HEAD", pattern, handler, midwares...)
 }
 
-func (r *router) Head(pattern string, handler Handler) {
-	r.route.insert("HEAD", pattern, handler)
+func (r *router) Options(pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Options")
+	defer r.lock.Unlock()
+
+	r.route.insert("OPTIONS", pattern, handler, midwares...)
 }
 
-func (r *router) Options(pattern string, handler Handler) {
-	r.route.insert("OPTIONS", pattern, handler)
+func (r *router) Any(pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Any")
+	defer r.lock.Unlock()
+
+	r.route.insert("ANY", pattern, handler, midwares...)
 }
 
-func (r *router) Any(pattern string, handler Handler) {
-	r.route.insert("ANY", pattern, handler)
+func (r *router) Route(method, pattern string, handler Handler, midwares ...Midware) *Route {
+	r.writable("Route")
+	defer r.lock.Unlock()
+
+	return r.route.insert(strings.ToUpper(method), pattern, handler, midwares...)
+}
+
+func (r *router) Match(methods []string, pattern string, handler Handler, midwares ...Midware) {
+	r.writable("Match")
+	defer r.lock.Unlock()
+
+	if len(methods) == 0 {
+		log.Panic("Match: no method of %s", pattern)
+	}
+
+	for _, method := range methods {
+		r.route.insert(strings.ToUpper(method), pattern, handler, midwares...)
+	}
 }
 
 func (r *router) NotFound(handler Handler) {
+	r.writable("NotFound")
+	defer r.lock.Unlock()
+
 	r.notfound = handler
 }
 
+func (r *router) DefaultNotFound(handler Handler) {
+	r.writable("DefaultNotFound")
+	defer r.lock.Unlock()
+
+	if handler == nil {
+		handler = defaultNotFound
+	}
+	r.fallback = handler
+}
+
 func (r *router) NotAllowed(handler Handler) {
+	r.writable("NotAllowed")
+	defer r.lock.Unlock()
+
 	r.notallowed = handler
 }
 
+func (r *router) Expect(check ExpectFunc) {
+	r.writable("Expect")
+	defer r.lock.Unlock()
+
+	r.expects = append(r.expects, check)
+}
+
+func (r *router) LazyContinue(enable bool) {
+	r.writable("LazyContinue")
+	defer r.lock.Unlock()
+
+	r.lazy = enable
+}
+
+func (r *router) Templates(templates *template.Template) {
+	r.writable("Templates")
+	defer r.lock.Unlock()
+
+	r.renderer = &context.TemplateRenderer{Templates: templates}
+}
+
+func (r *router) SetViews(dir string, opts ...context.ViewOptions) {
+	r.writable("SetViews")
+	defer r.lock.Unlock()
+
+	var opt context.ViewOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	views, err := context.NewViews(dir, opt)
+	if err != nil {
+		log.Panic("SetViews: %s", err)
+	}
+
+	r.renderer = views
+}
+
+func (r *router) SetRenderer(renderer context.Renderer) {
+	r.writable("SetRenderer")
+	defer r.lock.Unlock()
+
+	r.renderer = renderer
+}
+
+func (r *router) RenderFunc(fn context.RenderFunc) {
+	r.writable("RenderFunc")
+	defer r.lock.Unlock()
+
+	r.renders = append(r.renders, fn)
+}
+
+func (r *router) ErrorHandler(handler context.ErrorHandler) {
+	r.writable("ErrorHandler")
+	defer r.lock.Unlock()
+
+	r.errors = handler
+}
+
+func (r *router) Schema(pattern, schema string) {
+	r.writable("Schema")
+	defer r.lock.Unlock()
+
+	if route := r.registered(pattern); route != nil {
+		route.Schema(schema)
+	} else {
+		log.Error("Schema: route %s not registered", pattern)
+	}
+}
+
+func (r *router) Develop(enable bool) {
+	r.writable("Develop")
+	defer r.lock.Unlock()
+
+	r.develop = enable
+}
+
+func (r *router) MethodOverride(enable bool) {
+	r.writable("MethodOverride")
+	defer r.lock.Unlock()
+
+	r.override = enable
+}
+
+// overrideMethod changes method of a POST request to the one in X-HTTP-Method-Override header
+// or _method field of body form, only PUT, PATCH and DELETE are allowed
+func overrideMethod(ctx *context.Context) {
+	req := ctx.Request()
+	if req.Method != "POST" {
+		return
+	}
+
+	method := req.Header.Get("X-HTTP-Method-Override")
+	if method == "" {
+		if req.MultipartForm == nil && strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+			ctx.MultipartForm()
+		}
+		method = req.PostForm.Get("_method")
+	}
+
+	switch method = strings.ToUpper(method); method {
+	case "PUT", "PATCH", "DELETE":
+		req.Method = method
+	}
+}
+
+func (r *router) MethodNotAllowed(pattern string, handler Handler) {
+	r.writable("MethodNotAllowed")
+	defer r.lock.Unlock()
+
+	if route := r.registered(pattern); route != nil {
+		route.MethodNotAllowed(handler)
+	} else {
+		log.Error("MethodNotAllowed: route %s not registered", pattern)
+	}
+}
+
+func (r *router) AutoHead(pattern string, enable bool) {
+	r.writable("AutoHead")
+	defer r.lock.Unlock()
+
+	if route := r.registered(pattern); route != nil {
+		route.AutoHead(enable)
+	} else {
+		log.Error("AutoHead: route %s not registered", pattern)
+	}
+}
+
+// RouteInfo describes a method of registered route, see Router.Routes
+type RouteInfo struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	// Group is prefix of group the route added to, "" if not grouped
+	Group string `json:"group,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Host is pattern of sub router of Host, "" for default routes
+	Host string `json:"host,omitempty"`
+	// Oss is true if object storage attached to route
+	Oss bool `json:"oss,omitempty"`
+}
+
+func (r *router) Routes() []RouteInfo {
+	if r.readable() {
+		defer r.lock.RUnlock()
+	}
+
+	infos := r.routeInfos("")
+	for _, h := range r.hosts {
+		func() {
+			if h.router.readable() {
+				defer h.router.lock.RUnlock()
+			}
+			infos = append(infos, h.router.routeInfos(h.pattern)...)
+		}()
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		return a.Method < b.Method
+	})
+
+	return infos
+}
+
+// routeInfos lists routes of router, including grouped ones, host is set in all of them
+func (r *router) routeInfos(host string) []RouteInfo {
+	infos := []RouteInfo{}
+	for _, g := range []*Group{r.route, r.group} {
+		for _, route := range g.routes {
+			group := ""
+			if route.group != nil {
+				group = route.group.pattern
+			}
+
+			for method := range route.actions {
+				infos = append(infos, RouteInfo{
+					Method:  method,
+					Pattern: route.Pattern(),
+					Group:   group,
+					Name:    route.name,
+					Host:    host,
+					Oss:     route.oss != nil,
+				})
+			}
+		}
+	}
+
+	return infos
+}
+
+// registered returns the top-level route registered with pattern, nil if not exist
+func (r *router) registered(pattern string) *Route {
+	route := newRoute()
+	route.pattern = cleanPath(pattern)
+	route.regexpCompile()
+
+	return r.route.routes[route.pattern]
+}
+
+func (r *router) MaxConcurrent(n int, timeout time.Duration) {
+	r.writable("MaxConcurrent")
+	defer r.lock.Unlock()
+
+	if n <= 0 {
+		r.semaphore = nil
+	} else {
+		r.semaphore = make(chan struct{}, n)
+	}
+	r.queue = timeout
+}
+
+func (r *router) HeaderLimit(count int, size int) {
+	r.writable("HeaderLimit")
+	defer r.lock.Unlock()
+
+	r.maxHeaders = count
+	r.maxHeaderBytes = size
+}
+
+func (r *router) MaxURLLength(n int) {
+	r.writable("MaxURLLength")
+	defer r.lock.Unlock()
+
+	r.maxURLLength = n
+}
+
+func (r *router) MaxPathDepth(n int) {
+	r.writable("MaxPathDepth")
+	defer r.lock.Unlock()
+
+	r.maxPathDepth = n
+}
+
+// depthExceeded checks if path has more segments than limit
+func (r *router) depthExceeded(path string) bool {
+	return r.maxPathDepth > 0 && strings.Count(path, "/") > r.maxPathDepth
+}
+
+// urlExceeded checks if request URI is longer than limit
+func (r *router) urlExceeded(req *http.Request) bool {
+	if r.maxURLLength <= 0 {
+		return false
+	}
+
+	uri := req.RequestURI
+	if uri == "" {
+		uri = req.URL.RequestURI()
+	}
+
+	return len(uri) > r.maxURLLength
+}
+
+// headerExceeded checks if headers of request exceed limits
+func (r *router) headerExceeded(req *http.Request) bool {
+	count, size := 0, 0
+	for name, values := range req.Header {
+		count += len(values)
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+
+	return (r.maxHeaders > 0 && count > r.maxHeaders) || (r.maxHeaderBytes > 0 && size > r.maxHeaderBytes)
+}
+
+func (r *router) Host(pattern string) Router {
+	r.writable("Host")
+	defer r.lock.Unlock()
+
+	sub := New().(*router)
+	r.hosts = append(r.hosts, &hostRouter{pattern: strings.ToLower(pattern), router: sub})
+	return sub
+}
+
+func (r *router) Mount(prefix string, handler http.Handler) {
+	r.writable("Mount")
+	defer r.lock.Unlock()
+
+	prefix = strings.TrimSuffix(cleanPath(prefix), "/")
+	if prefix == "" {
+		log.Panic("Mount: prefix required, use Handle to serve all requests")
+	}
+
+	r.mounts = append(r.mounts, &mount{prefix: prefix, handler: handler})
+	sort.SliceStable(r.mounts, func(i, j int) bool {
+		return len(r.mounts[i].prefix) > len(r.mounts[j].prefix)
+	})
+}
+
+// serveMount serves request with the mounted handler of the longest prefix matched, returns
+// false if no one matched
+func (r *router) serveMount(ctx *context.Context) bool {
+	for _, m := range r.mounts {
+		if !m.match(ctx.URL()) {
+			continue
+		}
+
+		ctx.SetRoute(m.prefix)
+
+		req := ctx.Request()
+		stripped := new(http.Request)
+		*stripped = *req
+		stripped.URL = new(url.URL)
+		*stripped.URL = *req.URL
+		stripped.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, m.prefix), "/")
+		stripped.URL.RawPath = ""
+
+		m.handler.ServeHTTP(ctx.ResponseWriter(), stripped)
+		return true
+	}
+
+	return false
+}
+
+func (r *router) TrailingSlash(mode int) {
+	r.writable("TrailingSlash")
+	defer r.lock.Unlock()
+
+	r.slash = mode
+}
+
+func (r *router) CaseInsensitive(enable bool) {
+	r.writable("CaseInsensitive")
+	defer r.lock.Unlock()
+
+	r.route.fold = enable
+	r.group.fold = enable
+}
+
+func (r *router) StrictRoutes(enable bool) {
+	r.writable("StrictRoutes")
+	defer r.lock.Unlock()
+
+	r.route.strict = enable
+	r.group.strict = enable
+}
+
+func (r *router) Freeze() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.route.sort()
+	r.group.sort()
+	for _, h := range r.hosts {
+		h.router.Freeze()
+	}
+
+	atomic.StoreInt32(&r.frozen, 1)
+}
+
+// writable locks route tables and settings for registration, it panics if router frozen
+func (r *router) writable(name string) {
+	r.lock.Lock()
+	if atomic.LoadInt32(&r.frozen) == 1 {
+		r.lock.Unlock()
+		log.Panic("%s: router is frozen, routes and settings must be set before Freeze", name)
+	}
+}
+
+// readable read locks route tables for matching and serving, it returns false without locking
+// if router frozen, since tables never change since then
+func (r *router) readable() bool {
+	if atomic.LoadInt32(&r.frozen) == 1 {
+		return false
+	}
+
+	r.lock.RLock()
+	return true
+}
+
+func (r *router) RunOptions(opts RunOptions) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.runOptions = opts
+}
+
+// server returns a http server listening on addr with timeouts of run options
+func (r *router) server(addr string) *http.Server {
+	r.mutex.Lock()
+	opts := r.runOptions
+	r.mutex.Unlock()
+
+	timeout := func(d, def time.Duration) time.Duration {
+		if d == 0 {
+			return def
+		}
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadTimeout:       timeout(opts.ReadTimeout, DefaultRunOptions.ReadTimeout),
+		ReadHeaderTimeout: timeout(opts.ReadHeaderTimeout, DefaultRunOptions.ReadHeaderTimeout),
+		WriteTimeout:      timeout(opts.WriteTimeout, DefaultRunOptions.WriteTimeout),
+		IdleTimeout:       timeout(opts.IdleTimeout, DefaultRunOptions.IdleTimeout),
+	}
+}
+
+func (r *router) Run(addr string) error {
+	server := r.server(addr)
+
+	return r.listen(server, server.ListenAndServe)
+}
+
+func (r *router) RunTLS(addr, cert, key string) error {
+	server := r.server(addr)
+
+	return r.listen(server, func() error {
+		return server.ListenAndServeTLS(cert, key)
+	})
+}
+
+func (r *router) Shutdown(ctx gocontext.Context) error {
+	r.mutex.Lock()
+	servers := r.servers
+	r.servers = nil
+	r.mutex.Unlock()
+
+	var err error = nil
+	for _, server := range servers {
+		if e := server.Shutdown(ctx); e != nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// listen runs server with start and waits until it stopped or a stop signal received
+func (r *router) listen(server *http.Server, start func() error) error {
+	r.mutex.Lock()
+	r.servers = append(r.servers, server)
+	r.mutex.Unlock()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- start()
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case err := <-errc:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case s := <-sig:
+		log.Info("Signal %s received, shutting down", s.String())
+
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), ShutdownTimeout)
+		defer cancel()
+
+		return r.Shutdown(ctx)
+	}
+}
+
+func (r *router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.Handle(rw, req)
+}
+
+func (r *router) Test(method, target string, body io.Reader) (*httptest.ResponseRecorder, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same as requests from server, RequestURI is set and host defaults to example.com
+	req.RequestURI = req.URL.RequestURI()
+	req.RemoteAddr = "192.0.2.1:1234"
+	if req.Host == "" {
+		req.Host = "example.com"
+	}
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, req)
+	return rw, nil
+}
+
 func (r *router) Handle(rw http.ResponseWriter, req *http.Request) {
 
-	r.recovery()
+	// Settings are changed under lock of route tables too, so they're read after locked
+	if r.readable() {
+		defer r.lock.RUnlock()
+	}
+
+	if r.semaphore != nil {
+		if !r.acquire() {
+			http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-r.semaphore }()
+	}
+
+	// Long URLs are rejected before any work on path
+	if r.urlExceeded(req) {
+		http.Error(rw, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+		return
+	}
+
+	// Headers are copied into context by Reset, so too many or too large ones are rejected before
+	if r.headerExceeded(req) {
+		http.Error(rw, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+		return
+	}
+
+	// Contexts are reused across requests, Reset re-initializes all the state, body is read after
+	// route matched, so routes of RawBody keep it unread
+	ctx := r.pool.Get().(*context.Context)
+	defer r.pool.Put(ctx)
+	ctx.ResetHeader(rw, req)
+	ctx.SetRenderer(r.renderer)
+	ctx.SetRenderFuncs(r.renders...)
+	ctx.SetErrorHandler(r.errors)
+	// Funcs of ctx.Defer run in scope of recovery, so cleanup happens and its panics are recovered
+	defer r.recovery(ctx)
+	defer ctx.Finish()
+
+	// Too deep paths can not match any route, they are not walked through route tree
+	if r.depthExceeded(ctx.URL()) {
+		r.notFound(ctx)
+		return
+	}
+
+	for _, hook := range r.preroutes {
+		if hook(ctx); ctx.IsAborted() {
+			return
+		}
+	}
 
-	ctx := context.New()
-	ctx.Reset(rw, req)
+	route := r.find(ctx)
+	if r.rawBody(ctx, route) {
+		ctx.KeepRawBody()
+	}
+
+	if ctx.Expecting() {
+		for _, check := range r.expects {
+			if code := check(ctx); code != 0 {
+				ctx.Header("Connection", "close")
+				ctx.WriteHeader(code)
+				return
+			}
+		}
+		if !r.lazy {
+			ctx.Continue()
+		}
+	}
+	ctx.ReadBody()
+
+	if r.override {
+		overrideMethod(ctx)
+	}
 
 	// log.Printf("URI: %s", ctx.URI())
 	// log.Printf("PATH: %s", ctx.URL())
 
+	// CORS of group takes precedence over global one, so the route is resolved before midwares
+	if ctx.Get("Origin") != "" && route != nil && route.groupOf(ctx.Method()).corsMidware() != nil {
+		ctx.SetValue(GroupCORSKey, true)
+	}
+
 	//Call all midware first
 	if len(r.midwares) > 0 {
 		for _, midware := range r.midwares {
-			if !midware(ctx) {
+			if !midware(ctx) || ctx.IsAborted() {
 				return
 			}
 		}
 	}
 
-	//Search Group
-	route := r.group.match(ctx)
-	if route != nil {
-		var handler Handler = nil
-		var ok bool = false
+	r.wrap(r.dispatch)(ctx)
+}
+
+// dispatch serves request with sub router of host, mounted handler or route matched
+func (r *router) dispatch(ctx *context.Context) {
+	rw, req := ctx.ResponseWriter(), ctx.Request()
 
-		// Check route exist or not, if not eixst return with notfound handler
-		if handler, ok = route.actions[ctx.Method()]; !ok {
-			if r.notfound != nil {
-				r.notfound(ctx)
-			} else {
-				http.NotFound(rw, req)
+	if r.serveHost(ctx) {
+		return
+	}
+
+	if r.serveMount(ctx) {
+		return
+	}
+
+	route := r.match(ctx)
+	if route == nil && r.slash != SlashStrict {
+		route = r.matchSlash(ctx)
+		if route != nil && r.slash == SlashRedirect {
+			code := http.StatusMovedPermanently
+			if req.Method != "GET" && req.Method != "HEAD" {
+				code = http.StatusPermanentRedirect
+			}
+
+			target := req.URL.Path
+			req.URL.Path = toggleSlash(target)
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
 			}
 
+			http.Redirect(rw, req, target, code)
+			return
+		}
+	}
+
+	if route == nil {
+		if r.hostNotAllowed(ctx) {
+			return
+		}
+
+		if route = r.matchPath(ctx); route != nil {
+			r.methodNotAllowed(ctx, route)
+		} else {
+			r.notFound(ctx)
+		}
+		return
+	}
+
+	r.serve(ctx, route)
+}
+
+// methodNotAllowed responds 405 for route matched path but not method, preflight of routes
+// without OPTIONS handler is answered by CORS of group
+func (r *router) methodNotAllowed(ctx *context.Context, route *Route) {
+	if cors := route.group.corsMidware(); cors != nil && isPreflight(ctx) {
+		if !runCORS(ctx, cors) {
 			return
 		}
+	}
+
+	r.notAllowed(ctx, route)
+}
+
+// acquire takes a slot of concurrent requests, waits for at most queue timeout
+func (r *router) acquire() bool {
+	select {
+	case r.semaphore <- struct{}{}:
+		return true
+	default:
+	}
+
+	if r.queue <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(r.queue)
+	defer timer.Stop()
+
+	select {
+	case r.semaphore <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// serveHost serves request with the first sub router of host which has route matched, returns
+// false if no one found
+func (r *router) serveHost(ctx *context.Context) bool {
+	for _, h := range r.hosts {
+		if !h.match(ctx.Host()) {
+			continue
+		}
+
+		if h.router.readable() {
+			defer h.router.lock.RUnlock()
+		}
+
+		route := h.router.match(ctx)
+		if route == nil {
+			continue
+		}
 
-		if route.group != nil && len(route.group.before) > 0 {
-			for _, midware := range route.group.before {
-				if !midware(ctx) {
-					return
-				}
+		for _, midware := range h.router.midwares {
+			if !midware(ctx) || ctx.IsAborted() {
+				return true
 			}
 		}
 
-		handler(ctx)
+		h.router.wrap(func(ctx *context.Context) {
+			h.router.serve(ctx, route)
+		})(ctx)
+		return true
+	}
+
+	return false
+}
+
+// hostNotAllowed responds 405 with the first sub router of host which has route matched path
+// but not method, after midwares of the sub router, returns false if no one found
+func (r *router) hostNotAllowed(ctx *context.Context) bool {
+	for _, h := range r.hosts {
+		if !h.match(ctx.Host()) {
+			continue
+		}
+
+		if h.router.readable() {
+			defer h.router.lock.RUnlock()
+		}
+
+		route := h.router.matchPath(ctx)
+		if route == nil {
+			continue
+		}
 
-		if route.group != nil && len(route.group.after) > 0 {
-			for _, midware := range route.group.after {
-				if !midware(ctx) {
-					return
-				}
+		for _, midware := range h.router.midwares {
+			if !midware(ctx) || ctx.IsAborted() {
+				return true
 			}
 		}
 
+		h.router.methodNotAllowed(ctx, route)
+		return true
+	}
+
+	return false
+}
+
+// match searches groups first, then routes
+func (r *router) match(ctx *context.Context) *Route {
+	if route := r.group.match(ctx); route != nil {
+		return route
+	}
+
+	return r.route.match(ctx)
+}
+
+// matchPath searches route matches request path regardless of method
+func (r *router) matchPath(ctx *context.Context) *Route {
+	if route := r.group.matchPath(ctx); route != nil {
+		return route
+	}
+
+	return r.route.matchPath(ctx)
+}
+
+// rawBody checks if route, or route of sub router serving host, keeps body unread
+func (r *router) rawBody(ctx *context.Context, route *Route) bool {
+	for _, h := range r.hosts {
+		if !h.match(ctx.Host()) {
+			continue
+		}
+
+		if h.router.readable() {
+			defer h.router.lock.RUnlock()
+		}
+
+		if route := h.router.find(ctx); route != nil {
+			return route.raw
+		}
+	}
+
+	return route != nil && route.raw
+}
+
+// find searches route of path like matchPath, but nothing is set in context
+func (r *router) find(ctx *context.Context) *Route {
+	if route := r.group.find(ctx); route != nil {
+		return route
+	}
+
+	return r.route.find(ctx)
+}
+
+// matchSlash matches with the trailing slash added or removed, if matched, request path will be
+// kept as the matched one, it should be restored by caller if the request will be redirected.
+func (r *router) matchSlash(ctx *context.Context) *Route {
+	path := ctx.Request().URL.Path
+	if path == "/" {
+		return nil
+	}
+
+	ctx.Request().URL.Path = toggleSlash(path)
+	if route := r.match(ctx); route != nil {
+		return route
+	}
+
+	ctx.Request().URL.Path = path
+	return nil
+}
+
+// serve runs group midwares, route midwares and handler of route
+func (r *router) serve(ctx *context.Context, route *Route) {
+
+	handler, method, ok := route.handler(ctx.Method())
+	if !ok {
+		// Check route exist or not, if not eixst return with notfound handler
+		r.notFound(ctx)
 		return
 	}
 
-	// Search route
-	route = r.route.match(ctx)
-	if route != nil {
+	if ctx.Method() == "HEAD" && method == "GET" {
+		ctx.SetResponseWriter(&headWriter{ResponseWriter: ctx.ResponseWriter()})
+	}
+
+	// Valid presigned oss requests are authorized by signature, group and route midwares are skipped
+	presigned := false
+	if route.oss != nil && oss.Presigned(ctx) {
+		if err := route.oss.VerifyPresigned(ctx); err != nil {
+			log.Debug("Presigned %s rejected, %s", ctx.URL(), err)
+			ctx.Abort(http.StatusForbidden)
+			return
+		}
+		presigned = true
+	}
 
-		if h, ok := route.actions[ctx.Method()]; ok {
-			if route.oss != nil {
-				ctx.Set(oss.OssPathKey, route.oss.Archive().Path(route.oss, ctx))
+	group := route.groupOf(method)
+	if cors := group.corsMidware(); cors != nil {
+		if !runCORS(ctx, cors) {
+			return
+		}
+	}
+
+	for _, midware := range r.methods[ctx.Method()] {
+		if !midware(ctx) || ctx.IsAborted() {
+			return
+		}
+	}
+
+	if !presigned && group != nil {
+		for _, midware := range group.befores() {
+			if !midware(ctx) || ctx.IsAborted() {
+				return
 			}
+		}
+	}
 
-			h(ctx)
+	// Objects can not be located without archive or out of root, oss handlers respond not found
+	// without path
+	if route.oss != nil {
+		ctx.SetValue(oss.OssKey, route.oss)
+		if respath := route.oss.Locate(ctx); respath != "" {
+			ctx.SetValue(oss.OssPathKey, respath)
 		}
+	}
 
+	if !presigned {
+		for _, midware := range route.uses {
+			if !midware(ctx) || ctx.IsAborted() {
+				return
+			}
+		}
+
+		for _, midware := range route.midwares[method] {
+			if !midware(ctx) || ctx.IsAborted() {
+				return
+			}
+		}
+	}
+
+	if r.develop && route.schema != nil {
+		rw := &bufferWriter{ResponseWriter: ctx.ResponseWriter()}
+		ctx.SetResponseWriter(rw)
+		defer validateResponse(ctx, route.schema, rw)
+	}
+
+	// Body of lazy continued request is read after all midwares accepted it
+	ctx.Continue()
+
+	ctx.Mark("handler")
+	ctx.Invoke(func() { handler(ctx) })
+	ctx.Mark("after")
+
+	if ctx.IsAborted() {
 		return
 	}
 
-	//Not found
+	if group != nil {
+		for _, midware := range group.afters() {
+			if !midware(ctx) || ctx.IsAborted() {
+				return
+			}
+		}
+	}
+}
+
+// runCORS runs CORS midware of group, GroupCORSKey is cleared so it's not skipped like global ones
+func runCORS(ctx *context.Context, cors Midware) bool {
+	ctx.SetValue(GroupCORSKey, false)
+	return cors(ctx) && !ctx.IsAborted()
+}
+
+// isPreflight checks if request is a CORS preflight request
+func isPreflight(ctx *context.Context) bool {
+	return ctx.Method() == "OPTIONS" && ctx.Get("Origin") != "" && ctx.Get("Access-Control-Request-Method") != ""
+}
+
+// notAllowed responds 405 with handler of route, or router if route has no one, Allow header of
+// methods allowed by route is set, handlers can read it with ctx.Get("Allow")
+func (r *router) notAllowed(ctx *context.Context, route *Route) {
+	allow := strings.Join(route.Methods(), ", ")
+	ctx.Header("Allow", allow)
+	ctx.Set("Allow", allow)
+
+	switch {
+	case route.notallowed != nil:
+		route.notallowed(ctx)
+	case r.notallowed != nil:
+		r.notallowed(ctx)
+	default:
+		defaultError(ctx, http.StatusMethodNotAllowed)
+	}
+}
+
+// notFound responds with the NotFound handler, route of ctx is cleared since no route matched
+func (r *router) notFound(ctx *context.Context) {
+	ctx.SetRoute("")
+
 	if r.notfound != nil {
 		r.notfound(ctx)
 	} else {
-		http.NotFound(rw, req)
+		r.fallback(ctx)
 	}
 }
 
-func (r *router) recovery() {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Error("%s\n", err)
+// defaultNotFound responds 404 with the same shape as other errors of app
+func defaultNotFound(ctx *context.Context) {
+	defaultError(ctx, http.StatusNotFound)
+}
+
+// defaultError responds status code in format negotiated by Accept header, a JSON error envelope
+// for JSON clients, a html page for browsers, and plain text otherwise, or if Accept is absent
+func defaultError(ctx *context.Context, code int) {
+	text := fmt.Sprintf("%d %s", code, http.StatusText(code))
+
+	// Clients accept anything, such as curl with "*/*", get plain text
+	format := "text/plain"
+	if ctx.AcceptsJSON() || ctx.AcceptsHTML() {
+		format = ctx.Accepts("application/json", "text/html", "text/plain")
+	}
+
+	switch format {
+	case "application/json":
+		ctx.Header("Content-Type", context.WithCharset("application/json"))
+		ctx.WriteHeader(code)
+		ctx.WriteString(fmt.Sprintf(`{"error":{"code":%d,"message":%q}}`, code, http.StatusText(code)))
+	case "text/html":
+		ctx.Header("Content-Type", context.WithCharset("text/html"))
+		ctx.WriteHeader(code)
+		ctx.WriteString("<html><head><title>" + text + "</title></head><body><h1>" + text + "</h1></body></html>")
+	default:
+		if code == http.StatusNotFound {
+			http.NotFound(ctx.ResponseWriter(), ctx.Request())
+		} else {
+			http.Error(ctx.ResponseWriter(), http.StatusText(code), code)
 		}
-	}()
+	}
+}
+
+// MaxBody returns a ExpectFunc which rejects requests declared body larger than size with 413
+func MaxBody(size int64) ExpectFunc {
+	return func(ctx *context.Context) int {
+		if ctx.Request().ContentLength > size {
+			return http.StatusRequestEntityTooLarge
+		}
+		return 0
+	}
+}
+
+func toggleSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/")
+	}
+
+	return path + "/"
+}
+
+// recovery recovers panics of midwares and handlers, 500 is written unless handler already wrote
+// response, such as streaming, then the client gets the truncated response
+func (r *router) recovery(ctx *context.Context) {
+	err := recover()
+	if err == nil {
+		return
+	}
+
+	// ErrAbortHandler is used to abort response by net/http
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+
+	stack := debug.Stack()
+	log.Error("Panic: %s %s, %v\n%s", ctx.Method(), ctx.URL(), err, stack)
+	if ctx.Written() {
+		return
+	}
+
+	// Stack is only leaked to clients in develop mode
+	if !r.develop {
+		stack = nil
+	}
+	panicError(ctx, ctx.RequestID(), stack)
+}
+
+// panicError responds 500 of a recovered panic in format accepted by client like defaultError,
+// with request id if set, and stack if not nil
+func panicError(ctx *context.Context, id string, stack []byte) {
+	code := http.StatusInternalServerError
+	text := fmt.Sprintf("%d %s", code, http.StatusText(code))
+
+	format := "text/plain"
+	if ctx.AcceptsJSON() || ctx.AcceptsHTML() {
+		format = ctx.Accepts("application/json", "text/html", "text/plain")
+	}
+
+	switch format {
+	case "application/json":
+		detail := struct {
+			Code      int    `json:"code"`
+			Message   string `json:"message"`
+			RequestID string `json:"request_id,omitempty"`
+			Stack     string `json:"stack,omitempty"`
+		}{code, "internal error", id, string(stack)}
+		ctx.JSONStatus(code, map[string]interface{}{"error": detail}, false)
+	case "text/html":
+		page := "<html><head><title>" + text + "</title></head><body><h1>" + text + "</h1>"
+		if id != "" {
+			page += "<p>Request ID: " + html.EscapeString(id) + "</p>"
+		}
+		if stack != nil {
+			page += "<pre>" + html.EscapeString(string(stack)) + "</pre>"
+		}
+		ctx.Header("Content-Type", context.WithCharset("text/html"))
+		ctx.WriteHeader(code)
+		ctx.WriteString(page + "</body></html>")
+	default:
+		body := http.StatusText(code)
+		if id != "" {
+			body += "\nRequest ID: " + id
+		}
+		if stack != nil {
+			body += "\n\n" + string(stack)
+		}
+		http.Error(ctx.ResponseWriter(), body, code)
+	}
 }
@@ -0,0 +1,1872 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/oss"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContextPoolReset(t *testing.T) {
+	r := New()
+	r.Get("/user/:id", func(ctx *context.Context) {
+		if ctx.Get("id") == "2" && ctx.Get("name") != "" {
+			t.Errorf("data leaked from previous request: %s", ctx.Get("name"))
+		}
+		ctx.WriteString(ctx.Get("id"))
+	})
+
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/user/1?name=zebra", nil))
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/user/2", nil))
+	if rw.Body.String() != "2" {
+		t.Errorf("expect body 2, got %s", rw.Body.String())
+	}
+}
+
+func TestDefaultNotFoundJSON(t *testing.T) {
+	r := New()
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	req.Header.Set("Accept", "application/json")
+	rw := httptest.NewRecorder()
+	r.Handle(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect status 404, got %d", rw.Code)
+	}
+
+	if ct := rw.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+
+	if body := rw.Body.String(); body != `{"error":{"code":404,"message":"Not Found"}}` {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	r := New()
+	r.Get("/users", func(ctx *context.Context) {
+		ctx.WriteString("users")
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/users/", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("strict: expect status 404, got %d", rw.Code)
+	}
+
+	r.TrailingSlash(SlashRedirect)
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/users/?page=2", nil))
+	if rw.Code != http.StatusMovedPermanently || rw.Header().Get("Location") != "/users?page=2" {
+		t.Errorf("redirect: unexpected response %d %s", rw.Code, rw.Header().Get("Location"))
+	}
+
+	r.TrailingSlash(SlashMerge)
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/users/", nil))
+	if rw.Code != http.StatusOK || rw.Body.String() != "users" {
+		t.Errorf("merge: unexpected response %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestResponseSchema(t *testing.T) {
+	r := New()
+	r.Develop(true)
+	r.Get("/user", func(ctx *context.Context) {
+		ctx.JSON(map[string]interface{}{"id": "1", "name": "zebra"}, false)
+	})
+	r.Schema("/user", `{"type": "object", "required": ["id", "name"], "properties": {"id": {"type": "integer"}}}`)
+
+	var violations []string
+	report := schemaViolation
+	schemaViolation = func(ctx *context.Context, v []string) {
+		violations = append(violations, v...)
+	}
+	defer func() { schemaViolation = report }()
+
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/user", nil))
+	if len(violations) != 1 || violations[0] != "$.id: expect type integer, got string" {
+		t.Errorf("unexpected violations %v", violations)
+	}
+
+	violations = nil
+	r.Develop(false)
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/user", nil))
+	if len(violations) != 0 {
+		t.Errorf("schema should not be validated in production: %v", violations)
+	}
+}
+
+func TestRenderFunc(t *testing.T) {
+	r := New()
+	r.Templates(template.Must(template.New("form").Parse(`<input name="csrf" value="{{.csrf}}">{{.title}}`)))
+	r.RenderFunc(func(ctx *context.Context, data interface{}) interface{} {
+		if m, ok := data.(map[string]interface{}); ok {
+			m["csrf"] = "token-" + ctx.Get("session")
+		}
+		return data
+	})
+	r.Get("/form", func(ctx *context.Context) {
+		ctx.Render("form", map[string]interface{}{"title": "Login"})
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/form?session=abc", nil))
+
+	if body := rw.Body.String(); body != `<input name="csrf" value="token-abc">Login` {
+		t.Errorf("unexpected render output %s", body)
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	r := New()
+	r.Expect(MaxBody(16))
+	r.Post("/upload", func(ctx *context.Context) {
+		ctx.WriteString(string(ctx.Body()))
+	})
+
+	upload := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader(body))
+		req.Header.Set("Expect", "100-continue")
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+		return rw
+	}
+
+	if rw := upload("zebra"); rw.Code != http.StatusOK || rw.Body.String() != "zebra" {
+		t.Errorf("acceptable body should be read, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	if rw := upload(strings.Repeat("zebra", 10)); rw.Code != http.StatusRequestEntityTooLarge || rw.Body.Len() != 0 {
+		t.Errorf("oversize body should be rejected, got %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+// countingReader records if body was read
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.read += n
+	return n, err
+}
+
+func TestLazyContinue(t *testing.T) {
+	r := New()
+	r.LazyContinue(true)
+	r.Post("/upload", func(ctx *context.Context) {
+		ctx.WriteString(string(ctx.Body()))
+	}, func(ctx *context.Context) bool {
+		if ctx.Get("Authorization") == "" {
+			ctx.Abort(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	})
+
+	upload := func(auth string) (*httptest.ResponseRecorder, *countingReader) {
+		body := &countingReader{Reader: strings.NewReader("zebra")}
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Expect", "100-continue")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+		return rw, body
+	}
+
+	if rw, body := upload(""); rw.Code != http.StatusUnauthorized || body.read != 0 {
+		t.Errorf("unauthorized upload should be rejected before body read, got %d, read %d", rw.Code, body.read)
+	}
+
+	if rw, body := upload("Bearer zebra"); rw.Code != http.StatusOK || rw.Body.String() != "zebra" || body.read != 5 {
+		t.Errorf("authorized upload should be read, got %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+type fakeRenderer struct {
+	name string
+	data interface{}
+}
+
+func (f *fakeRenderer) Render(w io.Writer, name string, data interface{}) error {
+	f.name = name
+	f.data = data
+	_, err := io.WriteString(w, "fake")
+	return err
+}
+
+func TestSetRenderer(t *testing.T) {
+	renderer := &fakeRenderer{}
+
+	r := New()
+	r.SetRenderer(renderer)
+	r.Get("/page", func(ctx *context.Context) {
+		ctx.Render("index.pug", "zebra")
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/page", nil))
+
+	if renderer.name != "index.pug" || renderer.data != "zebra" || rw.Body.String() != "fake" {
+		t.Errorf("render not delegated: %s %v %s", renderer.name, renderer.data, rw.Body.String())
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.NotAllowed(func(ctx *context.Context) {
+		ctx.WriteHeader(http.StatusMethodNotAllowed)
+		ctx.WriteString("global")
+	})
+	r.Get("/users", func(ctx *context.Context) {})
+	r.Get("/reports", func(ctx *context.Context) {})
+	r.MethodNotAllowed("/reports", func(ctx *context.Context) {
+		ctx.WriteHeader(http.StatusMethodNotAllowed)
+		ctx.WriteString("reports are read-only")
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("DELETE", "/reports", nil))
+	if rw.Code != http.StatusMethodNotAllowed || rw.Body.String() != "reports are read-only" {
+		t.Errorf("route handler expected, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("DELETE", "/users", nil))
+	if rw.Code != http.StatusMethodNotAllowed || rw.Body.String() != "global" {
+		t.Errorf("global handler expected, got %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestAutoHead(t *testing.T) {
+	r := New()
+	r.Get("/users", func(ctx *context.Context) {
+		ctx.Header("X-Total", "2")
+		ctx.WriteHeader(http.StatusOK)
+		ctx.WriteString("alice,bob")
+	})
+	r.Get("/reports", func(ctx *context.Context) {
+		ctx.WriteString("reports")
+	})
+	r.AutoHead("/reports", false)
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("HEAD", "/users", nil))
+	if rw.Code != http.StatusOK || rw.Header().Get("X-Total") != "2" || rw.Body.Len() != 0 {
+		t.Errorf("HEAD expected served by GET without body, got %d %q %q", rw.Code, rw.Header().Get("X-Total"), rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("HEAD", "/reports", nil))
+	if rw.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HEAD expected not allowed when disabled, got %d", rw.Code)
+	}
+
+	r.Head("/users", func(ctx *context.Context) {
+		ctx.Header("X-Head", "1")
+	})
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("HEAD", "/users", nil))
+	if rw.Header().Get("X-Head") != "1" || rw.Header().Get("X-Total") != "" {
+		t.Errorf("explicit HEAD handler expected")
+	}
+}
+
+func TestCatchAll(t *testing.T) {
+	r := New()
+	r.Get("/files/*filepath", func(ctx *context.Context) {
+		ctx.WriteString("file:" + ctx.Param("filepath"))
+	})
+	r.Get("/files/readme", func(ctx *context.Context) {
+		ctx.WriteString("readme")
+	})
+	r.Get("/files/:name/meta", func(ctx *context.Context) {
+		ctx.WriteString("meta:" + ctx.Param("name"))
+	})
+
+	cases := map[string]string{
+		"/files/a/b/c.txt":   "file:a/b/c.txt",
+		"/files/":            "file:",
+		"/files/readme":      "readme",
+		"/files/report/meta": "meta:report",
+	}
+
+	for path, body := range cases {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", path, nil))
+		if rw.Body.String() != body {
+			t.Errorf("%s: expect %q, got %q", path, body, rw.Body.String())
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	r := New()
+	r.Get("/users", Versions(map[string]Handler{
+		"1": func(ctx *context.Context) { ctx.WriteString("v1") },
+		"2": func(ctx *context.Context) { ctx.WriteString("v2:" + ctx.Get(VersionKey)) },
+	}))
+
+	cases := []struct {
+		accept string
+		code   int
+		body   string
+	}{
+		{"application/vnd.api+json;version=1", http.StatusOK, "v1"},
+		{"text/html, application/vnd.api+json; version=2", http.StatusOK, "v2:2"},
+		{"application/json", http.StatusOK, "v2:2"},
+		{"application/vnd.api+json;version=3", http.StatusNotAcceptable, ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Header.Set("Accept", c.accept)
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+
+		if rw.Code != c.code || (c.body != "" && rw.Body.String() != c.body) {
+			t.Errorf("%s: expect %d %q, got %d %q", c.accept, c.code, c.body, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestParamConstraints(t *testing.T) {
+	r := New()
+	r.Get("/users/:id(int)", func(ctx *context.Context) {
+		ctx.WriteString("id:" + ctx.Param("id"))
+	})
+	r.Get("/users/:name", func(ctx *context.Context) {
+		ctx.WriteString("name:" + ctx.Param("name"))
+	})
+	r.Get("/orders/:uuid(uuid)", func(ctx *context.Context) {
+		ctx.WriteString("order:" + ctx.Param("uuid"))
+	})
+	r.Get("/posts/:slug([a-z-]+)", func(ctx *context.Context) {
+		ctx.WriteString("post:" + ctx.Param("slug"))
+	})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/42", http.StatusOK, "id:42"},
+		{"/users/alice", http.StatusOK, "name:alice"},
+		{"/orders/9b2e6f1c-3f4a-4d2b-8c7e-1a2b3c4d5e6f", http.StatusOK, "order:9b2e6f1c-3f4a-4d2b-8c7e-1a2b3c4d5e6f"},
+		{"/orders/42", http.StatusNotFound, ""},
+		{"/posts/hello-world", http.StatusOK, "post:hello-world"},
+		{"/posts/Hello_World", http.StatusNotFound, ""},
+	}
+
+	for _, c := range cases {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", c.path, nil))
+		if rw.Code != c.code || (c.body != "" && rw.Body.String() != c.body) {
+			t.Errorf("%s: expect %d %q, got %d %q", c.path, c.code, c.body, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	r := New()
+	r.Get("/", func(ctx *context.Context) { ctx.WriteString("default") })
+	r.Get("/about", func(ctx *context.Context) { ctx.WriteString("about") })
+	r.Host("api.example.com").Get("/", func(ctx *context.Context) { ctx.WriteString("api") })
+	r.Host("*.example.com").Get("/", func(ctx *context.Context) { ctx.WriteString("tenant:" + ctx.SubDomain()) })
+
+	cases := map[string]string{
+		"http://api.example.com/":       "api",
+		"http://API.example.com:8080/":  "api",
+		"http://acme.example.com/":      "tenant:acme",
+		"http://example.com/":           "default",
+		"http://www.other.com/":         "default",
+		"http://acme.example.com/about": "about",
+	}
+
+	for target, body := range cases {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", target, nil))
+		if rw.Body.String() != body {
+			t.Errorf("%s: expect %q, got %q", target, body, rw.Body.String())
+		}
+	}
+}
+
+func TestHostNotAllowed(t *testing.T) {
+	r := New()
+	r.Get("/", func(ctx *context.Context) { ctx.WriteString("default") })
+	api := r.Host("api.example.com")
+	api.Get("/users", func(ctx *context.Context) { ctx.WriteString("users") })
+	api.Post("/users", func(ctx *context.Context) { ctx.WriteString("created") })
+	api.NotAllowed(func(ctx *context.Context) {
+		ctx.WriteHeader(http.StatusMethodNotAllowed)
+		ctx.WriteString("api: " + ctx.Get("Allow"))
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("DELETE", "http://api.example.com/users", nil))
+	if rw.Code != http.StatusMethodNotAllowed || rw.Header().Get("Allow") != "GET, HEAD, POST" || rw.Body.String() != "api: GET, HEAD, POST" {
+		t.Errorf("expect 405 of host router, got %d %q %q", rw.Code, rw.Header().Get("Allow"), rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "http://api.example.com/missing", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for path not matched, got %d", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("DELETE", "http://www.example.com/users", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect routes of other hosts ignored, got %d", rw.Code)
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	const limit = 3
+
+	r := New()
+	r.MaxConcurrent(limit, 0)
+
+	started := make(chan struct{}, limit)
+	release := make(chan struct{})
+	r.Get("/slow", func(ctx *context.Context) {
+		started <- struct{}{}
+		<-release
+		ctx.WriteString("done")
+	})
+
+	var wg sync.WaitGroup
+	codes := make([]int, limit)
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rw := httptest.NewRecorder()
+			r.Handle(rw, httptest.NewRequest("GET", "/slow", nil))
+			codes[i] = rw.Code
+		}(i)
+	}
+
+	for i := 0; i < limit; i++ {
+		<-started
+	}
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/slow", nil))
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect 503 beyond limit, got %d", rw.Code)
+	}
+
+	close(release)
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d: expect 200, got %d", i, code)
+		}
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/slow", nil))
+	if rw.Code != http.StatusOK {
+		t.Errorf("expect 200 after slots released, got %d", rw.Code)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	var calls []string
+
+	r := New()
+	r.Use(func(ctx *context.Context) bool {
+		if ctx.Get("token") == "" {
+			ctx.AbortWithJSON(http.StatusUnauthorized, map[string]string{"error": "token required"})
+		}
+		return true
+	})
+	g := &Group{}
+	r.Group("/admin", g.Sub("", g.Get("/users", func(ctx *context.Context) {
+		calls = append(calls, "handler")
+	})).Before(func(ctx *context.Context) bool {
+		calls = append(calls, "before")
+		if ctx.Get("token") != "admin" {
+			ctx.Abort(http.StatusForbidden)
+		}
+		return true
+	}).After(func(ctx *context.Context) bool {
+		calls = append(calls, "after")
+		return true
+	}))
+	r.Get("/users", func(ctx *context.Context) {
+		calls = append(calls, "handler")
+		ctx.Intercept([]byte("intercepted"), http.StatusTeapot, "handler intercepted")
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/users", nil))
+	if rw.Code != http.StatusUnauthorized || rw.Body.String() != `{"error":"token required"}` || len(calls) != 0 {
+		t.Errorf("expect aborted by global midware, got %d %s %v", rw.Code, rw.Body.String(), calls)
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/users?token=user", nil))
+	if rw.Code != http.StatusTeapot || rw.Body.String() != "intercepted" {
+		t.Errorf("expect intercepted without panic, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	calls = nil
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/admin/users?token=user", nil))
+	if rw.Code != http.StatusForbidden || strings.Join(calls, ",") != "before" {
+		t.Errorf("expect aborted by group midware, got %d %v", rw.Code, calls)
+	}
+
+	calls = nil
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/admin/users?token=admin", nil))
+	if rw.Code != http.StatusOK || strings.Join(calls, ",") != "before,handler,after" {
+		t.Errorf("expect all called, got %d %v", rw.Code, calls)
+	}
+}
+
+func TestGroupMidwares(t *testing.T) {
+	var calls []string
+
+	r := New()
+	g := &Group{}
+	r.Group("/admin", g.Get("/users", func(ctx *context.Context) {
+		calls = append(calls, "admin")
+	})).Before(func(ctx *context.Context) bool {
+		calls = append(calls, "before")
+		return ctx.Get("token") == "admin"
+	}).After(func(ctx *context.Context) bool {
+		calls = append(calls, "after")
+		return true
+	})
+	r.Group("/public", g.Get("/users", func(ctx *context.Context) {
+		calls = append(calls, "public")
+	}))
+
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/users?token=admin", nil))
+	if strings.Join(calls, ",") != "before,admin,after" {
+		t.Errorf("expect before,admin,after, got %v", calls)
+	}
+
+	calls = nil
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/users", nil))
+	if strings.Join(calls, ",") != "before" {
+		t.Errorf("expect short-circuited by before, got %v", calls)
+	}
+
+	calls = nil
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/public/users", nil))
+	if strings.Join(calls, ",") != "public" {
+		t.Errorf("expect midwares of other group not called, got %v", calls)
+	}
+}
+
+func TestNestedGroupMidwares(t *testing.T) {
+	var calls []string
+	record := func(name string) Midware {
+		return func(ctx *context.Context) bool {
+			calls = append(calls, name)
+			return true
+		}
+	}
+
+	r := New()
+	r.Use(record("global"))
+	g := &Group{}
+	r.Group("/a",
+		g.Sub("/b",
+			g.Sub("/c", g.Get("/x", func(ctx *context.Context) {
+				calls = append(calls, "handler")
+			})).Before(record("c")).After(record("c'")),
+		).Before(record("b")).After(record("b'")),
+		g.Get("/y", func(ctx *context.Context) {
+			calls = append(calls, "handler")
+		}),
+	).Before(record("a")).After(record("a'"))
+
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/a/b/c/x", nil))
+	if strings.Join(calls, ",") != "global,a,b,c,handler,c',b',a'" {
+		t.Errorf("unexpected order %v", calls)
+	}
+
+	calls = nil
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/a/y", nil))
+	if strings.Join(calls, ",") != "global,a,handler,a'" {
+		t.Errorf("unexpected order %v", calls)
+	}
+}
+
+func TestMethodOverride(t *testing.T) {
+	r := New()
+	r.Delete("/users/:id", func(ctx *context.Context) {
+		ctx.WriteString("deleted " + ctx.Param("id"))
+	})
+	r.Post("/users/:id", func(ctx *context.Context) {
+		ctx.WriteString("posted")
+	})
+
+	newForm := func() *http.Request {
+		req := httptest.NewRequest("POST", "/users/1", strings.NewReader("_method=delete"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, newForm())
+	if rw.Body.String() != "posted" {
+		t.Errorf("expect override disabled by default, got %s", rw.Body.String())
+	}
+
+	r.MethodOverride(true)
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, newForm())
+	if rw.Body.String() != "deleted 1" {
+		t.Errorf("expect overridden by form, got %s", rw.Body.String())
+	}
+
+	req := httptest.NewRequest("POST", "/users/2", nil)
+	req.Header.Set("X-HTTP-Method-Override", "DELETE")
+	rw = httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Body.String() != "deleted 2" {
+		t.Errorf("expect overridden by header, got %s", rw.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/users/3", nil)
+	req.Header.Set("X-HTTP-Method-Override", "GET")
+	rw = httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Body.String() != "posted" {
+		t.Errorf("expect GET override ignored, got %s", rw.Body.String())
+	}
+}
+
+func TestContextRoute(t *testing.T) {
+	var route string
+
+	r := New()
+	r.Use(func(ctx *context.Context) bool {
+		ctx.Defer(func() { route = ctx.Route() })
+		return true
+	})
+	handler := func(ctx *context.Context) {}
+	r.Get("/users/:id(int)", handler)
+	r.Get("/about", handler)
+	g := &Group{}
+	r.Group("/api", g.Sub("/v1", g.Get("/posts/:slug", handler)))
+
+	for path, expect := range map[string]string{
+		"/users/42":         "/users/:id(int)",
+		"/about":            "/about",
+		"/api/v1/posts/abc": "/api/v1/posts/:slug",
+		"/users/abc":        "",
+	} {
+		route = "unset"
+		r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if route != expect {
+			t.Errorf("%s: expect route %q, got %q", path, expect, route)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	r := New()
+	r.Get("/debug", func(ctx *context.Context) {
+		ctx.WriteString("route")
+	})
+	r.Mount("/debug", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("debug " + req.URL.Path))
+	}))
+	r.Mount("/debug/admin/", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("admin " + req.URL.Path + "?" + req.URL.RawQuery))
+	}))
+
+	for path, expect := range map[string]string{
+		"/debug":                  "debug /",
+		"/debug/pprof/heap":       "debug /pprof/heap",
+		"/debug/admin/users?id=1": "admin /users?id=1",
+		"/debugger":               "404 page not found\n",
+	} {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", path, nil))
+		if rw.Body.String() != expect {
+			t.Errorf("%s: expect %q, got %q", path, expect, rw.Body.String())
+		}
+	}
+}
+
+func TestStaticSPA(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0644)
+	os.MkdirAll(filepath.Join(dir, "assets"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("app()"), 0644)
+
+	r := New()
+	r.StaticSPA("/", dir, "index.html")
+	r.Get("/api/users", func(ctx *context.Context) {
+		ctx.WriteString("users")
+	})
+
+	for path, expect := range map[string]string{
+		"/assets/app.js":    "app()",
+		"/users/42/profile": "<html>app</html>",
+		"/assets":           "<html>app</html>",
+		"/":                 "<html>app</html>",
+		"/../secret":        "<html>app</html>",
+		"/api/users":        "users",
+	} {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", path, nil))
+		if rw.Code != http.StatusOK || rw.Body.String() != expect {
+			t.Errorf("%s: expect 200 %q, got %d %q", path, expect, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func pingDatabase() error {
+	return errors.New("connection refused")
+}
+
+func TestHealthReady(t *testing.T) {
+	ready := false
+
+	r := New()
+	r.Health("/healthz")
+	r.Ready("/readyz", func() error {
+		if !ready {
+			return errors.New("warming up")
+		}
+		return nil
+	})
+	r.Ready("/readyz/db", pingDatabase)
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/healthz", nil))
+	if rw.Code != http.StatusOK || rw.Body.String() != `{"status":"ok"}` {
+		t.Errorf("unexpected health %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/readyz", nil))
+	if rw.Code != http.StatusServiceUnavailable || !strings.Contains(rw.Body.String(), `"error":"warming up"`) {
+		t.Errorf("expect 503, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	ready = true
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/readyz", nil))
+	if rw.Code != http.StatusOK {
+		t.Errorf("expect ready, got %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/readyz/db", nil))
+	if rw.Code != http.StatusServiceUnavailable || !strings.Contains(rw.Body.String(), `"name":"github.com/raythorn/zebra/router.pingDatabase"`) {
+		t.Errorf("expect failed check named, got %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestNotFoundNotAllowedContext(t *testing.T) {
+	r := New()
+	r.Get("/users/:id", func(ctx *context.Context) {})
+	r.Delete("/users/:id", func(ctx *context.Context) {})
+	r.NotAllowed(func(ctx *context.Context) {
+		ctx.JSONStatus(http.StatusMethodNotAllowed, map[string]string{
+			"route": ctx.Route(), "method": ctx.Method(), "allow": ctx.Get("Allow"),
+		}, false)
+	})
+	r.NotFound(func(ctx *context.Context) {
+		ctx.JSONStatus(http.StatusNotFound, map[string]string{"route": ctx.Route(), "path": ctx.URL()}, false)
+	})
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("POST", "/users/42", nil))
+	if rw.Code != http.StatusMethodNotAllowed || rw.Header().Get("Allow") != "DELETE, GET, HEAD" ||
+		rw.Body.String() != `{"allow":"DELETE, GET, HEAD","method":"POST","route":"/users/:id"}` {
+		t.Errorf("unexpected 405 %d %s %s", rw.Code, rw.Header().Get("Allow"), rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/posts/42", nil))
+	if rw.Code != http.StatusNotFound || rw.Body.String() != `{"path":"/posts/42","route":""}` {
+		t.Errorf("unexpected 404 %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestGroupPrefixParams(t *testing.T) {
+	r := New()
+	g := &Group{}
+	handler := func(ctx *context.Context) {
+		ctx.WriteString(ctx.Param("version") + " " + ctx.Param("org") + " " + ctx.Param("id") + " " + ctx.Route())
+	}
+	r.Group("/api/:version(v[0-9]+)",
+		g.Get("/users/:id", handler),
+		g.Sub("/orgs/:org", g.Get("/members/:id", handler)),
+	)
+
+	for path, expect := range map[string]string{
+		"/api/v1/users/42":             "v1  42 /api/:version(v[0-9]+)/users/:id",
+		"/api/v2/users/7":              "v2  7 /api/:version(v[0-9]+)/users/:id",
+		"/api/v2/orgs/zebra/members/7": "v2 zebra 7 /api/:version(v[0-9]+)/orgs/:org/members/:id",
+	} {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", path, nil))
+		if rw.Body.String() != expect {
+			t.Errorf("%s: expect %q, got %q", path, expect, rw.Body.String())
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/api/latest/users/42", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect constrained prefix param not matched, got %d", rw.Code)
+	}
+}
+
+func TestConcurrentRegister(t *testing.T) {
+	r := New()
+	handler := func(ctx *context.Context) { ctx.WriteString(ctx.URL()) }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				r.Get(fmt.Sprintf("/plugin%d/item%d/:id", i, j), handler)
+				r.Group(fmt.Sprintf("/group%d", i), newGroup().Get(fmt.Sprintf("/item%d", j), handler))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/plugin0/item0/1", nil))
+			}
+		}()
+	}
+	wg.Wait()
+
+	r.Freeze()
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 20; j++ {
+			rw := httptest.NewRecorder()
+			r.Handle(rw, httptest.NewRequest("GET", fmt.Sprintf("/plugin%d/item%d/1", i, j), nil))
+			if rw.Code != 200 {
+				t.Fatalf("route /plugin%d/item%d not registered", i, j)
+			}
+
+			rw = httptest.NewRecorder()
+			r.Handle(rw, httptest.NewRequest("GET", fmt.Sprintf("/group%d/item%d", i, j), nil))
+			if rw.Code != 200 {
+				t.Fatalf("route /group%d/item%d not registered", i, j)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expect registration after Freeze panics")
+		}
+	}()
+	r.Get("/late", handler)
+}
+
+func TestFreezeSettings(t *testing.T) {
+	r := New()
+	r.Get("/users", func(ctx *context.Context) {})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			r.MaxURLLength(1024 + i)
+			r.NotFound(func(ctx *context.Context) { ctx.Abort(http.StatusNotFound) })
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+		}
+	}()
+	wg.Wait()
+
+	r.Freeze()
+	for name, set := range map[string]func(){
+		"NotFound":      func() { r.NotFound(func(ctx *context.Context) {}) },
+		"MaxConcurrent": func() { r.MaxConcurrent(1, 0) },
+	} {
+		panicked := func() (panicked bool) {
+			defer func() {
+				panicked = recover() != nil
+			}()
+			set()
+			return false
+		}()
+
+		if !panicked {
+			t.Errorf("expect %s after Freeze panics", name)
+		}
+	}
+}
+
+func TestTreeMatch(t *testing.T) {
+	r := New()
+	handler := func(ctx *context.Context) {
+		ctx.WriteString(ctx.Route() + " " + ctx.Get("id") + ctx.Get("name") + ctx.Get("filepath"))
+	}
+	r.Get("/users/me", handler)
+	r.Post("/users/:id", handler)
+	r.Get("/users/:id(int)", handler)
+	r.Get("/users/:name", handler)
+	r.Get("/users/:id/posts", handler)
+	r.Get("/files/*filepath", handler)
+	r.Get("/tags/(?P<name>[a-z]+/[a-z]+)", handler)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{"GET", "/users/me", "/users/me "},
+		{"POST", "/users/me", "/users/:id me"},
+		{"GET", "/users/42", "/users/:id(int) 42"},
+		{"GET", "/users/bob", "/users/:name bob"},
+		{"GET", "/users/bob/", "/users/:name bob"},
+		{"GET", "/users/42/posts", "/users/:id/posts 42"},
+		{"GET", "/files/", "/files/*filepath "},
+		{"GET", "/files/a/b.txt", "/files/*filepath a/b.txt"},
+		{"GET", "/tags/go/web", "/tags/(?P<name>[a-z]+/[a-z]+) go/web"},
+	}
+
+	for _, c := range cases {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest(c.method, c.path, nil))
+		if rw.Code != 200 || rw.Body.String() != c.body {
+			t.Errorf("%s %s: expect 200 %q, got %d %q", c.method, c.path, c.body, rw.Code, rw.Body.String())
+		}
+	}
+
+	for _, path := range []string{"/users/bob/x", "/files", "/users/42/posts/x"} {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", path, nil))
+		if rw.Code != 404 {
+			t.Errorf("GET %s: expect 404, got %d", path, rw.Code)
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	r := New()
+	handler := func(ctx *context.Context) { ctx.WriteString(ctx.Get("id") + ctx.Get("name")) }
+	r.Get("/api/users/:id", handler)
+	r.Get("/api/tags/(?P<name>[a-z]+)", handler)
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/API/Users/Bob", nil))
+	if rw.Code != 404 {
+		t.Fatalf("expect 404 if case sensitive, got %d", rw.Code)
+	}
+
+	r.CaseInsensitive(true)
+	r.TrailingSlash(SlashRedirect)
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/API/Users/Bob", 200, "Bob"},
+		{"/Api/TAGS/go", 200, "go"},
+		{"/API/users", 404, ""},
+	}
+
+	for _, c := range cases {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest("GET", c.path, nil))
+		if rw.Code != c.code || (c.code == 200 && rw.Body.String() != c.body) {
+			t.Errorf("%s: expect %d %q, got %d %q", c.path, c.code, c.body, rw.Code, rw.Body.String())
+		}
+	}
+
+	r.Get("/Docs", handler)
+	rw = httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/docs/", nil))
+	if rw.Code != http.StatusMovedPermanently || rw.Header().Get("Location") != "/docs" {
+		t.Errorf("expect redirect to /docs, got %d %s", rw.Code, rw.Header().Get("Location"))
+	}
+}
+
+type fileArchive struct{}
+
+func (fileArchive) Path(storage *oss.Oss, ctx *context.Context) string {
+	return filepath.Join(storage.Root(), ctx.Param("name"))
+}
+
+func TestGroupOss(t *testing.T) {
+	root, err := ioutil.TempDir("", "oss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	ioutil.WriteFile(filepath.Join(root, "logo.txt"), []byte("logo"), 0644)
+
+	r := New()
+	r.Group("/private", newGroup().Oss("/assets/:name", root, fileArchive{})).Before(func(ctx *context.Context) bool {
+		if ctx.Get("Authorization") == "" {
+			ctx.Abort(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	})
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/private/assets/logo.txt", nil))
+	if rw.Code != http.StatusUnauthorized {
+		t.Errorf("expect group midwares protect oss, got %d", rw.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/private/assets/logo.txt", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rw = httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Code != 200 || rw.Body.String() != "logo" {
+		t.Errorf("expect object downloaded, got %d %q", rw.Code, rw.Body.String())
+	}
+}
+
+func TestOssWithoutArchive(t *testing.T) {
+	root, err := ioutil.TempDir("", "oss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	r := New()
+	r.Oss("/objects/:name", root, nil)
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/objects/logo.png", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404 without archive, got %d", rw.Code)
+	}
+
+	// Path of object can not be chosen by client with query or headers
+	victim := filepath.Join(root, "victim.txt")
+	ioutil.WriteFile(victim, []byte("secret"), 0644)
+
+	for _, method := range []string{"GET", "DELETE"} {
+		req := httptest.NewRequest(method, "/objects/logo.png?"+oss.OssPathKey+"="+victim, nil)
+		req.Header.Set(oss.OssPathKey, victim)
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+		if rw.Code != http.StatusNotFound || strings.Contains(rw.Body.String(), "secret") {
+			t.Errorf("%s: expect forged path ignored, got %d %q", method, rw.Code, rw.Body.String())
+		}
+	}
+
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("expect file not deleted by forged path, got %v", err)
+	}
+}
+
+func TestOssOutOfRoot(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "a", "b")
+	resid := "0123456789abcdef0123456789abcdef.txt"
+	victim := filepath.Join(base, "01", "234", resid)
+	os.MkdirAll(filepath.Dir(victim), 0755)
+	ioutil.WriteFile(victim, []byte("secret"), 0644)
+
+	r := New()
+	r.Oss("/objects/:resid", root, &oss.MD5Archive{})
+
+	rw, _ := r.Test("DELETE", "/objects/"+resid+"?category=../..", nil)
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for category of query, got %d", rw.Code)
+	}
+
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("expect file out of root not deleted, got %v", err)
+	}
+}
+
+func TestOssNotAllowed(t *testing.T) {
+	r := New()
+	r.Oss("/objects/:name", t.TempDir(), nil)
+	r.Group("/private", newGroup().Oss("/assets/:name", t.TempDir(), fileArchive{}))
+
+	for _, target := range []string{"/objects/logo.png", "/private/assets/logo.png"} {
+		rw, _ := r.Test("PATCH", target, nil)
+		if rw.Code != http.StatusMethodNotAllowed || rw.Header().Get("Allow") != "DELETE, GET, HEAD, POST, PUT" {
+			t.Errorf("%s: expect 405 with methods of oss, got %d %q", target, rw.Code, rw.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestMatchMethods(t *testing.T) {
+	r := New()
+	r.Match([]string{"POST", "put"}, "/users/:id", func(ctx *context.Context) {
+		ctx.WriteString(ctx.Method() + " " + ctx.Param("id"))
+	})
+
+	for _, method := range []string{"POST", "PUT"} {
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest(method, "/users/1", nil))
+		if rw.Code != 200 || rw.Body.String() != method+" 1" {
+			t.Errorf("%s: unexpected response %d %q", method, rw.Code, rw.Body.String())
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, httptest.NewRequest("GET", "/users/1", nil))
+	if rw.Code != http.StatusMethodNotAllowed || rw.Header().Get("Allow") != "POST, PUT" {
+		t.Errorf("expect 405 with Allow POST, PUT, got %d %q", rw.Code, rw.Header().Get("Allow"))
+	}
+}
+
+func TestRouteBuilder(t *testing.T) {
+	r := New()
+	order := []string{}
+	r.Route("get", "/users/:id", func(ctx *context.Context) {
+		order = append(order, "handler")
+	}, func(ctx *context.Context) bool {
+		order = append(order, "route")
+		return true
+	}).Name("user.show").Use(func(ctx *context.Context) bool {
+		order = append(order, "use")
+		return true
+	})
+
+	route := r.Route("POST", "/users/:id", func(ctx *context.Context) {})
+	if route.name != "user.show" || route.Pattern() != "/users/:id" {
+		t.Errorf("expect same route returned, got %q %q", route.name, route.Pattern())
+	}
+
+	r.Handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	if strings.Join(order, ",") != "use,route,handler" {
+		t.Errorf("unexpected order %v", order)
+	}
+}
+
+func TestHeaderLimit(t *testing.T) {
+	r := New()
+	r.Get("/", func(ctx *context.Context) { ctx.WriteString("ok") })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 20; i++ {
+		req.Header.Add(fmt.Sprintf("X-Header-%d", i), "value")
+	}
+
+	rw := httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Code != 200 {
+		t.Fatalf("expect default limits allow normal requests, got %d", rw.Code)
+	}
+
+	r.HeaderLimit(10, 0)
+	rw = httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expect 431 for too many headers, got %d", rw.Code)
+	}
+
+	r.HeaderLimit(0, 64)
+	rw = httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expect 431 for too large headers, got %d", rw.Code)
+	}
+}
+
+func TestMaxURLLength(t *testing.T) {
+	r := New()
+	r.Get("/search", func(ctx *context.Context) { ctx.WriteString("ok") })
+
+	target := "/search?q=" + strings.Repeat("a", 100)
+	if rw, _ := r.Test("GET", target, nil); rw.Code != http.StatusOK {
+		t.Fatalf("expect default limit allows normal urls, got %d", rw.Code)
+	}
+
+	if rw, _ := r.Test("GET", "/search?q="+strings.Repeat("a", DefaultMaxURLLength), nil); rw.Code != http.StatusRequestURITooLong {
+		t.Errorf("expect 414 for url longer than default, got %d", rw.Code)
+	}
+
+	r.MaxURLLength(64)
+	if rw, _ := r.Test("GET", target, nil); rw.Code != http.StatusRequestURITooLong {
+		t.Errorf("expect 414 for url longer than 64, got %d", rw.Code)
+	}
+
+	r.MaxURLLength(0)
+	if rw, _ := r.Test("GET", "/search?q="+strings.Repeat("a", DefaultMaxURLLength), nil); rw.Code != http.StatusOK {
+		t.Errorf("expect no limit, got %d", rw.Code)
+	}
+}
+
+func TestMaxPathDepth(t *testing.T) {
+	r := New()
+	r.Get("/files/*filepath", func(ctx *context.Context) { ctx.WriteString(ctx.Param("filepath")) })
+
+	if rw, _ := r.Test("GET", "/files/a/b/c", nil); rw.Code != http.StatusOK || rw.Body.String() != "a/b/c" {
+		t.Fatalf("expect default depth allows normal paths, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	deep := "/files" + strings.Repeat("/a", DefaultMaxPathDepth)
+	if rw, _ := r.Test("GET", deep, nil); rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for path deeper than default, got %d", rw.Code)
+	}
+
+	r.MaxPathDepth(3)
+	if rw, _ := r.Test("GET", "/files/a/b/c", nil); rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for path deeper than 3, got %d", rw.Code)
+	}
+
+	r.MaxPathDepth(0)
+	if rw, _ := r.Test("GET", deep, nil); rw.Code != http.StatusOK {
+		t.Errorf("expect no limit, got %d", rw.Code)
+	}
+}
+
+// syncBuffer is a buffer written by logger goroutine and read by tests
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+func TestDuplicateRoute(t *testing.T) {
+	out := &syncBuffer{}
+	log.SetOutput(out)
+	defer log.SetOutput(os.Stdout)
+
+	first := func(ctx *context.Context) { ctx.WriteString("first") }
+	second := func(ctx *context.Context) { ctx.WriteString("second") }
+
+	r := New()
+	r.Get("/users", first)
+	r.Post("/users", first)
+	r.Get("/users", second)
+
+	for i := 0; i < 100 && !strings.Contains(out.String(), "GET /users is registered more than once"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(out.String(), "GET /users is registered more than once") {
+		t.Errorf("expect duplicated route warned, got %q", out.String())
+	}
+	if strings.Contains(out.String(), "POST /users") {
+		t.Errorf("expect other methods of same pattern not warned, got %q", out.String())
+	}
+	if rw, _ := r.Test("GET", "/users", nil); rw.Body.String() != "second" {
+		t.Errorf("expect last handler used, got %q", rw.Body.String())
+	}
+
+	r = New()
+	r.StrictRoutes(true)
+	r.Get("/users", first)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expect duplicated route panics in strict mode")
+		}
+	}()
+	r.Match([]string{"POST", "GET"}, "/users", second)
+}
+
+func TestDuplicateGroupRoute(t *testing.T) {
+	out := &syncBuffer{}
+	log.SetOutput(out)
+	defer log.SetOutput(os.Stdout)
+
+	first := func(ctx *context.Context) { ctx.WriteString("first") }
+	second := func(ctx *context.Context) { ctx.WriteString("second") }
+
+	r := New()
+	g := &Group{}
+	r.Group("/api", g.Get("/users", first), g.Sub("/v1", g.Get("/items", first), g.Get("/items", second)))
+	r.Group("/api", g.Get("/users", second), g.Post("/users", first))
+
+	for _, warning := range []string{"GET /api/v1/items is registered", "GET /api/users is registered"} {
+		for i := 0; i < 100 && !strings.Contains(out.String(), warning); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if !strings.Contains(out.String(), warning) {
+			t.Errorf("expect %q warned, got %q", warning, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "POST /api/users") {
+		t.Errorf("expect other methods of same pattern not warned, got %q", out.String())
+	}
+
+	cases := []struct {
+		panics bool
+		args   []interface{}
+	}{
+		{true, []interface{}{g.Get("/users", first), g.Get("/users", second)}},
+		{true, []interface{}{g.Sub("/v1", g.Get("/items", first), g.Get("/items", second))}},
+		{false, []interface{}{g.Get("/users", first), g.Post("/users", second)}},
+	}
+
+	for i, c := range cases {
+		r := New()
+		r.StrictRoutes(true)
+
+		panicked := func() (panicked bool) {
+			defer func() {
+				panicked = recover() != nil
+			}()
+			r.Group("/api", c.args...)
+			return false
+		}()
+
+		if panicked != c.panics {
+			t.Errorf("case %d: expect panic %v in strict mode, got %v", i, c.panics, panicked)
+		}
+	}
+
+	r = New()
+	r.StrictRoutes(true)
+	r.Group("/api", g.Get("/users", first))
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expect route duplicated by another group panics in strict mode")
+		}
+	}()
+	r.Group("/api", g.Get("/users", second))
+}
+
+func TestSubGroupsSameRoute(t *testing.T) {
+	calls := []string{}
+	handler := func(ctx *context.Context) { ctx.WriteString(ctx.Method()) }
+
+	r := New()
+	g := &Group{}
+	r.Group("/api",
+		g.Sub("", g.Get("/x", handler)).Before(func(ctx *context.Context) bool {
+			calls = append(calls, "reader")
+			return true
+		}),
+		g.Sub("", g.Post("/x", handler)).Before(func(ctx *context.Context) bool {
+			calls = append(calls, "writer")
+			return true
+		}))
+
+	for method, midware := range map[string]string{"GET": "reader", "POST": "writer"} {
+		calls = nil
+		rw := httptest.NewRecorder()
+		r.Handle(rw, httptest.NewRequest(method, "/api/x", nil))
+		if rw.Code != http.StatusOK || rw.Body.String() != method || strings.Join(calls, ",") != midware {
+			t.Errorf("expect %s served with midware of its group, got %d %s %v", method, rw.Code, rw.Body.String(), calls)
+		}
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	r := New().(*router)
+
+	server := r.server(":8080")
+	if server.ReadTimeout != DefaultRunOptions.ReadTimeout || server.ReadHeaderTimeout != DefaultRunOptions.ReadHeaderTimeout ||
+		server.WriteTimeout != 0 || server.IdleTimeout != DefaultRunOptions.IdleTimeout {
+		t.Errorf("expect default timeouts, got %+v", server)
+	}
+
+	r.RunOptions(RunOptions{WriteTimeout: time.Minute, IdleTimeout: -1})
+	server = r.server(":8080")
+	if server.WriteTimeout != time.Minute || server.IdleTimeout != 0 || server.ReadTimeout != DefaultRunOptions.ReadTimeout {
+		t.Errorf("expect write timeout set and idle timeout disabled, got %+v", server)
+	}
+}
+
+func TestDefaultErrorNegotiation(t *testing.T) {
+	r := New()
+	r.Get("/users", func(ctx *context.Context) {})
+
+	cases := []struct {
+		method, path, accept, contentType, body string
+	}{
+		{"GET", "/missing", "application/json", "application/json; charset=utf-8", `{"error":{"code":404,"message":"Not Found"}}`},
+		{"GET", "/missing", "", "text/plain; charset=utf-8", "404 page not found\n"},
+		{"POST", "/users", "application/json, text/plain;q=0.5", "application/json; charset=utf-8", `{"error":{"code":405,"message":"Method Not Allowed"}}`},
+		{"POST", "/users", "text/html", "text/html; charset=utf-8", "<html><head><title>405 Method Not Allowed</title></head><body><h1>405 Method Not Allowed</h1></body></html>"},
+		{"POST", "/users", "*/*", "text/plain; charset=utf-8", "Method Not Allowed\n"},
+		{"GET", "/missing", "application/json, text/plain, */*", "application/json; charset=utf-8", `{"error":{"code":404,"message":"Not Found"}}`},
+		{"GET", "/missing", "text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<html><head><title>404 Not Found</title></head><body><h1>404 Not Found</h1></body></html>"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+		if rw.Header().Get("Content-Type") != c.contentType || rw.Body.String() != c.body {
+			t.Errorf("%s %s %q: expect %s %q, got %s %q", c.method, c.path, c.accept, c.contentType, c.body,
+				rw.Header().Get("Content-Type"), rw.Body.String())
+		}
+	}
+}
+
+func TestRouterTest(t *testing.T) {
+	r := New()
+	r.Use(func(ctx *context.Context) bool {
+		ctx.Header("X-Global", "zebra")
+		return true
+	})
+	g := &Group{}
+	r.Group("/api", g.Post("/users/:id", func(ctx *context.Context) {
+		ctx.WriteString(ctx.Get("id") + " " + ctx.Get("active") + " " + string(ctx.Body()))
+	}))
+
+	rw, err := r.Test("POST", "/api/users/1?active=true", strings.NewReader("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rw.Code != http.StatusOK || rw.Body.String() != "1 true bob" || rw.Header().Get("X-Global") != "zebra" {
+		t.Errorf("expect 200 \"1 true bob\" with global midware, got %d %q %v", rw.Code, rw.Body.String(), rw.Header())
+	}
+
+	if rw, _ := r.Test("GET", "http://example.org/missing", nil); rw.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", rw.Code)
+	}
+
+	if _, err := r.Test("GET", "::bad", nil); err == nil {
+		t.Error("expect error for invalid target")
+	}
+}
+
+func TestUseFunc(t *testing.T) {
+	var calls []string
+
+	r := New()
+	r.Use(func(ctx *context.Context) bool {
+		calls = append(calls, "use")
+		return true
+	})
+	for _, name := range []string{"outer", "inner"} {
+		name := name
+		r.UseFunc(func(next Handler) Handler {
+			return func(ctx *context.Context) {
+				calls = append(calls, name)
+				next(ctx)
+				calls = append(calls, fmt.Sprintf("%s %d", name, ctx.Status()))
+			}
+		})
+	}
+	r.Get("/users", func(ctx *context.Context) {
+		calls = append(calls, "handler")
+		ctx.WriteHeader(http.StatusAccepted)
+	}, func(ctx *context.Context) bool {
+		calls = append(calls, "route")
+		return true
+	})
+
+	r.Test("GET", "/users", nil)
+	if strings.Join(calls, ",") != "use,outer,inner,route,handler,inner 202,outer 202" {
+		t.Errorf("unexpected order: %v", calls)
+	}
+
+	calls = nil
+	r.Test("GET", "/missing", nil)
+	if strings.Join(calls, ",") != "use,outer,inner,inner 404,outer 404" {
+		t.Errorf("wrappers should observe not found: %v", calls)
+	}
+}
+
+func TestUseForMethods(t *testing.T) {
+	var audits []string
+
+	r := New()
+	r.UseForMethods([]string{"post", "DELETE"}, func(ctx *context.Context) bool {
+		audits = append(audits, ctx.Method()+" "+ctx.URL())
+		return ctx.Get("X-User") != ""
+	})
+	r.Get("/users/:id", func(ctx *context.Context) {})
+	r.Delete("/users/:id", func(ctx *context.Context) {})
+	r.Post("/users", func(ctx *context.Context) {
+		ctx.WriteString("created")
+	})
+
+	r.Test("GET", "/users/1", nil)
+	r.Test("DELETE", "/users/1", nil)
+	r.Test("POST", "/missing", nil)
+	if strings.Join(audits, ",") != "DELETE /users/1" {
+		t.Errorf("only matched requests of methods should be audited: %v", audits)
+	}
+
+	req := httptest.NewRequest("POST", "/users", nil)
+	req.Header.Set("X-User", "bob")
+	rw := httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Body.String() != "created" || len(audits) != 2 {
+		t.Errorf("expect audited request to pass, got %q %v", rw.Body.String(), audits)
+	}
+}
+
+func TestFaviconRobots(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "favicon.ico")
+	ioutil.WriteFile(file, []byte("icon"), 0644)
+
+	r := New()
+	r.Favicon(file)
+	r.Robots("User-agent: *\nDisallow: /admin\n")
+
+	rw, _ := r.Test("GET", "/favicon.ico", nil)
+	if rw.Code != http.StatusOK || rw.Body.String() != "icon" || rw.Header().Get("Cache-Control") != "public, max-age=2592000" {
+		t.Errorf("unexpected favicon %d %q %v", rw.Code, rw.Body.String(), rw.Header())
+	}
+
+	rw, _ = r.Test("GET", "/robots.txt", nil)
+	if rw.Code != http.StatusOK || rw.Body.String() != "User-agent: *\nDisallow: /admin\n" ||
+		!strings.HasPrefix(rw.Header().Get("Content-Type"), "text/plain") || rw.Header().Get("Cache-Control") != "public, max-age=86400" {
+		t.Errorf("unexpected robots.txt %d %q %v", rw.Code, rw.Body.String(), rw.Header())
+	}
+}
+
+func TestRecoveryWritten(t *testing.T) {
+	r := New()
+	g := &Group{}
+	r.Group("/api", g.Get("/silent", func(ctx *context.Context) {
+	}), g.Get("/users", func(ctx *context.Context) {
+		ctx.WriteString("users")
+	})).After(func(ctx *context.Context) bool {
+		if !ctx.Written() {
+			ctx.Text(http.StatusNoContent, "")
+		}
+		return true
+	})
+	r.Get("/panic", func(ctx *context.Context) {
+		panic("boom")
+	})
+	r.Get("/stream", func(ctx *context.Context) {
+		ctx.WriteString("partial")
+		panic("boom")
+	})
+
+	if rw, _ := r.Test("GET", "/api/silent", nil); rw.Code != http.StatusNoContent {
+		t.Errorf("expect fallback of after midware, got %d", rw.Code)
+	}
+
+	if rw, _ := r.Test("GET", "/api/users", nil); rw.Code != http.StatusOK || rw.Body.String() != "users" {
+		t.Errorf("expect response of handler kept, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	if rw, _ := r.Test("GET", "/panic", nil); rw.Code != http.StatusInternalServerError {
+		t.Errorf("expect 500 for panic, got %d", rw.Code)
+	}
+
+	if rw, _ := r.Test("GET", "/stream", nil); rw.Code != http.StatusOK || rw.Body.String() != "partial" {
+		t.Errorf("expect written response untouched by recovery, got %d %q", rw.Code, rw.Body.String())
+	}
+}
+
+func TestRawBody(t *testing.T) {
+	r := New()
+	r.Route("POST", "/webhook", func(ctx *context.Context) {
+		body, _ := ioutil.ReadAll(ctx.Request().Body)
+		ctx.WriteString(fmt.Sprintf("%s|%s|%s|%d", body, ctx.Get("source"), ctx.Get("a"), len(ctx.Body())))
+	}).RawBody()
+	r.Post("/form", func(ctx *context.Context) {
+		ctx.WriteString(ctx.Get("a") + ctx.Get("source"))
+	})
+
+	post := func(target string) string {
+		req := httptest.NewRequest("POST", target, strings.NewReader("a=1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+		return rw.Body.String()
+	}
+
+	if body := post("/webhook?source=github"); body != "a=1|github||0" {
+		t.Errorf("expect raw body untouched with query in form, got %q", body)
+	}
+
+	if body := post("/form?source=github"); body != "1github" {
+		t.Errorf("expect form parsed for other routes, got %q", body)
+	}
+}
+
+func TestSafeUse(t *testing.T) {
+	r := New()
+	r.SafeUse(func(ctx *context.Context) bool {
+		panic("analytics down")
+	})
+	r.SafeUse(func(ctx *context.Context) bool {
+		return ctx.Get("X-Block") == ""
+	})
+	r.Get("/", func(ctx *context.Context) { ctx.WriteString("ok") })
+
+	if rw, _ := r.Test("GET", "/", nil); rw.Code != http.StatusOK || rw.Body.String() != "ok" {
+		t.Errorf("expect panic of safe midware skipped, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Block", "1")
+	rw := httptest.NewRecorder()
+	r.Handle(rw, req)
+	if rw.Body.String() == "ok" {
+		t.Error("expect safe midware still able to intercept")
+	}
+
+	r.Use(func(ctx *context.Context) bool {
+		panic("auth down")
+	})
+	if rw, _ := r.Test("GET", "/", nil); rw.Code != http.StatusInternalServerError {
+		t.Errorf("expect panic of Use fails with 500, got %d", rw.Code)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	handler := func(ctx *context.Context) {}
+
+	r := New()
+	r.Route("GET", "/users/:id", handler).Name("user.show")
+	r.Delete("/users/:id", handler)
+	g := &Group{}
+	r.Group("/admin", g.Get("/stats", handler), g.Sub("/v2", g.Post("/jobs", handler)))
+	r.Host("api.example.com").Get("/", handler)
+	r.Oss("/objects/:name", t.TempDir(), nil)
+
+	var lines []string
+	for _, info := range r.Routes() {
+		lines = append(lines, fmt.Sprintf("%s %s %s %s %s %v", info.Host, info.Method, info.Pattern, info.Group, info.Name, info.Oss))
+	}
+
+	expect := []string{
+		" GET /admin/stats /admin  false",
+		" POST /admin/v2/jobs /admin/v2  false",
+		" DELETE /objects/:name   true",
+		" GET /objects/:name   true",
+		" HEAD /objects/:name   true",
+		" POST /objects/:name   true",
+		" PUT /objects/:name   true",
+		" DELETE /users/:id  user.show false",
+		" GET /users/:id  user.show false",
+		"api.example.com GET /   false",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("unexpected routes:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestDeferPanic(t *testing.T) {
+	var calls []string
+
+	r := New()
+	r.Get("/tx", func(ctx *context.Context) {
+		ctx.Defer(func() { calls = append(calls, "close") })
+		ctx.Defer(func() { panic("rollback failed") })
+		ctx.Defer(func() { calls = append(calls, "rollback") })
+		panic("boom")
+	})
+
+	rw, _ := r.Test("GET", "/tx", nil)
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("expect 500, got %d", rw.Code)
+	}
+	if strings.Join(calls, ",") != "rollback,close" {
+		t.Errorf("expect all deferred funcs called in LIFO order, got %v", calls)
+	}
+}
+
+func TestRewritePath(t *testing.T) {
+	r := New()
+	r.PreRoute(func(ctx *context.Context) {
+		if strings.HasPrefix(ctx.URL(), "/v1/") {
+			ctx.SetPath("/v2/" + strings.TrimPrefix(ctx.URL(), "/v1/"))
+		}
+	})
+	r.Use(func(ctx *context.Context) bool {
+		if ctx.URL() == "/legacy" {
+			ctx.SetPath("/v2/users/0")
+		}
+		return true
+	})
+	r.Get("/v2/users/:id", func(ctx *context.Context) {
+		ctx.WriteString(ctx.Route() + " " + ctx.Param("id") + " " + ctx.URI())
+	})
+	r.Route("POST", "/v2/hooks", func(ctx *context.Context) {
+		body, _ := ioutil.ReadAll(ctx.Request().Body)
+		ctx.WriteString(fmt.Sprintf("%s %d", body, len(ctx.Body())))
+	}).RawBody()
+
+	if rw, _ := r.Test("GET", "/v1/users/7", nil); rw.Body.String() != "/v2/users/:id 7 /v1/users/7" {
+		t.Errorf("expect path rewritten by PreRoute, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	if rw, _ := r.Test("GET", "/legacy", nil); rw.Body.String() != "/v2/users/:id 0 /legacy" {
+		t.Errorf("expect path rewritten by midware, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	if rw, _ := r.Test("POST", "/v1/hooks", strings.NewReader("raw")); rw.Body.String() != "raw 0" {
+		t.Errorf("expect route settings resolved with rewritten path, got %q", rw.Body.String())
+	}
+}
+
+func TestPanicNegotiation(t *testing.T) {
+	r := New()
+	r.Use(func(ctx *context.Context) bool {
+		ctx.Set(context.RequestIDKey, "req-1")
+		return true
+	})
+	r.Get("/panic", func(ctx *context.Context) {
+		panic("boom")
+	})
+
+	request := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/panic", nil)
+		req.Header.Set("Accept", accept)
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+		return rw
+	}
+
+	rw := request("application/json")
+	if rw.Code != 500 || rw.Body.String() != `{"error":{"code":500,"message":"internal error","request_id":"req-1"}}` {
+		t.Errorf("unexpected JSON response %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw = request("text/html")
+	if rw.Code != 500 || !strings.Contains(rw.Body.String(), "<p>Request ID: req-1</p>") || strings.Contains(rw.Body.String(), "<pre>") {
+		t.Errorf("unexpected HTML response %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw = request("*/*")
+	if rw.Code != 500 || rw.Body.String() != "Internal Server Error\nRequest ID: req-1\n" {
+		t.Errorf("unexpected plain response %d %q", rw.Code, rw.Body.String())
+	}
+
+	r.Develop(true)
+	rw = request("application/json")
+	var body struct {
+		Error struct {
+			Stack string `json:"stack"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &body); err != nil || !strings.Contains(body.Error.Stack, "goroutine") {
+		t.Errorf("expect stack in develop mode, got %s", rw.Body.String())
+	}
+}
+
+func BenchmarkHandle(b *testing.B) {
+	r := New()
+	r.Get("/user", func(ctx *context.Context) {})
+
+	req := httptest.NewRequest("GET", "/user", nil)
+	req.Header.Set("Accept", "application/json")
+	rw := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Handle(rw, req)
+	}
+}
+
+func BenchmarkNewContext(b *testing.B) {
+	req := httptest.NewRequest("GET", "/user", nil)
+	req.Header.Set("Accept", "application/json")
+	var rw http.ResponseWriter = httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := context.New()
+		ctx.Reset(rw, req)
+	}
+}
+
+// benchRoutes registers 400 routes with parameters, like a large RESTful API
+func benchRoutes() *router {
+	r := New().(*router)
+	handler := func(ctx *context.Context) {}
+	for i := 0; i < 100; i++ {
+		r.Get(fmt.Sprintf("/api/v1/resource%d", i), handler)
+		r.Get(fmt.Sprintf("/api/v1/resource%d/:id", i), handler)
+		r.Put(fmt.Sprintf("/api/v1/resource%d/:id(int)", i), handler)
+		r.Get(fmt.Sprintf("/api/v1/resource%d/:id/items/:item", i), handler)
+	}
+
+	return r
+}
+
+func BenchmarkMatchTree(b *testing.B) {
+	r := benchRoutes()
+	ctx := context.New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/resource99/42/items/7", nil))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if r.match(ctx) == nil {
+			b.Fatal("route not matched")
+		}
+	}
+}
+
+// BenchmarkMatchLinear scans regexp of all routes, which is how routes were matched before the tree
+func BenchmarkMatchLinear(b *testing.B) {
+	r := benchRoutes()
+	ctx := context.New()
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/resource99/42/items/7", nil))
+
+	routes := make([]*Route, 0, len(r.route.routes))
+	for _, route := range r.route.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].pattern < routes[j].pattern })
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var matched *Route
+		for _, route := range routes {
+			if route.match(ctx) {
+				matched = route
+				break
+			}
+		}
+		if matched == nil {
+			b.Fatal("route not matched")
+		}
+	}
+}
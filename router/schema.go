@@ -0,0 +1,165 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"net/http"
+	"strings"
+)
+
+// schemaViolation reports violations of response schema, it logs warnings by default
+var schemaViolation = func(ctx *context.Context, violations []string) {
+	for _, violation := range violations {
+		log.Warning("Schema: %s %s: %s", ctx.Method(), ctx.URL(), violation)
+	}
+}
+
+// Schema declares the expected JSON schema of responses of this route, it's only validated in
+// develop mode, and violations will be logged. Supported keywords are type, properties,
+// required, items and enum.
+func (r *Route) Schema(schema string) *Route {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		log.Panic("Schema: invalid schema of %s: %s", r.pattern, err.Error())
+	}
+
+	r.schema = parsed
+	return r
+}
+
+// bufferWriter passes data through to ResponseWriter and keeps a copy of the body
+type bufferWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bufferWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// validateResponse validates body buffered in rw against schema of route
+func validateResponse(ctx *context.Context, schema map[string]interface{}, rw *bufferWriter) {
+	if !strings.Contains(rw.Header().Get("Content-Type"), "json") {
+		return
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(rw.body.Bytes(), &value); err != nil {
+		schemaViolation(ctx, []string{"response is not valid JSON: " + err.Error()})
+		return
+	}
+
+	if violations := validateSchema(schema, value, "$"); len(violations) > 0 {
+		schemaViolation(ctx, violations)
+	}
+}
+
+func validateSchema(schema map[string]interface{}, value interface{}, path string) []string {
+
+	var violations []string
+
+	if t, ok := schema["type"]; ok {
+		matched := false
+		switch t := t.(type) {
+		case string:
+			matched = matchType(t, value)
+		case []interface{}:
+			for _, item := range t {
+				if name, ok := item.(string); ok && matchType(name, value) {
+					matched = true
+					break
+				}
+			}
+		}
+
+		if !matched {
+			return append(violations, fmt.Sprintf("%s: expect type %v, got %s", path, t, typeOf(value)))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, item := range enum {
+			if fmt.Sprint(item) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			violations = append(violations, fmt.Sprintf("%s: value %v not in enum %v", path, value, enum))
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if key, ok := name.(string); ok {
+					if _, ok := value[key]; !ok {
+						violations = append(violations, fmt.Sprintf("%s: missing required field %s", path, key))
+					}
+				}
+			}
+		}
+
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			for key, sub := range properties {
+				field, ok := value[key]
+				subschema, valid := sub.(map[string]interface{})
+				if ok && valid {
+					violations = append(violations, validateSchema(subschema, field, path+"."+key)...)
+				}
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				violations = append(violations, validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return violations
+}
+
+func matchType(name string, value interface{}) bool {
+	switch name {
+	case "integer":
+		if n, ok := value.(float64); ok {
+			return n == float64(int64(n))
+		}
+		return false
+	default:
+		return name == typeOf(value)
+	}
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"github.com/raythorn/zebra/context"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Cache durations of Favicon and Robots, favicons rarely change, robots.txt is refetched daily
+const (
+	FaviconMaxAge = 30 * 24 * time.Hour
+	RobotsMaxAge  = 24 * time.Hour
+)
+
+func (r *router) StaticSPA(prefix, dir, index string) {
+	pattern := cleanPath(prefix)
+	if pattern != "/" {
+		pattern += "/"
+	}
+
+	r.Get(pattern+"*filepath", spaHandler(dir, index))
+}
+
+func (r *router) Favicon(file string) {
+	r.Get("/favicon.ico", func(ctx *context.Context) {
+		ctx.Cache(FaviconMaxAge)
+		serveFile(ctx, file)
+	})
+}
+
+func (r *router) Robots(content string) {
+	r.Get("/robots.txt", func(ctx *context.Context) {
+		ctx.Cache(RobotsMaxAge)
+		ctx.Text(http.StatusOK, content)
+	})
+}
+
+// spaHandler serves file of path parameter filepath in dir, index is served if file not exist,
+// so that client side routing works
+func spaHandler(dir, index string) Handler {
+	return func(ctx *context.Context) {
+		name := path.Clean("/" + ctx.Param("filepath"))
+
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			file = filepath.Join(dir, index)
+		}
+
+		serveFile(ctx, file)
+	}
+}
+
+// serveFile serves file with content type registered by context.RegisterMIME, or detected by
+// http.ServeContent if not registered
+func serveFile(ctx *context.Context, file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		ctx.NotFound()
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		ctx.NotFound()
+		return
+	}
+
+	if contentType := context.TypeByExtension(filepath.Ext(file)); contentType != "" {
+		ctx.Header("Content-Type", contentType)
+	}
+
+	http.ServeContent(ctx.ResponseWriter(), ctx.Request(), info.Name(), info.ModTime(), f)
+}
@@ -0,0 +1,208 @@
+package router
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// node is a segment of the route tree, children are split into static segments, parameters and
+// catch-all segments, so a path is matched in time of its length rather than number of routes.
+// Static segments are tried first, then constrained parameters, plain parameters and catch-all
+// ones, matching backtracks if a branch has no route for the path or method.
+type node struct {
+	// segment of registered pattern, such as "users", ":id(int)" or "*filepath"
+	segment string
+	// parameter name of a parameter or catch-all segment
+	name string
+	// constraint of parameter, nil for plain parameters
+	exp *regexp.Regexp
+	// route whose pattern ends at this node
+	route *Route
+
+	statics   map[string]*node
+	params    []*node
+	catchalls []*node
+}
+
+// catchSegment matches a catch-all segment, such as "*filepath"
+var catchSegment = regexp.MustCompile(`^\*([^/#?()\.\\]+)$`)
+
+// segments splits path into segments without leading slash, "/" has no segment
+func segments(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+// insert adds route to the tree, it returns false if pattern of route can not be split into
+// segments, such as a named regexp spans segments, which should be matched by regexp
+func (n *node) insert(route *Route) bool {
+	segs := segments(route.path)
+	for i, seg := range segs {
+		if !strings.ContainsAny(seg, ":*()") {
+			continue
+		}
+
+		if sub := paramExp.FindStringSubmatch(seg); sub != nil && sub[0] == seg {
+			if sub[2] == "" {
+				continue
+			}
+
+			exp := sub[2]
+			if e, ok := constraints[exp]; ok {
+				exp = e
+			}
+
+			if _, err := regexp.Compile(`^(?:` + exp + `)$`); err != nil {
+				return false
+			}
+			continue
+		}
+
+		if i == len(segs)-1 && catchSegment.MatchString(seg) {
+			continue
+		}
+
+		return false
+	}
+
+	for i, seg := range segs {
+		n = n.child(seg, i == len(segs)-1)
+	}
+	n.route = route
+
+	return true
+}
+
+// child returns the child of segment, it's created if not exist
+func (n *node) child(seg string, last bool) *node {
+	if last && catchSegment.MatchString(seg) {
+		for _, c := range n.catchalls {
+			if c.segment == seg {
+				return c
+			}
+		}
+
+		c := &node{segment: seg, name: seg[1:]}
+		n.catchalls = append(n.catchalls, c)
+		sort.Slice(n.catchalls, func(i, j int) bool { return n.catchalls[i].segment < n.catchalls[j].segment })
+		return c
+	}
+
+	if sub := paramExp.FindStringSubmatch(seg); sub != nil && sub[0] == seg {
+		for _, c := range n.params {
+			if c.segment == seg {
+				return c
+			}
+		}
+
+		c := &node{segment: seg, name: sub[1]}
+		if sub[2] != "" {
+			exp := sub[2]
+			if e, ok := constraints[exp]; ok {
+				exp = e
+			}
+			c.exp = regexp.MustCompile(`^(?:` + exp + `)$`)
+		}
+
+		n.params = append(n.params, c)
+		sort.Slice(n.params, func(i, j int) bool {
+			a, b := n.params[i], n.params[j]
+			if (a.exp != nil) != (b.exp != nil) {
+				return a.exp != nil
+			}
+			return a.segment < b.segment
+		})
+		return c
+	}
+
+	if n.statics == nil {
+		n.statics = make(map[string]*node)
+	}
+
+	c, ok := n.statics[seg]
+	if !ok {
+		c = &node{segment: seg}
+		n.statics[seg] = c
+	}
+
+	return c
+}
+
+// match searches route of path allows method, any method if method is empty. Static segments
+// are compared case-insensitively if fold. Values of parameters are returned in pairs of name
+// and value.
+func (n *node) match(path, method string, fold bool) (*Route, []string) {
+	if n == nil || !strings.HasPrefix(path, "/") {
+		return nil, nil
+	}
+
+	var params []string
+	if route := n.search(segments(path), method, fold, &params); route != nil {
+		return route, params
+	}
+
+	// "/" has an empty tail for catch-all routes of root, such as "/*filepath"
+	if path == "/" {
+		if route := n.search([]string{""}, method, fold, &params); route != nil {
+			return route, params
+		}
+	}
+
+	return nil, nil
+}
+
+func (n *node) search(segs []string, method string, fold bool, params *[]string) *Route {
+	// Routes with parameters match an optional trailing slash like regexp routes
+	if n.route != nil && (len(segs) == 0 || (len(segs) == 1 && segs[0] == "" && !n.route.static())) {
+		if method == "" || n.route.allows(method) {
+			return n.route
+		}
+	}
+
+	if len(segs) == 0 {
+		return nil
+	}
+
+	seg, rest := segs[0], segs[1:]
+	if c, ok := n.statics[seg]; ok {
+		if route := c.search(rest, method, fold, params); route != nil {
+			return route
+		}
+	} else if fold {
+		for s, c := range n.statics {
+			if strings.EqualFold(s, seg) {
+				if route := c.search(rest, method, fold, params); route != nil {
+					return route
+				}
+			}
+		}
+	}
+
+	if seg != "" {
+		for _, c := range n.params {
+			if c.exp != nil && !c.exp.MatchString(seg) {
+				continue
+			}
+
+			*params = append(*params, c.name, seg)
+			if route := c.search(rest, method, fold, params); route != nil {
+				return route
+			}
+			*params = (*params)[:len(*params)-2]
+		}
+	}
+
+	for _, c := range n.catchalls {
+		if c.route != nil && (method == "" || c.route.allows(method)) {
+			*params = append(*params, c.name, strings.Join(segs, "/"))
+			return c.route
+		}
+	}
+
+	return nil
+}
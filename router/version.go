@@ -0,0 +1,81 @@
+package router
+
+import (
+	"github.com/raythorn/zebra/context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VersionKey is the key of API version in context, it's set by Versions before the handler called
+const VersionKey = "com.raythorn.falcon.version"
+
+// Versions dispatches requests of same route to different handlers by API version declared in
+// Accept header, such as "application/vnd.api+json;version=2". Requests without version are
+// handled by the latest one, and 406 responded if the version requested not exist.
+//
+//	r.Get("/users", router.Versions(map[string]router.Handler{
+//		"1": usersV1,
+//		"2": usersV2,
+//	}))
+func Versions(handlers map[string]Handler) Handler {
+	latest := ""
+	for v := range handlers {
+		if latest == "" || compareVersion(v, latest) > 0 {
+			latest = v
+		}
+	}
+
+	return func(ctx *context.Context) {
+		version := acceptVersion(ctx.Get("Accept"))
+		if version == "" {
+			version = latest
+		}
+
+		handler, ok := handlers[version]
+		if !ok {
+			http.Error(ctx.ResponseWriter(), http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+			return
+		}
+
+		ctx.Set(VersionKey, version)
+		handler(ctx)
+	}
+}
+
+// acceptVersion returns the first version parameter of media types in accept
+func acceptVersion(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		if _, params, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil {
+			if v, ok := params["version"]; ok {
+				return v
+			}
+		}
+	}
+
+	return ""
+}
+
+// compareVersion compares dot separated versions numerically, such as "1.10" > "1.2"
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+
+	return strings.Compare(a, b)
+}
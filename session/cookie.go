@@ -0,0 +1,109 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/sign"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// MaxCookieSize is the max bytes of cookie value saved by CookieStore, browsers drop cookies
+// larger than 4KB
+const MaxCookieSize = 4000
+
+var ErrCookieSize = errors.New("Session: values too large for cookie")
+
+// CookieStore keeps values of sessions in a cookie signed by package sign, nothing is kept on
+// server, so sessions are shared by all instances without a database. Values are encoded in
+// JSON, so numbers are loaded as float64, and they're signed but not encrypted, clients can read
+// them, secrets should never be saved. Destroyed sessions are only cleared from browser, a copied
+// cookie is still valid until expired.
+//
+//	opts := session.Options{Secret: secret}
+//	zebra.Use(session.Middleware(session.NewCookieStore(opts), opts))
+type CookieStore struct {
+	opts Options
+}
+
+// NewCookieStore creates a cookie store with cookie options of session, values are saved in
+// cookie named Name with suffix "_data", and signed with Secret
+func NewCookieStore(opts Options) *CookieStore {
+	if len(opts.Secret) == 0 {
+		log.Panic("Session: secret is required")
+	}
+	if opts.Name == "" {
+		opts.Name = "zebra_session"
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	opts.Name += "_data"
+
+	return &CookieStore{opts: opts}
+}
+
+func (c *CookieStore) Get(ctx *context.Context, id string) (map[string]interface{}, error) {
+	cookie, err := ctx.Request().Cookie(c.opts.Name)
+	if err != nil {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, nil
+	}
+
+	signed, err := url.ParseQuery(string(raw))
+	if err != nil || sign.Verify(signed, c.opts.Secret) != nil || signed.Get("id") != id {
+		return nil, nil
+	}
+
+	values := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(signed.Get("data")), &values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (c *CookieStore) Save(ctx *context.Context, id string, values map[string]interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	signed := sign.Build(url.Values{"id": {id}, "data": {string(data)}}, c.opts.Secret, ttl)
+	value := base64.RawURLEncoding.EncodeToString([]byte(signed.Encode()))
+	if len(value) > MaxCookieSize {
+		return ErrCookieSize
+	}
+
+	replaceCookie(ctx, c.cookie(value, int(ttl.Seconds())))
+	return nil
+}
+
+func (c *CookieStore) Delete(ctx *context.Context, id string) error {
+	replaceCookie(ctx, c.cookie("", -1))
+	return nil
+}
+
+func (c *CookieStore) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     c.opts.Name,
+		Value:    value,
+		Path:     c.opts.Path,
+		Domain:   c.opts.Domain,
+		MaxAge:   maxAge,
+		Secure:   c.opts.Secure,
+		HttpOnly: true,
+		SameSite: c.opts.SameSite,
+	}
+}
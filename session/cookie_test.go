@@ -0,0 +1,101 @@
+package session
+
+import (
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieStore(t *testing.T) {
+	opts := Options{Secret: []byte("secret")}
+
+	r := router.New()
+	r.Use(Middleware(NewCookieStore(opts), opts))
+	r.Post("/login", func(ctx *context.Context) {
+		ctx.Session().Regenerate()
+		ctx.Session().Set("user_id", 42)
+		ctx.WriteString("ok")
+	})
+	r.Get("/whoami", func(ctx *context.Context) {
+		ctx.WriteString(fmt.Sprint(ctx.Session().Get("user_id")))
+	})
+	r.Post("/logout", func(ctx *context.Context) {
+		ctx.Session().Destroy()
+		ctx.WriteString("bye")
+	})
+
+	do := func(method, path string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, map[string]*http.Cookie) {
+		req := httptest.NewRequest(method, path, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+
+		set := make(map[string]*http.Cookie)
+		for _, c := range rw.Result().Cookies() {
+			set[c.Name] = c
+		}
+		return rw, set
+	}
+
+	_, anonymous := do("GET", "/whoami")
+	if anonymous["zebra_session_data"] != nil {
+		t.Errorf("expect no values saved for unchanged session")
+	}
+
+	_, login := do("POST", "/login", anonymous["zebra_session"])
+	id, data := login["zebra_session"], login["zebra_session_data"]
+	if id == nil || data == nil || !data.HttpOnly {
+		t.Fatalf("expect values saved in cookie before body written, got %v", login)
+	}
+
+	if rw, _ := do("GET", "/whoami", id, data); rw.Body.String() != "42" {
+		t.Errorf("expect user loaded from cookie, got %s", rw.Body.String())
+	}
+
+	tampered := *data
+	tampered.Value = strings.ToUpper(data.Value[:8]) + data.Value[8:]
+	if rw, _ := do("GET", "/whoami", id, &tampered); rw.Body.String() != "<nil>" {
+		t.Errorf("expect tampered cookie rejected, got %s", rw.Body.String())
+	}
+
+	if rw, _ := do("GET", "/whoami", anonymous["zebra_session"], data); rw.Body.String() != "<nil>" {
+		t.Errorf("expect values of another session rejected, got %s", rw.Body.String())
+	}
+
+	if _, logout := do("POST", "/logout", id, data); logout["zebra_session_data"] == nil || logout["zebra_session_data"].MaxAge >= 0 {
+		t.Errorf("expect values cookie cleared on logout, got %v", logout["zebra_session_data"])
+	}
+}
+
+func TestCookieStoreLimits(t *testing.T) {
+	store := NewCookieStore(Options{Secret: []byte("secret")})
+
+	rw := httptest.NewRecorder()
+	ctx := context.New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+
+	large := map[string]interface{}{"blob": strings.Repeat("z", MaxCookieSize)}
+	if err := store.Save(ctx, "id", large, time.Hour); err != ErrCookieSize {
+		t.Errorf("expect values too large rejected, got %v", err)
+	}
+
+	if err := store.Save(ctx, "id", map[string]interface{}{"k": "v"}, -time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range rw.Result().Cookies() {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	ctx.Reset(httptest.NewRecorder(), req)
+	if values, err := store.Get(ctx, "id"); values != nil || err != nil {
+		t.Errorf("expect expired values dropped, got %v %v", values, err)
+	}
+}
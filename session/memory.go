@@ -0,0 +1,78 @@
+package session
+
+import (
+	"github.com/raythorn/zebra/context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in memory, sessions are lost when process exits, and not shared
+// between processes, it's suitable for development and single instance deployment.
+type MemoryStore struct {
+	mutex    sync.Mutex
+	sessions map[string]*entry
+	sweep    time.Time
+}
+
+type entry struct {
+	values  map[string]interface{}
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*entry), sweep: time.Now()}
+}
+
+func (m *MemoryStore) Get(ctx *context.Context, id string) (map[string]interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	e, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+
+	if time.Now().After(e.expires) {
+		delete(m.sessions, id)
+		return nil, nil
+	}
+
+	return copyValues(e.values), nil
+}
+
+func (m *MemoryStore) Save(ctx *context.Context, id string, values map[string]interface{}, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	m.sessions[id] = &entry{values: copyValues(values), expires: now.Add(ttl)}
+
+	// Evict expired sessions lazily, at most once per minute
+	if now.Sub(m.sweep) > time.Minute {
+		for k, e := range m.sessions {
+			if now.After(e.expires) {
+				delete(m.sessions, k)
+			}
+		}
+		m.sweep = now
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx *context.Context, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+// copyValues copies values, so requests of same session never share the map
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
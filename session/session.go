@@ -0,0 +1,288 @@
+// Copyright 2016 Derek Ray. All rights reserved.
+// Use of this source code is governed by Apache License 2.0
+// that can be found in the LICENSE file.
+
+// Package session implements server side sessions with pluggable stores.
+//
+// Session id is saved in a cookie signed with HMAC-SHA256, values are kept in Store, and
+// loaded by Middleware before handler called, then saved before response written if changed.
+// MemoryStore keeps values in memory of process, and CookieStore keeps them in a signed cookie.
+//
+//	zebra.Use(session.Middleware(session.NewMemoryStore(), session.Options{Secret: secret}))
+//
+//	func login(ctx *context.Context) {
+//		ctx.Session().Regenerate()
+//		ctx.Session().Set("user_id", 42)
+//	}
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/log"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Store saves values of sessions by id, ctx is the request of session, stores like CookieStore
+// keep values in response
+type Store interface {
+	// Get returns values of session id, nil if not exist or expired
+	Get(ctx *context.Context, id string) (map[string]interface{}, error)
+
+	// Save persists values of session id, which will be expired after ttl. It's called before
+	// response written if session changed, and again after handler returned if changed since then.
+	Save(ctx *context.Context, id string, values map[string]interface{}, ttl time.Duration) error
+
+	// Delete deletes session id
+	Delete(ctx *context.Context, id string) error
+}
+
+// Options of session cookie, Secret is required
+type Options struct {
+	Secret   []byte
+	Name     string        // Name of cookie, "zebra_session" by default
+	MaxAge   time.Duration // Session expires after MaxAge since last saved, 24 hours by default
+	Path     string        // "/" by default
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite // http.SameSiteLaxMode by default
+}
+
+var ErrRegenerate = errors.New("Session: failed to generate session id")
+
+// Middleware returns a midware which loads session by the signed cookie, and exposes it with
+// ctx.Session(). Changes are saved to store before response written, or after handler returned
+// if nothing written, and cookie is refreshed when new session created or regenerated.
+func Middleware(store Store, opts Options) router.Midware {
+	if len(opts.Secret) == 0 {
+		log.Panic("Session: secret is required")
+	}
+	if opts.Name == "" {
+		opts.Name = "zebra_session"
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 24 * time.Hour
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(ctx *context.Context) bool {
+		s := &session{ctx: ctx, store: store, opts: &opts}
+
+		if cookie, err := ctx.Request().Cookie(opts.Name); err == nil {
+			if id, ok := verify(cookie.Value, opts.Secret); ok {
+				values, err := store.Get(ctx, id)
+				if err != nil {
+					log.Error("Session: load %s failed, %s", id, err)
+				} else if values != nil {
+					s.id, s.values = id, values
+				}
+			}
+		}
+
+		if s.id == "" {
+			if err := s.renew(); err != nil {
+				log.Error("%s", err)
+				http.Error(ctx.ResponseWriter(), http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return false
+			}
+			s.values = make(map[string]interface{})
+		}
+
+		ctx.SetSession(s)
+		ctx.SetResponseWriter(&saveWriter{ResponseWriter: ctx.ResponseWriter(), session: s})
+		ctx.Defer(s.save)
+		return true
+	}
+}
+
+// saveWriter saves session before response written, so stores can still set headers, such as
+// cookie of CookieStore
+type saveWriter struct {
+	http.ResponseWriter
+	session *session
+	saved   bool
+}
+
+func (w *saveWriter) WriteHeader(code int) {
+	w.save()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *saveWriter) Write(data []byte) (int, error) {
+	w.save()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *saveWriter) Flush() {
+	w.save()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original writer for http.ResponseController
+func (w *saveWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *saveWriter) save() {
+	if !w.saved {
+		w.saved = true
+		w.session.save()
+	}
+}
+
+// session implements context.Session
+type session struct {
+	ctx      *context.Context
+	store    Store
+	opts     *Options
+	id       string
+	values   map[string]interface{}
+	modified bool
+	// ids replaced by Regenerate, they're deleted from store when saved
+	stale     []string
+	destroyed bool
+}
+
+func (s *session) ID() string {
+	return s.id
+}
+
+func (s *session) Get(key string) interface{} {
+	return s.values[key]
+}
+
+func (s *session) Set(key string, value interface{}) {
+	s.values[key] = value
+	s.modified = true
+}
+
+func (s *session) Delete(key string) {
+	delete(s.values, key)
+	s.modified = true
+}
+
+func (s *session) Regenerate() error {
+	old := s.id
+	if err := s.renew(); err != nil {
+		return err
+	}
+
+	s.stale = append(s.stale, old)
+	s.modified = true
+	return nil
+}
+
+func (s *session) Destroy() {
+	s.values = make(map[string]interface{})
+	s.destroyed = true
+
+	s.setCookie(&http.Cookie{
+		Name:   s.opts.Name,
+		Path:   s.opts.Path,
+		Domain: s.opts.Domain,
+		MaxAge: -1,
+	})
+}
+
+// renew generates a new id and sets it to cookie
+func (s *session) renew() error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return ErrRegenerate
+	}
+
+	s.id = base64.RawURLEncoding.EncodeToString(b)
+	s.setCookie(&http.Cookie{
+		Name:     s.opts.Name,
+		Value:    s.id + "." + signature(s.id, s.opts.Secret),
+		Path:     s.opts.Path,
+		Domain:   s.opts.Domain,
+		MaxAge:   int(s.opts.MaxAge.Seconds()),
+		Secure:   s.opts.Secure,
+		HttpOnly: true,
+		SameSite: s.opts.SameSite,
+	})
+
+	return nil
+}
+
+// setCookie sets session cookie, replaces the one set before in same request
+func (s *session) setCookie(cookie *http.Cookie) {
+	replaceCookie(s.ctx, cookie)
+}
+
+// replaceCookie sets cookie to response, the one of same name set before in same request is removed
+func replaceCookie(ctx *context.Context, cookie *http.Cookie) {
+	header := ctx.ResponseWriter().Header()
+
+	cookies := header["Set-Cookie"][:0]
+	for _, c := range header["Set-Cookie"] {
+		if !strings.HasPrefix(c, cookie.Name+"=") {
+			cookies = append(cookies, c)
+		}
+	}
+	header["Set-Cookie"] = cookies
+
+	http.SetCookie(ctx.ResponseWriter(), cookie)
+}
+
+// save persists changes to store, it's called before response written and after handler returned
+func (s *session) save() {
+	for _, id := range s.stale {
+		if err := s.store.Delete(s.ctx, id); err != nil {
+			log.Error("Session: delete %s failed, %s", id, err)
+		}
+	}
+	s.stale = nil
+
+	if s.destroyed {
+		if err := s.store.Delete(s.ctx, s.id); err != nil {
+			log.Error("Session: delete %s failed, %s", s.id, err)
+		}
+		return
+	}
+
+	if !s.modified {
+		return
+	}
+	s.modified = false
+
+	if err := s.store.Save(s.ctx, s.id, s.values, s.opts.MaxAge); err != nil {
+		log.Error("Session: save %s failed, %s", s.id, err)
+	}
+}
+
+func signature(id string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks signature of cookie value and returns the session id
+func verify(value string, secret []byte) (string, bool) {
+	i := strings.LastIndex(value, ".")
+	if i <= 0 {
+		return "", false
+	}
+
+	id := value[:i]
+	if !hmac.Equal([]byte(value[i+1:]), []byte(signature(id, secret))) {
+		return "", false
+	}
+
+	return id, true
+}
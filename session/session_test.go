@@ -0,0 +1,91 @@
+package session
+
+import (
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"github.com/raythorn/zebra/router"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	store := NewMemoryStore()
+
+	r := router.New()
+	r.Use(Middleware(store, Options{Secret: []byte("secret")}))
+	r.Post("/login", func(ctx *context.Context) {
+		ctx.Session().Regenerate()
+		ctx.Session().Set("user_id", 42)
+	})
+	r.Get("/whoami", func(ctx *context.Context) {
+		ctx.WriteString(fmt.Sprint(ctx.Session().Get("user_id")))
+	})
+	r.Post("/logout", func(ctx *context.Context) {
+		ctx.Session().Destroy()
+	})
+
+	do := func(method, path string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rw := httptest.NewRecorder()
+		r.Handle(rw, req)
+
+		for _, c := range rw.Result().Cookies() {
+			if c.Name == "zebra_session" {
+				return rw, c
+			}
+		}
+		return rw, nil
+	}
+
+	rw, anonymous := do("GET", "/whoami", nil)
+	if rw.Body.String() != "<nil>" || anonymous == nil || !anonymous.HttpOnly {
+		t.Fatalf("expect new anonymous session, got %s %v", rw.Body.String(), anonymous)
+	}
+
+	_, login := do("POST", "/login", anonymous)
+	if login == nil || login.Value == anonymous.Value {
+		t.Fatalf("expect session id regenerated on login")
+	}
+
+	rw, _ = do("GET", "/whoami", login)
+	if rw.Body.String() != "42" {
+		t.Errorf("expect user loaded from session, got %s", rw.Body.String())
+	}
+
+	rw, _ = do("GET", "/whoami", anonymous)
+	if rw.Body.String() != "<nil>" {
+		t.Errorf("expect old session id not usable, got %s", rw.Body.String())
+	}
+
+	tampered := *login
+	tampered.Value = "forged" + login.Value[len("forged"):]
+	rw, _ = do("GET", "/whoami", &tampered)
+	if rw.Body.String() != "<nil>" {
+		t.Errorf("expect tampered cookie rejected, got %s", rw.Body.String())
+	}
+
+	do("POST", "/logout", login)
+	rw, _ = do("GET", "/whoami", login)
+	if rw.Body.String() != "<nil>" {
+		t.Errorf("expect session destroyed, got %s", rw.Body.String())
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(nil, "live", map[string]interface{}{"k": "v"}, time.Hour)
+	store.Save(nil, "dead", map[string]interface{}{"k": "v"}, -time.Second)
+
+	if values, _ := store.Get(nil, "live"); values["k"] != "v" {
+		t.Errorf("expect live session loaded, got %v", values)
+	}
+
+	if values, _ := store.Get(nil, "dead"); values != nil {
+		t.Errorf("expect expired session dropped, got %v", values)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2016 Derek Ray. All rights reserved.
+// Use of this source code is governed by Apache License 2.0
+// that can be found in the LICENSE file.
+
+// Package sign builds and verifies signed query parameters, which can be used to make
+// time-limited URLs, such as unsubscribe links or temporary download links.
+//
+// All values are sorted and signed with HMAC-SHA256, the expire time(unix seconds) is saved
+// in parameter "expires" and the signature in "signature".
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//Keys of signing parameters
+const (
+	ExpiresKey   = "expires"
+	SignatureKey = "signature"
+
+	pathKey = "@path"
+)
+
+var (
+	ErrMissing   = errors.New("Sign: signature or expires missing")
+	ErrExpired   = errors.New("Sign: signature expired")
+	ErrSignature = errors.New("Sign: signature not match")
+)
+
+//Build signs values with secret, which will be expired after ttl, the returned values
+//contains all original values and signing parameters
+func Build(values url.Values, secret []byte, ttl time.Duration) url.Values {
+	signed := url.Values{}
+	for k, v := range values {
+		if k != SignatureKey {
+			signed[k] = append([]string{}, v...)
+		}
+	}
+
+	signed.Set(ExpiresKey, strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	signed.Set(SignatureKey, signature(signed, secret))
+
+	return signed
+}
+
+//Verify checks signature and expire time of values
+func Verify(values url.Values, secret []byte) error {
+	sign := values.Get(SignatureKey)
+	expires := values.Get(ExpiresKey)
+	if sign == "" || expires == "" {
+		return ErrMissing
+	}
+
+	if !hmac.Equal([]byte(sign), []byte(signature(values, secret))) {
+		return ErrSignature
+	}
+
+	timestamp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+
+	if time.Now().Unix() > timestamp {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+//BuildURL signs values bound with path, and returns the signed url as path?query
+func BuildURL(path string, values url.Values, secret []byte, ttl time.Duration) string {
+	bound := withPath(path, values)
+	signed := Build(bound, secret, ttl)
+	signed.Del(pathKey)
+
+	return path + "?" + signed.Encode()
+}
+
+//VerifyURL checks values are signed with path by BuildURL and not expired
+func VerifyURL(path string, values url.Values, secret []byte) error {
+	return Verify(withPath(path, values), secret)
+}
+
+func withPath(path string, values url.Values) url.Values {
+	bound := url.Values{}
+	for k, v := range values {
+		bound[k] = v
+	}
+	bound.Set(pathKey, path)
+
+	return bound
+}
+
+// signature signs all values but signature with secret, values are sorted by key
+func signature(values url.Values, secret []byte) string {
+	unsigned := url.Values{}
+	for k, v := range values {
+		if k != SignatureKey {
+			unsigned[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned.Encode()))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
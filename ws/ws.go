@@ -0,0 +1,351 @@
+// Copyright 2016 Derek Ray. All rights reserved.
+// Use of this source code is governed by Apache License 2.0
+// that can be found in the LICENSE file.
+
+// Package ws implement the server side of WebSocket protocol(RFC 6455) on top of Context.Hijack.
+//
+//	func echo(ctx *context.Context) {
+//		conn, err := ws.Upgrade(ctx)
+//		if err != nil {
+//			return
+//		}
+//		defer conn.Close()
+//
+//		for {
+//			mt, data, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			conn.WriteMessage(mt, data)
+//		}
+//	}
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/raythorn/zebra/context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Message types, defined as frame opcode in RFC 6455
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+//Close status codes
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseNoStatus      = 1005
+	CloseTooLarge      = 1009
+)
+
+const (
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	//Max size of a message, larger message will be rejected with CloseTooLarge
+	MaxMessageSize = 32 << 20
+)
+
+//CloseError returned by ReadMessage when peer closed the connection
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("WebSocket: closed with code %d %s", e.Code, e.Text)
+}
+
+//Conn is a WebSocket connection, ReadMessage should be called in one goroutine, and
+//WriteMessage is safe to be called concurrently
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex
+	closed bool
+}
+
+//Upgrade performs WebSocket handshake on the request of ctx and returns the connection,
+//400 will be responded if the request is not a valid WebSocket handshake.
+func Upgrade(ctx *context.Context) (*Conn, error) {
+
+	if ctx.Method() != "GET" {
+		return nil, handshakeError(ctx, http.StatusMethodNotAllowed, "WebSocket: method not GET")
+	}
+
+	if !tokenContains(ctx.Request().Header, "Connection", "upgrade") {
+		return nil, handshakeError(ctx, http.StatusBadRequest, "WebSocket: 'Connection' header not contains 'Upgrade'")
+	}
+
+	if !tokenContains(ctx.Request().Header, "Upgrade", "websocket") {
+		return nil, handshakeError(ctx, http.StatusBadRequest, "WebSocket: 'Upgrade' header not 'websocket'")
+	}
+
+	if ctx.Request().Header.Get("Sec-Websocket-Version") != "13" {
+		ctx.Header("Sec-WebSocket-Version", "13")
+		return nil, handshakeError(ctx, http.StatusBadRequest, "WebSocket: unsupported version")
+	}
+
+	key := ctx.Request().Header.Get("Sec-Websocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, handshakeError(ctx, http.StatusBadRequest, "WebSocket: invalid 'Sec-WebSocket-Key'")
+	}
+
+	netconn, rw, err := ctx.Hijack()
+	if err != nil {
+		return nil, handshakeError(ctx, http.StatusInternalServerError, err.Error())
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+
+	netconn.SetDeadline(time.Time{})
+	if _, err := netconn.Write([]byte(response)); err != nil {
+		netconn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: netconn, reader: rw.Reader}, nil
+}
+
+//ReadMessage reads a complete message, fragmented message will be assembled. Ping is answered
+//with pong automatically, and a close frame will be echoed and returned as *CloseError.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+
+	messageType := 0
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteMessage(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			code := CloseNoStatus
+			text := ""
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+				text = string(payload[2:])
+			}
+			c.writeClose(code, "")
+			c.Close()
+			return 0, nil, &CloseError{Code: code, Text: text}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "WebSocket: unexpected data frame in fragmented message")
+			}
+			messageType = opcode
+		case 0:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "WebSocket: unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "WebSocket: unknown opcode")
+		}
+
+		if len(message)+len(payload) > MaxMessageSize {
+			return 0, nil, c.fail(CloseTooLarge, "WebSocket: message too large")
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return messageType, message, nil
+		}
+	}
+}
+
+//WriteMessage writes data as a single frame with messageType
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if len(data) > 125 {
+			return errors.New("WebSocket: control frame too large")
+		}
+	default:
+		return errors.New("WebSocket: unknown message type")
+	}
+
+	frame := make([]byte, 0, len(data)+10)
+	frame = append(frame, 0x80|byte(messageType))
+
+	switch size := len(data); {
+	case size <= 125:
+		frame = append(frame, byte(size))
+	case size <= 0xffff:
+		frame = append(frame, 126, byte(size>>8), byte(size))
+	default:
+		frame = append(frame, 127)
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[2:], uint64(size))
+	}
+
+	frame = append(frame, data...)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return errors.New("WebSocket: connection closed")
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+//Ping sends a ping frame, the pong will be consumed by ReadMessage
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteMessage(PingMessage, data)
+}
+
+//CloseWith sends a close frame with code and reason, then closes the connection
+func (c *Conn) CloseWith(code int, reason string) error {
+	c.writeClose(code, reason)
+	return c.Close()
+}
+
+//Close closes the underlying connection without sending close frame
+func (c *Conn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	return c.conn.Close()
+}
+
+//SetDeadline sets read and write deadline of the underlying connection
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+//RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := int(header[0] & 0x0f)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "WebSocket: reserved bits set")
+	}
+
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "WebSocket: client frame not masked")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= CloseMessage && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(CloseProtocolError, "WebSocket: invalid control frame")
+	}
+
+	if length > MaxMessageSize {
+		return false, 0, nil, c.fail(CloseTooLarge, "WebSocket: frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (c *Conn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	return c.WriteMessage(CloseMessage, payload)
+}
+
+// fail closes connection with code and returns error of reason
+func (c *Conn) fail(code int, reason string) error {
+	c.CloseWith(code, "")
+	return errors.New(reason)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func tokenContains(header http.Header, name, token string) bool {
+	for _, value := range header[name] {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func handshakeError(ctx *context.Context, code int, reason string) error {
+	http.Error(ctx.ResponseWriter(), http.StatusText(code), code)
+	return errors.New(reason)
+}
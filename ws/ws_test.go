@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/raythorn/zebra/context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func echo(rw http.ResponseWriter, req *http.Request) {
+	ctx := context.New()
+	ctx.Reset(rw, req)
+
+	conn, err := Upgrade(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(mt, data)
+	}
+}
+
+func maskedFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func readFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func TestUpgradeEcho(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echo))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expect status 101, got %d", resp.StatusCode)
+	}
+
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %s", accept)
+	}
+
+	conn.Write(maskedFrame(TextMessage, []byte("zebra")))
+	if opcode, payload := readFrame(t, reader); opcode != TextMessage || string(payload) != "zebra" {
+		t.Errorf("unexpected echo %d %s", opcode, payload)
+	}
+
+	conn.Write(maskedFrame(PingMessage, []byte("ping")))
+	if opcode, payload := readFrame(t, reader); opcode != PongMessage || string(payload) != "ping" {
+		t.Errorf("unexpected pong %d %s", opcode, payload)
+	}
+
+	closing := make([]byte, 2)
+	binary.BigEndian.PutUint16(closing, CloseNormal)
+	conn.Write(maskedFrame(CloseMessage, closing))
+	if opcode, payload := readFrame(t, reader); opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseNormal {
+		t.Errorf("unexpected close reply %d %v", opcode, payload)
+	}
+}
+
+func TestUpgradeBadRequest(t *testing.T) {
+	rw := httptest.NewRecorder()
+	ctx := context.New()
+	ctx.Reset(rw, httptest.NewRequest("GET", "/", nil))
+
+	if _, err := Upgrade(ctx); err == nil {
+		t.Fatal("expect handshake error")
+	}
+
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("expect status 400, got %d", rw.Code)
+	}
+}
@@ -6,8 +6,14 @@
 package zebra
 
 import (
+	"context"
+	zcontext "github.com/raythorn/zebra/context"
 	"github.com/raythorn/zebra/oss"
 	"github.com/raythorn/zebra/router"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
 )
 
 var (
@@ -20,58 +26,104 @@ func init() {
 	Env = &Environment{data: make(map[string]string)}
 }
 
-//Run starts a http(s) server
+//Run starts a http(s) server, it blocks until server stopped, SIGINT and SIGTERM will shutdown
+//server gracefully
 func Run() {
 	zebra.run()
 }
 
+//SetRunOptions sets read/write timeouts of servers started by Run, zero fields use safe defaults
+func SetRunOptions(opts router.RunOptions) {
+	zebra.RunOptions(opts)
+}
+
+//Shutdown stops server gracefully, in-flight requests will be drained until ctx done
+func Shutdown(ctx context.Context) error {
+	return zebra.Shutdown(ctx)
+}
+
 //Insert midware to http server, which will be called before each request handled.
 func Use(handler router.Midware) {
 	zebra.Use(handler)
 }
 
-func Oss(pattern, root string, archive oss.Archive) {
-	zebra.Oss(pattern, root, archive)
+//SafeUse insert midware like Use, but its panics are logged and ignored
+func SafeUse(midware router.Midware) {
+	zebra.SafeUse(midware)
+}
+
+//PreRoute add a hook run before routing, such as rewriting path with ctx.SetPath
+func PreRoute(hook func(*zcontext.Context)) {
+	zebra.PreRoute(hook)
+}
+
+//UseFunc add a midware wraps routing and handler, which can run code after handler returned
+func UseFunc(wrapper router.WrapFunc) {
+	zebra.UseFunc(wrapper)
+}
+
+//UseForMethods add a midware called for matched requests of methods only, such as POST and DELETE
+func UseForMethods(methods []string, midware router.Midware) {
+	zebra.UseForMethods(methods, midware)
+}
+
+func Oss(pattern, root string, archive oss.Archive) *oss.Oss {
+	return zebra.Oss(pattern, root, archive)
 }
 
 //Get add a GET handler, which used to get data from server
-func Get(pattern string, handler router.Handler) {
-	zebra.Get(pattern, handler)
+func Get(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Get(pattern, handler, midwares...)
 }
 
 //Patch add a PATCH handler, which used to patch existed data
-func Patch(pattern string, handler router.Handler) {
-	zebra.Patch(pattern, handler)
+func Patch(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Patch(pattern, handler, midwares...)
 }
 
 //Put add a PUT handler, which used to update data
-func Put(pattern string, handler router.Handler) {
-	zebra.Put(pattern, handler)
+func Put(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Put(pattern, handler, midwares...)
 }
 
 //Post add a POST handler, which used to create resource
-func Post(pattern string, handler router.Handler) {
-	zebra.Post(pattern, handler)
+func Post(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Post(pattern, handler, midwares...)
 }
 
 //Delete add a DELETE handler, which used to delete resource from server
-func Delete(pattern string, handler router.Handler) {
-	zebra.Delete(pattern, handler)
+func Delete(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Delete(pattern, handler, midwares...)
 }
 
 //Head add a HEAD handler
-func Head(pattern string, handler router.Handler) {
-	zebra.Head(pattern, handler)
+func Head(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Head(pattern, handler, midwares...)
 }
 
 //Options add a OPTIONS handler
-func Options(pattern string, handler router.Handler) {
-	zebra.Options(pattern, handler)
+func Options(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Options(pattern, handler, midwares...)
 }
 
 //Any add a ANY handler, which can response to all method
-func Any(pattern string, handler router.Handler) {
-	zebra.Any(pattern, handler)
+func Any(pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Any(pattern, handler, midwares...)
+}
+
+//Route add a handler for requests of method, and returns the route for further configuration
+func Route(method, pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.Route(method, pattern, handler, midwares...)
+}
+
+//Routes return all registered routes, such as for dumping them at startup
+func Routes() []router.RouteInfo {
+	return zebra.Routes()
+}
+
+//Match add a handler for requests of methods, such as []string{"POST", "PUT"}
+func Match(methods []string, pattern string, handler router.Handler, midwares ...router.Midware) {
+	zebra.Match(methods, pattern, handler, midwares...)
 }
 
 //NotFound add a not found handler, which used to be the handler when request not found
@@ -79,11 +131,133 @@ func NotFound(handler router.Handler) {
 	zebra.NotFound(handler)
 }
 
+//DefaultNotFound set the fallback renderer used when no not found handler set
+func DefaultNotFound(handler router.Handler) {
+	zebra.DefaultNotFound(handler)
+}
+
 //NotAllowed add a not allowed handler, which used to be the handler when request not allowed
 func NotAllowed(handler router.Handler) {
 	zebra.NotAllowed(handler)
 }
 
+//Expect add a check for "Expect: 100-continue" requests, which runs before body sent
+func Expect(check router.ExpectFunc) {
+	zebra.Expect(check)
+}
+
+//LazyContinue delay 100 Continue until midwares passed, so they can reject uploads before body sent
+func LazyContinue(enable bool) {
+	zebra.LazyContinue(enable)
+}
+
+//Templates set templates used by Context.Render
+func Templates(templates *template.Template) {
+	zebra.Templates(templates)
+}
+
+//SetViews load html/template files in dir used by Context.HTML, with layouts and partials
+func SetViews(dir string, opts ...zcontext.ViewOptions) {
+	zebra.SetViews(dir, opts...)
+}
+
+//SetRenderer set the engine used by Context.Render
+func SetRenderer(renderer zcontext.Renderer) {
+	zebra.SetRenderer(renderer)
+}
+
+//RenderFunc add a func which transforms data before Context.Render executes
+func RenderFunc(fn zcontext.RenderFunc) {
+	zebra.RenderFunc(fn)
+}
+
+//Develop enable or disable develop mode
+func Develop(enable bool) {
+	zebra.Develop(enable)
+}
+
+//MethodOverride enables routing POST requests as PUT/PATCH/DELETE by X-HTTP-Method-Override header
+//or _method form field, it's disabled by default
+func MethodOverride(enable bool) {
+	zebra.MethodOverride(enable)
+}
+
+//TrailingSlash set how trailing slash handled, router.SlashStrict, router.SlashRedirect or router.SlashMerge
+func TrailingSlash(mode int) {
+	zebra.TrailingSlash(mode)
+}
+
+//CaseInsensitive enables matching request path case-insensitively, values of parameters keep their case
+func CaseInsensitive(enable bool) {
+	zebra.CaseInsensitive(enable)
+}
+
+//StrictRoutes makes registering a method and pattern twice panic, instead of logging a warning
+func StrictRoutes(enable bool) {
+	zebra.StrictRoutes(enable)
+}
+
+//HeaderLimit limits number and total bytes of request headers, requests exceeding them get 431
+func HeaderLimit(count, size int) {
+	zebra.HeaderLimit(count, size)
+}
+
+//MaxURLLength limits bytes of request URI, requests with longer ones get 414
+func MaxURLLength(n int) {
+	zebra.MaxURLLength(n)
+}
+
+//MaxPathDepth limits segments of request path, requests with deeper paths get 404 without matching
+func MaxPathDepth(n int) {
+	zebra.MaxPathDepth(n)
+}
+
+//Host returns a sub router only serves requests to host matched pattern, such as "api.example.com"
+//or "*.example.com", unmatched requests fall through to the default routes
+func Host(pattern string) router.Router {
+	return zebra.Host(pattern)
+}
+
+//StaticSPA serves a single page app in dir under prefix, index is served for paths without file
+func StaticSPA(prefix, dir, index string) {
+	zebra.StaticSPA(prefix, dir, index)
+}
+
+//Favicon serves file at /favicon.ico with long cache headers
+func Favicon(file string) {
+	zebra.Favicon(file)
+}
+
+//Robots serves content as /robots.txt
+func Robots(content string) {
+	zebra.Robots(content)
+}
+
+//Health registers a GET liveness probe at path, such as "/healthz"
+func Health(path string) {
+	zebra.Health(path)
+}
+
+//Ready registers a GET readiness probe at path, such as "/readyz", it responds 503 if any check fails
+func Ready(path string, checks ...func() error) {
+	zebra.Ready(path, checks...)
+}
+
+//Mount serves requests under prefix with a http.Handler, prefix is stripped from request path
+func Mount(prefix string, handler http.Handler) {
+	zebra.Mount(prefix, handler)
+}
+
+//Freeze stops further registration, requests are matched without locking after it
+func Freeze() {
+	zebra.Freeze()
+}
+
+//Test run a request through routes without network, and return the recorded response
+func Test(method, target string, body io.Reader) (*httptest.ResponseRecorder, error) {
+	return zebra.Test(method, target, body)
+}
+
 //Group assemble handlers with same prefix together, routes can be routes and sub-groups, with
 //group you can add midwares with Before and After, Before add midware to be called before
 //handler called and After add midware to be called after handler called
@@ -96,42 +270,47 @@ func GSub(prefix string, routes ...interface{}) *router.Group {
 	return zebra.g.Sub(prefix, routes...)
 }
 
+//GOss creates a object storage route for group, requests to objects run through midwares of group
+func GOss(pattern, root string, archive oss.Archive) *router.Route {
+	return zebra.g.Oss(pattern, root, archive)
+}
+
 //GGet add a grouped GET handler
-func GGet(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Get(pattern, handler)
+func GGet(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Get(pattern, handler, midwares...)
 }
 
 //GPatch add a grouped PATCH handler
-func GPatch(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Patch(pattern, handler)
+func GPatch(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Patch(pattern, handler, midwares...)
 }
 
 //GPut add a grouped PUT handler
-func GPut(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Put(pattern, handler)
+func GPut(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Put(pattern, handler, midwares...)
 }
 
 //GPost add a grouped POST handler
-func GPost(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Post(pattern, handler)
+func GPost(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Post(pattern, handler, midwares...)
 }
 
 //GDelete add a grouped DELETE handler
-func GDelete(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Delete(pattern, handler)
+func GDelete(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Delete(pattern, handler, midwares...)
 }
 
 //GHead add a grouped HEAD handler
-func GHead(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Head(pattern, handler)
+func GHead(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Head(pattern, handler, midwares...)
 }
 
 //GOptions add a grouped OPTIONS handler
-func GOptions(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Options(pattern, handler)
+func GOptions(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Options(pattern, handler, midwares...)
 }
 
 //GAny add a grouped ANY handler
-func GAny(pattern string, handler router.Handler) *router.Route {
-	return zebra.g.Any(pattern, handler)
+func GAny(pattern string, handler router.Handler, midwares ...router.Midware) *router.Route {
+	return zebra.g.Any(pattern, handler, midwares...)
 }